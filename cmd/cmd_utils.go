@@ -1,67 +1,240 @@
 package cmd
 
 import (
-	"database/sql"
 	"fmt"
+	"log"
+	"strings"
 
 	"github.com/aitoooooo/binlogx/pkg/cache"
 	"github.com/aitoooooo/binlogx/pkg/config"
 	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/aitoooooo/binlogx/pkg/schema"
+	"github.com/aitoooooo/binlogx/pkg/source"
+	"github.com/aitoooooo/binlogx/pkg/util"
 )
 
+// newDataSource 根据全局配置创建数据源，指定了 --source 时使用离线文件，否则使用在线数据库
+func newDataSource(cfg *models.GlobalConfig) source.DataSource {
+	if cfg.Source != "" {
+		return withSchemaHistory(newFileSource(cfg), cfg, cfg.DBConnection)
+	}
+	return withSchemaHistory(newMySQLSource(cfg), cfg, cfg.DBConnection)
+}
+
+// withSchemaHistory 指定了 --schema-history 时包装数据源，重放 DDL 并按事件位置的表结构映射列名
+// 历史中没有记录的表以 --schema-file 作为起始位置的基线结构；dbConnection 的 INFORMATION_SCHEMA 是当前结构，
+// 只用于历史中没有任何版本的表
+func withSchemaHistory(ds source.DataSource, cfg *models.GlobalConfig, dbConnection string) source.DataSource {
+	if cfg.SchemaHistory == "" {
+		return ds
+	}
+	tracking := schema.NewTrackingSource(ds, cfg.SchemaHistory)
+	if cfg.SchemaFile != "" {
+		catalog, err := schema.LoadSchemaFile(cfg.SchemaFile)
+		if err != nil {
+			log.Printf("表结构历史: 无法加载基线: %v", err)
+		} else {
+			tracking.SetBaseline(catalog)
+		}
+	}
+	if metaCache, err := newMetaCache(dbConnection, ""); err == nil && metaCache != nil {
+		tracking.SetCurrentSchema(metaCache)
+	}
+	return tracking
+}
+
+// newMetaCache 创建列名缓存，优先使用 schema 文件中的表结构，找不到的表再查询数据库
+// 两者都没有时返回 nil
+func newMetaCache(dbConnection, schemaFile string) (*cache.MetaCache, error) {
+	var loaders []cache.TableMetaLoader
+	if schemaFile != "" {
+		catalog, err := schema.LoadSchemaFile(schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		loaders = append(loaders, catalog.GetTableMeta)
+	}
+	if dbConnection != "" {
+		if db, err := source.OpenDB(dbConnection); err == nil {
+			loaders = append(loaders, cache.DBLoader(db))
+		}
+	}
+	if len(loaders) == 0 {
+		return nil, nil
+	}
+	return cache.NewMetaCacheWithLoader(cache.ChainLoader(loaders...), 10000, config.GlobalMonitor), nil
+}
+
+// newFileSource 根据全局配置创建离线文件数据源
+func newFileSource(cfg *models.GlobalConfig) *source.FileSource {
+	fileSource := source.NewFileSource(cfg.Source)
+	// 设置时间范围过滤
+	if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
+		fileSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
+	}
+	// 服务端类型和 GTID 过滤
+	fileSource.SetFlavor(cfg.Flavor)
+	fileSource.SetGTIDFilter(cfg.IncludeGTIDs, cfg.ExcludeGTIDs)
+	// 跟踪模式
+	fileSource.SetFollow(cfg.Follow)
+	return fileSource
+}
+
+// newMySQLSource 根据全局配置创建在线数据源
+func newMySQLSource(cfg *models.GlobalConfig) *source.MySQLSource {
+	mysqlSource := source.NewMySQLSource(cfg.DBConnection)
+	// 设置时间范围过滤
+	if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
+		mysqlSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
+	}
+	// 服务端类型、GTID 起点和过滤
+	mysqlSource.SetFlavor(cfg.Flavor)
+	mysqlSource.SetStartGTIDSet(cfg.StartGTIDSet)
+	mysqlSource.SetGTIDFilter(cfg.IncludeGTIDs, cfg.ExcludeGTIDs)
+	// 断线重连
+	mysqlSource.SetReconnect(cfg.ReconnectAttempts, cfg.ReconnectInterval)
+	return mysqlSource
+}
+
 // CommandHelper 提供命令的公共功能
 type CommandHelper struct {
 	metaCache *cache.MetaCache
 }
 
-// NewCommandHelper 创建命令助手
-func NewCommandHelper(dbConnection string) *CommandHelper {
-	var metaCache *cache.MetaCache
-	if dbConnection != "" {
-		if db, err := sql.Open("mysql", dbConnection); err == nil {
-			metaCache = cache.NewMetaCache(db, 10000, config.GlobalMonitor)
-		}
+// NewCommandHelper 创建命令助手，列名来自 schemaFile（--schema-file）和 dbConnection 的 INFORMATION_SCHEMA
+func NewCommandHelper(dbConnection, schemaFile string) (*CommandHelper, error) {
+	metaCache, err := newMetaCache(dbConnection, schemaFile)
+	if err != nil {
+		return nil, err
 	}
 	return &CommandHelper{
 		metaCache: metaCache,
+	}, nil
+}
+
+// NewSQLGenerator 创建 SQL 生成器，列类型（无符号、ENUM/SET、BIT、二进制等）来自与列名映射相同的 MetaCache
+func (ch *CommandHelper) NewSQLGenerator() *util.SQLGenerator {
+	sqlGenerator := util.NewSQLGenerator(config.GlobalMonitor)
+	if ch.metaCache != nil {
+		sqlGenerator.SetMetaLoader(ch.metaCache.GetTableMeta)
 	}
+	return sqlGenerator
+}
+
+// PrepareEvent 映射列名并生成事件的 SQL（写入 event.SQL），QUERY 等非行事件只映射列名
+// 处理器在 Prepare 中调用，由 worker 并行执行
+func (ch *CommandHelper) PrepareEvent(event *models.Event, sqlGenerator *util.SQLGenerator) {
+	ch.MapColumnNames(event)
+	switch event.Action {
+	case "INSERT", "UPDATE", "DELETE":
+		event.SQL = generateSQL(event, sqlGenerator)
+	}
+}
+
+// generateSQL 生成行事件的前向 SQL，非行事件返回空字符串
+func generateSQL(event *models.Event, sqlGenerator *util.SQLGenerator) string {
+	switch event.Action {
+	case "INSERT":
+		return sqlGenerator.GenerateInsertSQL(event)
+	case "UPDATE":
+		return sqlGenerator.GenerateUpdateSQL(event)
+	case "DELETE":
+		return sqlGenerator.GenerateDeleteSQL(event)
+	}
+	return ""
 }
 
 // MapColumnNames 将事件中的列占位符映射到实际列名
+// binlog_row_metadata=FULL 时数据源已经从 TABLE_MAP 事件中取得列名和字符集，只有仍为 col_N 的列才查询 MetaCache，
+// 同时按 MetaCache 中的字符集将数据源未转码的非 UTF-8 字符列转码（MINIMAL 元数据也带有字符集，列名仍为 col_N）
 func (ch *CommandHelper) MapColumnNames(event *models.Event) {
 	if ch.metaCache == nil || (event.AfterValues == nil && event.BeforeValues == nil) {
 		return
 	}
+	if !hasColumnPlaceholders(event.AfterValues) && !hasColumnPlaceholders(event.BeforeValues) {
+		return
+	}
 
-	columnNames := ch.getColumnNameMapping(event.Database, event.Table)
+	columnNames, meta := ch.getColumnNameMapping(event.Database, event.Table)
 	if columnNames == nil {
 		return
 	}
 
 	if event.AfterValues != nil {
-		event.AfterValues = mapColNamesToValues(event.AfterValues, columnNames)
+		event.AfterValues = decodeColumnStrings(mapColNamesToValues(event.AfterValues, columnNames), meta, event.DecodedColumns)
 	}
 	if event.BeforeValues != nil {
-		event.BeforeValues = mapColNamesToValues(event.BeforeValues, columnNames)
+		event.BeforeValues = decodeColumnStrings(mapColNamesToValues(event.BeforeValues, columnNames), meta, event.DecodedColumns)
+	}
+	event.Columns = mapColNamesToList(event.Columns, columnNames)
+	if event.PrimaryKey == nil {
+		event.PrimaryKey = meta.PrimaryKey
 	}
 }
 
-// getColumnNameMapping 获取表的列名映射（col_N -> 实际列名）
-func (ch *CommandHelper) getColumnNameMapping(database, table string) map[string]string {
+// hasColumnPlaceholders 检查是否还有未映射的 col_N 列
+func hasColumnPlaceholders(values map[string]interface{}) bool {
+	for key := range values {
+		if strings.HasPrefix(key, "col_") {
+			return true
+		}
+	}
+	return false
+}
+
+// getColumnNameMapping 获取表的列名映射（col_N -> 实际列名）和表元数据
+func (ch *CommandHelper) getColumnNameMapping(database, table string) (map[string]string, *models.TableMeta) {
 	if ch.metaCache == nil {
-		return nil
+		return nil, nil
 	}
 
 	meta, err := ch.metaCache.GetTableMeta(database, table)
 	if err != nil || meta == nil {
-		return nil
+		return nil, nil
 	}
 
 	columnNames := make(map[string]string)
 	for i, col := range meta.Columns {
 		columnNames[fmt.Sprintf("col_%d", i)] = col.Name
 	}
-	return columnNames
+	return columnNames, meta
+}
+
+// decodeColumnStrings 将非 UTF-8 字符集的字符列转码为 UTF-8 字符串，TEXT 列的 []byte 也转换为字符串
+// decoded 中的列已由数据源转码，跳过以免重复转码
+func decodeColumnStrings(values map[string]interface{}, meta *models.TableMeta, decoded []int) map[string]interface{} {
+	skip := make(map[int]bool, len(decoded))
+	for _, idx := range decoded {
+		skip[idx] = true
+	}
+	for i, col := range meta.Columns {
+		if col.Charset == "" || col.Charset == "binary" || skip[i] {
+			continue
+		}
+		switch val := values[col.Name].(type) {
+		case string:
+			values[col.Name], _ = util.DecodeString(col.Charset, []byte(val))
+		case []byte:
+			values[col.Name], _ = util.DecodeString(col.Charset, val)
+		}
+	}
+	return values
+}
+
+// mapColNamesToList 将列名列表中的 col_N 映射到实际列名，返回新的列表（同一行事件拆分出的事件共用列名列表）
+func mapColNamesToList(columns []string, columnNames map[string]string) []string {
+	if columns == nil || columnNames == nil {
+		return columns
+	}
+
+	result := make([]string, len(columns))
+	for i, column := range columns {
+		if realName, ok := columnNames[column]; ok {
+			column = realName
+		}
+		result[i] = column
+	}
+	return result
 }
 
 // mapColNamesToValues 将 col_N 映射到实际列名
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/cache"
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+func TestMapColumnNamesDecodesOnce(t *testing.T) {
+	meta := &models.TableMeta{Columns: []models.ColumnMeta{
+		{Name: "id", Type: "int"},
+		{Name: "name", Type: "varchar(32)", Charset: "latin1"},
+	}}
+	metaCache := cache.NewMetaCacheWithLoader(func(schema, table string) (*models.TableMeta, error) {
+		return meta, nil
+	}, 10, nil)
+	helper := &CommandHelper{metaCache: metaCache}
+
+	// 数据源没有字符集元数据：列值为原始的 latin1 字节，由命令层转码
+	raw := &models.Event{
+		Database:    "app",
+		Table:       "t",
+		Action:      "INSERT",
+		AfterValues: map[string]interface{}{"col_0": int32(1), "col_1": string([]byte{'c', 'a', 'f', 0xe9})},
+	}
+	// binlog_row_metadata=MINIMAL：数据源已按排序规则转码，列名仍为 col_N
+	decoded := &models.Event{
+		Database:       "app",
+		Table:          "t",
+		Action:         "INSERT",
+		AfterValues:    map[string]interface{}{"col_0": int32(1), "col_1": "café"},
+		DecodedColumns: []int{1},
+	}
+
+	want := map[string]interface{}{"id": int32(1), "name": "café"}
+	for name, event := range map[string]*models.Event{"raw": raw, "decoded": decoded} {
+		helper.MapColumnNames(event)
+		if !reflect.DeepEqual(event.AfterValues, want) {
+			t.Errorf("%s: got %#v, want %#v", name, event.AfterValues, want)
+		}
+	}
+}
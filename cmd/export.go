@@ -14,7 +14,6 @@ import (
 	"github.com/aitoooooo/binlogx/pkg/filter"
 	"github.com/aitoooooo/binlogx/pkg/models"
 	"github.com/aitoooooo/binlogx/pkg/processor"
-	"github.com/aitoooooo/binlogx/pkg/source"
 	"github.com/aitoooooo/binlogx/pkg/util"
 	"github.com/spf13/cobra"
 )
@@ -207,6 +206,14 @@ func NewProgressWrappedHandler(inner processor.EventHandler, tracker *ProgressTr
 	}
 }
 
+// Prepare 交由实际处理器准备事件（实际处理器没有实现 EventPreparer 时不做任何事）
+func (pwh *ProgressWrappedHandler) Prepare(event *models.Event) error {
+	if preparer, ok := pwh.inner.(processor.EventPreparer); ok {
+		return preparer.Prepare(event)
+	}
+	return nil
+}
+
 func (pwh *ProgressWrappedHandler) Handle(event *models.Event) error {
 	// 计数已处理的事件
 	pwh.tracker.AddProcessed(1)
@@ -259,22 +266,7 @@ var exportCmd = &cobra.Command{
 		}
 
 		// 创建数据源
-		var ds source.DataSource
-		if cfg.Source != "" {
-			fileSource := source.NewFileSource(cfg.Source)
-			// 设置时间范围过滤
-			if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
-				fileSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
-			}
-			ds = fileSource
-		} else {
-			mysqlSource := source.NewMySQLSource(cfg.DBConnection)
-			// 设置时间范围过滤
-			if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
-				mysqlSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
-			}
-			ds = mysqlSource
-		}
+		ds := newDataSource(cfg)
 
 		// 打开数据源
 		if err := ds.Open(cmd.Context()); err != nil {
@@ -289,13 +281,19 @@ var exportCmd = &cobra.Command{
 		}
 
 		// 创建命令助手（包含列名缓存和映射功能）
-		helper := NewCommandHelper(cfg.DBConnection)
+		helper, err := NewCommandHelper(cfg.DBConnection, cfg.SchemaFile)
+		if err != nil {
+			return err
+		}
 
 		// 创建进度跟踪器
 		tracker := NewProgressTracker()
 
 		// 如果启用了 --estimate-total，先扫描一遍计算总事件数
 		estimateTotal, _ := cmd.Flags().GetBool("estimate-total")
+		if estimateTotal && cfg.Follow {
+			return fmt.Errorf("--estimate-total cannot be used with --follow")
+		}
 		if estimateTotal {
 			fmt.Fprintf(os.Stderr, "[预扫描] 正在统计总事件数，请稍候...\n")
 
@@ -327,21 +325,7 @@ var exportCmd = &cobra.Command{
 
 			// 重新打开数据源用于实际导出
 			ds.Close()
-			if cfg.Source != "" {
-				fileSource := source.NewFileSource(cfg.Source)
-				// 设置时间范围过滤
-				if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
-					fileSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
-				}
-				ds = fileSource
-			} else {
-				mysqlSource := source.NewMySQLSource(cfg.DBConnection)
-				// 设置时间范围过滤
-				if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
-					mysqlSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
-				}
-				ds = mysqlSource
-			}
+			ds = newDataSource(cfg)
 			if err := ds.Open(cmd.Context()); err != nil {
 				return err
 			}
@@ -399,6 +383,7 @@ var exportCmd = &cobra.Command{
 
 		// 创建处理器
 		proc := processor.NewEventProcessor(ds, rf, cfg.Workers)
+		proc.SetOrdered(cfg.Ordered)
 		proc.AddHandler(exportHandler)
 
 		// 启动处理
@@ -438,7 +423,7 @@ func newCSVExporter(output string, helper *CommandHelper, actions map[string]boo
 		file:         file,
 		writer:       writer,
 		helper:       helper,
-		sqlGenerator: util.NewSQLGenerator(config.GlobalMonitor),
+		sqlGenerator: helper.NewSQLGenerator(),
 		actions:      actions,
 	}
 
@@ -449,22 +434,13 @@ func newCSVExporter(output string, helper *CommandHelper, actions map[string]boo
 	return exporter, nil
 }
 
-func (ce *CSVExporter) Handle(event *models.Event) error {
-	// 映射列名和生成 SQL 在锁外进行（这些是 CPU 密集操作）
-	ce.helper.MapColumnNames(event)
-
-	// 生成 SQL（此时列名已经映射为实际列名）
-	if event.Action != "QUERY" && event.Action != "" {
-		switch event.Action {
-		case "INSERT":
-			event.SQL = ce.sqlGenerator.GenerateInsertSQL(event)
-		case "UPDATE":
-			event.SQL = ce.sqlGenerator.GenerateUpdateSQL(event)
-		case "DELETE":
-			event.SQL = ce.sqlGenerator.GenerateDeleteSQL(event)
-		}
-	}
+// Prepare 映射列名并生成 SQL（CPU 密集操作，由 worker 并行调用）
+func (ce *CSVExporter) Prepare(event *models.Event) error {
+	ce.helper.PrepareEvent(event, ce.sqlGenerator)
+	return nil
+}
 
+func (ce *CSVExporter) Handle(event *models.Event) error {
 	// 过滤：只导出指定的 action（在锁外判断）
 	if !ce.actions[event.Action] {
 		return nil
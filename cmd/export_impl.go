@@ -2,9 +2,7 @@ package cmd
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"github.com/aitoooooo/binlogx/pkg/config"
 	"sync"
 
 	"github.com/aitoooooo/binlogx/pkg/models"
@@ -73,7 +71,7 @@ func newSQLiteExporter(output string, helper *CommandHelper, actions map[string]
 		path:         path,
 		db:           db,
 		helper:       helper,
-		sqlGenerator: util.NewSQLGenerator(config.GlobalMonitor),
+		sqlGenerator: helper.NewSQLGenerator(),
 		actions:      actions,
 		shardLock:    util.NewShardedLock(16), // 16 个分片，通常足够
 		batches:      make(map[int][]*models.Event),
@@ -81,22 +79,13 @@ func newSQLiteExporter(output string, helper *CommandHelper, actions map[string]
 	}, nil
 }
 
-func (se *SQLiteExporter) Handle(event *models.Event) error {
-	// 映射列名和生成 SQL 在锁外进行（这些是 CPU 密集操作）
-	se.helper.MapColumnNames(event)
-
-	// 生成 SQL（此时列名已经映射为实际列名）
-	if event.Action != "QUERY" && event.Action != "" {
-		switch event.Action {
-		case "INSERT":
-			event.SQL = se.sqlGenerator.GenerateInsertSQL(event)
-		case "UPDATE":
-			event.SQL = se.sqlGenerator.GenerateUpdateSQL(event)
-		case "DELETE":
-			event.SQL = se.sqlGenerator.GenerateDeleteSQL(event)
-		}
-	}
+// Prepare 映射列名并生成 SQL（CPU 密集操作，由 worker 并行调用）
+func (se *SQLiteExporter) Prepare(event *models.Event) error {
+	se.helper.PrepareEvent(event, se.sqlGenerator)
+	return nil
+}
 
+func (se *SQLiteExporter) Handle(event *models.Event) error {
 	// 过滤：只导出指定的 action（在锁外判断）
 	if !se.actions[event.Action] {
 		return nil
@@ -160,8 +149,9 @@ func (se *SQLiteExporter) flushBatchDirect(batch []*models.Event) error {
 	defer stmt.Close()
 
 	for _, event := range batch {
-		beforeJSON, _ := json.Marshal(event.BeforeValues)
-		afterJSON, _ := json.Marshal(event.AfterValues)
+		// 按表定义的列序编码，而不是 map 键的字母序
+		beforeJSON, _ := event.MarshalValues(event.BeforeValues)
+		afterJSON, _ := event.MarshalValues(event.AfterValues)
 
 		if _, err := stmt.Exec(
 			event.Timestamp,
@@ -232,27 +222,18 @@ func newH2Exporter(output string, helper *CommandHelper, actions map[string]bool
 	return &H2Exporter{
 		path:         output,
 		helper:       helper,
-		sqlGenerator: util.NewSQLGenerator(config.GlobalMonitor),
+		sqlGenerator: helper.NewSQLGenerator(),
 		actions:      actions,
 	}, nil
 }
 
-func (he *H2Exporter) Handle(event *models.Event) error {
-	// 映射列名和生成 SQL 在锁外进行（这些是 CPU 密集操作）
-	he.helper.MapColumnNames(event)
-
-	// 生成 SQL（此时列名已经映射为实际列名）
-	if event.Action != "QUERY" && event.Action != "" {
-		switch event.Action {
-		case "INSERT":
-			event.SQL = he.sqlGenerator.GenerateInsertSQL(event)
-		case "UPDATE":
-			event.SQL = he.sqlGenerator.GenerateUpdateSQL(event)
-		case "DELETE":
-			event.SQL = he.sqlGenerator.GenerateDeleteSQL(event)
-		}
-	}
+// Prepare 映射列名并生成 SQL（CPU 密集操作，由 worker 并行调用）
+func (he *H2Exporter) Prepare(event *models.Event) error {
+	he.helper.PrepareEvent(event, he.sqlGenerator)
+	return nil
+}
 
+func (he *H2Exporter) Handle(event *models.Event) error {
 	// 过滤：只导出指定的 action（在锁外判断）
 	if !he.actions[event.Action] {
 		return nil
@@ -284,27 +265,18 @@ func newHiveExporter(output string, helper *CommandHelper, actions map[string]bo
 	return &HiveExporter{
 		path:         output,
 		helper:       helper,
-		sqlGenerator: util.NewSQLGenerator(config.GlobalMonitor),
+		sqlGenerator: helper.NewSQLGenerator(),
 		actions:      actions,
 	}, nil
 }
 
-func (he *HiveExporter) Handle(event *models.Event) error {
-	// 映射列名和生成 SQL 在锁外进行（这些是 CPU 密集操作）
-	he.helper.MapColumnNames(event)
-
-	// 生成 SQL（此时列名已经映射为实际列名）
-	if event.Action != "QUERY" && event.Action != "" {
-		switch event.Action {
-		case "INSERT":
-			event.SQL = he.sqlGenerator.GenerateInsertSQL(event)
-		case "UPDATE":
-			event.SQL = he.sqlGenerator.GenerateUpdateSQL(event)
-		case "DELETE":
-			event.SQL = he.sqlGenerator.GenerateDeleteSQL(event)
-		}
-	}
+// Prepare 映射列名并生成 SQL（CPU 密集操作，由 worker 并行调用）
+func (he *HiveExporter) Prepare(event *models.Event) error {
+	he.helper.PrepareEvent(event, he.sqlGenerator)
+	return nil
+}
 
+func (he *HiveExporter) Handle(event *models.Event) error {
 	// 过滤：只导出指定的 action（在锁外判断）
 	if !he.actions[event.Action] {
 		return nil
@@ -336,27 +308,18 @@ func newESExporter(output string, helper *CommandHelper, actions map[string]bool
 	return &ESExporter{
 		endpoint:     output,
 		helper:       helper,
-		sqlGenerator: util.NewSQLGenerator(config.GlobalMonitor),
+		sqlGenerator: helper.NewSQLGenerator(),
 		actions:      actions,
 	}, nil
 }
 
-func (ee *ESExporter) Handle(event *models.Event) error {
-	// 映射列名和生成 SQL 在锁外进行（这些是 CPU 密集操作）
-	ee.helper.MapColumnNames(event)
-
-	// 生成 SQL（此时列名已经映射为实际列名）
-	if event.Action != "QUERY" && event.Action != "" {
-		switch event.Action {
-		case "INSERT":
-			event.SQL = ee.sqlGenerator.GenerateInsertSQL(event)
-		case "UPDATE":
-			event.SQL = ee.sqlGenerator.GenerateUpdateSQL(event)
-		case "DELETE":
-			event.SQL = ee.sqlGenerator.GenerateDeleteSQL(event)
-		}
-	}
+// Prepare 映射列名并生成 SQL（CPU 密集操作，由 worker 并行调用）
+func (ee *ESExporter) Prepare(event *models.Event) error {
+	ee.helper.PrepareEvent(event, ee.sqlGenerator)
+	return nil
+}
 
+func (ee *ESExporter) Handle(event *models.Event) error {
 	// 过滤：只导出指定的 action（在锁外判断）
 	if !ee.actions[event.Action] {
 		return nil
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+func TestSQLiteExporterKeepsColumnOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.db")
+	exporter, err := newSQLiteExporter(path, &CommandHelper{}, map[string]bool{"UPDATE": true}, 10)
+	if err != nil {
+		t.Fatalf("create exporter: %v", err)
+	}
+
+	event := &models.Event{
+		Database:     "shop",
+		Table:        "orders",
+		Action:       "UPDATE",
+		Columns:      []string{"id", "status", "amount", "created_at"},
+		BeforeValues: map[string]interface{}{"id": 1, "status": "new", "amount": "9.90", "created_at": "2024-01-01"},
+		AfterValues:  map[string]interface{}{"id": 1, "status": "paid", "amount": "9.90", "created_at": "2024-01-01", "extra": true},
+	}
+	if err := exporter.Handle(event); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+	if err := exporter.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var before, after string
+	if err := db.QueryRow("SELECT before_values, after_values FROM binlog_events").Scan(&before, &after); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+
+	// 按表定义的列序输出，不在列定义中的列排在最后
+	if want := `{"id":1,"status":"new","amount":"9.90","created_at":"2024-01-01"}`; before != want {
+		t.Errorf("before_values:\n got  %s\n want %s", before, want)
+	}
+	if want := `{"id":1,"status":"paid","amount":"9.90","created_at":"2024-01-01","extra":true}`; after != want {
+		t.Errorf("after_values:\n got  %s\n want %s", after, want)
+	}
+}
@@ -41,19 +41,13 @@ var parseCmd = &cobra.Command{
 		// ========== 参数验证 ==========
 		var validationErrors []string
 
-		// 验证1: --start-log-file 只能用于在线 MySQL 数据源
-		if startLogFile != "" && cfg.Source != "" && cfg.DBConnection == "" {
-			validationErrors = append(validationErrors,
-				"❌ --start-log-file 只能与 --db-connection 一起使用，不能用于 --source（离线文件）")
-		}
-
-		// 验证2: 如果指定了 start-log-pos，必须同时指定 start-log-file
+		// 验证1: 如果指定了 start-log-pos，必须同时指定 start-log-file
 		if startLogPos > 0 && startLogFile == "" {
 			validationErrors = append(validationErrors,
 				"❌ 指定了 --start-log-pos 时必须同时指定 --start-log-file")
 		}
 
-		// 验证3: binlog 位置必须 >= 4（binlog 文件头占用前 4 字节）
+		// 验证2: binlog 位置必须 >= 4（binlog 文件头占用前 4 字节）
 		if startLogPos > 0 && startLogPos < 4 {
 			validationErrors = append(validationErrors,
 				"❌ --start-log-pos 必须 >= 4（binlog 文件头占用前 4 字节）")
@@ -80,6 +74,7 @@ var parseCmd = &cobra.Command{
 			fmt.Fprintf(os.Stderr, "  • 从指定文件开始:     binlogx parse --db-connection='...' --start-log-file=mysql-bin.000002\n")
 			fmt.Fprintf(os.Stderr, "  • 从指定位置开始:     binlogx parse --db-connection='...' --start-log-file=mysql-bin.000001 --start-log-pos=4\n")
 			fmt.Fprintf(os.Stderr, "  • 查看离线文件:       binlogx parse --source=/path/to/binlog\n")
+			fmt.Fprintf(os.Stderr, "  • 离线目录指定起点:   binlogx parse --source=/var/lib/mysql/ --start-log-file=mysql-bin.000120\n")
 			fmt.Fprintf(os.Stderr, "  • 离线文件+列名映射:  binlogx parse --source=/path/to/binlog --db-connection='user:pass@tcp(host:port)/'\n\n")
 			return fmt.Errorf("参数验证失败")
 		}
@@ -92,7 +87,7 @@ var parseCmd = &cobra.Command{
 		var startPos uint32
 
 		if cfg.Source != "" {
-			ds = source.NewFileSource(cfg.Source)
+			ds = newFileSource(cfg)
 			sourceType = "file"
 
 			// 离线文件模式的断点续看逻辑
@@ -136,7 +131,7 @@ var parseCmd = &cobra.Command{
 				ds.(*source.FileSource).SetStartPosition(startFile, startPos)
 			}
 		} else {
-			mysqlSource := source.NewMySQLSource(cfg.DBConnection)
+			mysqlSource := newMySQLSource(cfg)
 			ds = mysqlSource
 			sourceType = "mysql"
 
@@ -186,6 +181,9 @@ var parseCmd = &cobra.Command{
 			}
 		}
 
+		// 表结构历史
+		ds = withSchemaHistory(ds, cfg, dbConnectionForHelper)
+
 		// 打开数据源
 		if err := ds.Open(cmd.Context()); err != nil {
 			return err
@@ -199,18 +197,22 @@ var parseCmd = &cobra.Command{
 		}
 
 		// 创建命令助手（包含列名缓存和映射功能）
-		helper := NewCommandHelper(dbConnectionForHelper)
+		helper, err := NewCommandHelper(dbConnectionForHelper, cfg.SchemaFile)
+		if err != nil {
+			return err
+		}
 
 		// 创建流式处理器 - 立即输出事件，不缓存
 		eventChan := make(chan *models.Event, 100)
 		parser := &streamParseHandler{
-			sqlGenerator: util.NewSQLGenerator(config.GlobalMonitor),
+			sqlGenerator: helper.NewSQLGenerator(),
 			helper:       helper,
 			eventChan:    eventChan,
 		}
 
 		// 创建处理器
 		proc := processor.NewEventProcessor(ds, rf, cfg.Workers)
+		proc.SetOrdered(cfg.Ordered)
 		proc.AddHandler(parser)
 
 		// 在单独的 goroutine 中启动处理
@@ -252,26 +254,17 @@ type streamParseHandler struct {
 	count        int
 }
 
+// Prepare 映射列名并生成 SQL（由 worker 并行调用）
+// 重要：先映射列名，再生成 SQL，这样 AfterValues 和 BeforeValues 中的 col_N 会被替换为实际列名
+func (ph *streamParseHandler) Prepare(event *models.Event) error {
+	ph.helper.PrepareEvent(event, ph.sqlGenerator)
+	return nil
+}
+
 func (ph *streamParseHandler) Handle(event *models.Event) error {
 	ph.mu.Lock()
 	defer ph.mu.Unlock()
 
-	// 重要：先映射列名，再生成 SQL
-	// 这样 AfterValues 和 BeforeValues 中的 col_N 会被替换为实际列名
-	ph.helper.MapColumnNames(event)
-
-	// 生成 SQL（此时 AfterValues 已经有实际列名了）
-	if event.Action != "QUERY" {
-		switch event.Action {
-		case "INSERT":
-			event.SQL = ph.sqlGenerator.GenerateInsertSQL(event)
-		case "UPDATE":
-			event.SQL = ph.sqlGenerator.GenerateUpdateSQL(event)
-		case "DELETE":
-			event.SQL = ph.sqlGenerator.GenerateDeleteSQL(event)
-		}
-	}
-
 	ph.count++
 
 	// 立即发送到输出通道
@@ -371,7 +364,7 @@ func fflush() {
 }
 
 func init() {
-	// 添加 parse 命令的局部参数（仅适用于在线 MySQL 数据源）
-	parseCmd.Flags().String("start-log-file", "", "起始 binlog 文件名（例如 mysql-bin.000001，仅用于 --db-connection）")
-	parseCmd.Flags().Uint32("start-log-pos", 0, "起始 binlog 位置（仅用于 --db-connection）")
+	// 添加 parse 命令的局部参数（断点续看的起始位置）
+	parseCmd.Flags().String("start-log-file", "", "起始 binlog 文件名（例如 mysql-bin.000001），--source 为目录、通配符或索引文件时跳过之前的文件")
+	parseCmd.Flags().Uint32("start-log-pos", 0, "起始 binlog 位置（需与 --start-log-file 一起使用）")
 }
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
-	"sync"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/aitoooooo/binlogx/pkg/config"
 	"github.com/aitoooooo/binlogx/pkg/filter"
@@ -25,24 +28,16 @@ var rollbackSqlCmd = &cobra.Command{
 		}
 
 		bulk, _ := cmd.Flags().GetBool("bulk")
+		allColumns, _ := cmd.Flags().GetBool("all-columns")
+		flashback, _ := cmd.Flags().GetString("flashback")
+		compact, _ := cmd.Flags().GetBool("compact")
+		includeUncommitted, _ := cmd.Flags().GetBool("include-uncommitted")
+		if compact && flashback != "" {
+			return fmt.Errorf("--compact cannot be used with --flashback")
+		}
 
 		// 创建数据源
-		var ds source.DataSource
-		if cfg.Source != "" {
-			fileSource := source.NewFileSource(cfg.Source)
-			// 设置时间范围过滤
-			if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
-				fileSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
-			}
-			ds = fileSource
-		} else {
-			mysqlSource := source.NewMySQLSource(cfg.DBConnection)
-			// 设置时间范围过滤
-			if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
-				mysqlSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
-			}
-			ds = mysqlSource
-		}
+		ds := newDataSource(cfg)
 
 		// 打开数据源
 		if err := ds.Open(cmd.Context()); err != nil {
@@ -56,20 +51,40 @@ var rollbackSqlCmd = &cobra.Command{
 			return err
 		}
 
-		// 创建命令助手（包含列名缓存和映射功能）
-		helper := NewCommandHelper(cfg.DBConnection)
-
-		// 处理器
-		rollbackHandler := &rollbackSqlHandler{
-			bulk:         bulk,
-			buffer:       make([]string, 0),
-			sqlGenerator: util.NewSQLGenerator(config.GlobalMonitor),
-			helper:       helper,
-		}
-
 		// 创建处理器
 		proc := processor.NewEventProcessor(ds, rf, cfg.Workers)
-		proc.AddHandler(rollbackHandler)
+
+		if flashback != "" {
+			// 输出反转后的二进制 binlog，不需要列名和 SQL 生成
+			handler := &flashbackHandler{
+				path:               flashback,
+				includeUncommitted: includeUncommitted,
+				spool:              util.NewReverseSpool(rollbackSpoolMemory),
+			}
+			defer handler.spool.Close()
+			proc.AddTransactionHandler(handler)
+		} else {
+			// 创建命令助手（包含列名缓存和映射功能）
+			helper, err := NewCommandHelper(cfg.DBConnection, cfg.SchemaFile)
+			if err != nil {
+				return err
+			}
+
+			// 处理器：按事务接收事件，回滚 SQL 按事务倒序输出
+			rollbackHandler := newRollbackSqlHandler(helper.NewSQLGenerator(), helper, bulk, includeUncommitted)
+			rollbackHandler.sqlGenerator.SetChangedColumnsOnly(!allColumns)
+			defer rollbackHandler.spool.Close()
+			if compact {
+				rollbackHandler.compactor = util.NewRowCompactor(compactMemory, rollbackHandler.sqlGenerator.PrimaryKey)
+				defer rollbackHandler.compactor.Close()
+				defer func() {
+					if rollbackHandler.pending != nil {
+						rollbackHandler.pending.Close()
+					}
+				}()
+			}
+			proc.AddTransactionHandler(rollbackHandler)
+		}
 
 		// 启动处理
 		if err := proc.Start(); err != nil {
@@ -81,80 +96,339 @@ var rollbackSqlCmd = &cobra.Command{
 	},
 }
 
+// rollbackSpoolMemory 回滚 SQL 在内存中缓存的上限，超过后写入临时文件
+const rollbackSpoolMemory = 64 << 20
+
+// rollbackBulkRows --bulk 时一个多行 INSERT 最多包含的行数
+const rollbackBulkRows = 1000
+
+// rollbackSqlHandler 回滚 SQL 处理器
+// 行事件读到时就生成回滚 SQL 写入倒序缓冲（不在内存中保存整个事务），事务结束时补上 BEGIN 和注释，
+// 读取结束后倒序输出：事务按倒序排列（最新的事务在前），事务中的回滚 SQL 按行事件的倒序排列并用 BEGIN/COMMIT 包裹
+// --compact 时不按事务分组，读取结束后按净变化的倒序输出每行的回滚 SQL
+// --bulk 时连续的回滚 INSERT（DELETE 的回滚）按表合并为多行 INSERT
+// 读取范围内没有提交的事务默认跳过（--include-uncommitted 时包含）
 type rollbackSqlHandler struct {
-	bulk         bool
-	buffer       []string
-	sqlGenerator *util.SQLGenerator
-	helper       *CommandHelper
-	mu           sync.Mutex
-}
+	bulk               bool
+	includeUncommitted bool
+	spool              *util.ReverseSpool
+	compactor          *util.RowCompactor // --compact 时合并同一行的多次修改
+	pending            *util.RowCompactor // --compact 时当前事务中的修改，事务提交后再合并到 compactor
+	statements         int                // 回滚 SQL 的总数
+	sqlGenerator       *util.SQLGenerator
+	helper             *CommandHelper
+	skipped            int // 没有修改任何列而跳过的 UPDATE 数
+	incomplete         int // 前镜像不完整、无法回滚的事件数
+	uncommitted        int // 没有提交而跳过的事务数
 
-func (rsh *rollbackSqlHandler) Handle(event *models.Event) error {
-	rsh.mu.Lock()
-	defer rsh.mu.Unlock()
+	txMark       int      // 当前事务在 spool 中的第一个数据块，-1 表示当前事务还没有行事件
+	txStatements int      // 当前事务（或 --compact 输出时）已经写入 spool 的回滚 SQL 数
+	bulkHead     string   // --bulk 时正在合并的 INSERT 语句头
+	bulkRows     []string // --bulk 时正在合并的 VALUES，按 binlog 顺序排列
+}
 
-	// QUERY 事件不处理
-	if event.Action == "QUERY" {
-		return nil
+// newRollbackSqlHandler 创建回滚 SQL 处理器
+func newRollbackSqlHandler(sqlGenerator *util.SQLGenerator, helper *CommandHelper, bulk, includeUncommitted bool) *rollbackSqlHandler {
+	return &rollbackSqlHandler{
+		bulk:               bulk,
+		includeUncommitted: includeUncommitted,
+		spool:              util.NewReverseSpool(rollbackSpoolMemory),
+		sqlGenerator:       sqlGenerator,
+		helper:             helper,
+		txMark:             -1,
 	}
+}
 
+// HandleRow 生成事务中一个行事件的回滚 SQL（事务处理器按 binlog 顺序调用，不需要加锁）
+// 事务的第一个行事件之前先写入 COMMIT，倒序输出后位于事务的最后
+func (rsh *rollbackSqlHandler) HandleRow(event *models.Event) error {
 	// 映射列名：将 col_N 替换为实际列名
 	rsh.helper.MapColumnNames(event)
 
-	// 生成回滚 SQL
-	sql := generateRollbackSQL(event, rsh.sqlGenerator)
-	if sql == "" {
+	if rsh.compactor != nil {
+		if rsh.pending == nil {
+			rsh.pending = util.NewRowCompactor(compactMemory, rsh.sqlGenerator.PrimaryKey)
+		}
+		return rsh.pending.Add(event)
+	}
+
+	if rsh.txMark < 0 {
+		rsh.txMark = rsh.spool.Len()
+		if err := rsh.spool.Append([]byte("COMMIT;\n")); err != nil {
+			return err
+		}
+	}
+	return rsh.appendRollback(event)
+}
+
+// HandleTransaction 结束一个事务：写入 BEGIN 和事务注释；没有提交或没有回滚 SQL 的事务从 spool 中删除
+func (rsh *rollbackSqlHandler) HandleTransaction(tx *models.Transaction) error {
+	if rsh.compactor != nil {
+		return rsh.commitPending(tx)
+	}
+	if rsh.txMark < 0 {
+		return nil
+	}
+	if err := rsh.flushBulk(); err != nil {
+		return err
+	}
+	mark, statements := rsh.txMark, rsh.txStatements
+	rsh.txMark, rsh.txStatements = -1, 0
+
+	if skipUncommitted(tx, rsh.includeUncommitted) {
+		rsh.uncommitted++
+		return rsh.spool.Truncate(mark)
+	}
+	if statements == 0 {
+		return rsh.spool.Truncate(mark)
+	}
+	rsh.statements += statements
+	return rsh.spool.Append([]byte(transactionComment(tx) + "\nBEGIN;\n"))
+}
+
+// commitPending --compact 时将当前事务的修改合并到 compactor，没有提交的事务直接丢弃
+func (rsh *rollbackSqlHandler) commitPending(tx *models.Transaction) error {
+	pending := rsh.pending
+	rsh.pending = nil
+	if pending == nil {
+		return nil
+	}
+	defer pending.Close()
+
+	if skipUncommitted(tx, rsh.includeUncommitted) {
+		rsh.uncommitted++
 		return nil
 	}
+	// 事务内的净变化再与之前事务的净变化合并，结果与逐个加入行事件相同
+	return pending.Each(func(event *models.Event, changes int) error {
+		return rsh.compactor.Add(event)
+	})
+}
 
+// appendRollback 将一个事件的回滚 SQL 追加到 spool，--bulk 时 DELETE 的回滚先合并到多行 INSERT 中
+func (rsh *rollbackSqlHandler) appendRollback(event *models.Event) error {
 	if rsh.bulk {
-		rsh.buffer = append(rsh.buffer, sql)
-	} else {
-		fmt.Println(sql + ";")
+		if head, row := rsh.sqlGenerator.GenerateRollbackInsertRow(event); head != "" {
+			if head != rsh.bulkHead || len(rsh.bulkRows) >= rollbackBulkRows {
+				if err := rsh.flushBulk(); err != nil {
+					return err
+				}
+				rsh.bulkHead = head
+			}
+			rsh.bulkRows = append(rsh.bulkRows, row)
+			return nil
+		}
+	}
+
+	sql := rsh.rollbackStatement(event)
+	if sql == "" {
+		return nil
+	}
+	if err := rsh.flushBulk(); err != nil {
+		return err
+	}
+	rsh.txStatements++
+	return rsh.spool.Append([]byte(sql + ";\n"))
+}
+
+// flushBulk 将正在合并的行写成一个多行 INSERT，VALUES 按倒序排列，与逐条输出时的顺序一致
+func (rsh *rollbackSqlHandler) flushBulk() error {
+	if len(rsh.bulkRows) == 0 {
+		return nil
 	}
+	var b strings.Builder
+	b.WriteString(rsh.bulkHead)
+	b.WriteString(" VALUES\n")
+	for i := len(rsh.bulkRows) - 1; i >= 0; i-- {
+		b.WriteString(rsh.bulkRows[i])
+		if i > 0 {
+			b.WriteString(",\n")
+		}
+	}
+	b.WriteString(";\n")
+	rsh.bulkRows = rsh.bulkRows[:0]
+	rsh.txStatements++
+	return rsh.spool.Append([]byte(b.String()))
+}
+
+// rollbackStatement 生成一个事件的回滚 SQL，无法回滚或不需要回滚时返回空字符串并计数
+func (rsh *rollbackSqlHandler) rollbackStatement(event *models.Event) string {
+	sql := generateRollbackSQL(event, rsh.sqlGenerator)
+	if sql == "" {
+		if missing := rsh.sqlGenerator.MissingRollbackColumns(event); len(missing) > 0 {
+			// binlog_row_image=MINIMAL/NOBLOB 时前镜像缺少列，生成的 SQL 会丢失数据
+			rsh.incomplete++
+			fmt.Fprintf(os.Stderr, "[警告] %s %s.%s (%s:%d) 前镜像缺少列 %s，无法生成回滚 SQL\n",
+				event.Action, event.Database, event.Table, event.LogName, event.LogPos, strings.Join(missing, ", "))
+		} else if rsh.sqlGenerator.IsNoopUpdate(event) {
+			rsh.skipped++
+		}
+	}
+	return sql
+}
 
+// compactedRollback 将每行净变化的回滚 SQL 追加到倒序缓冲，最后修改的行最先输出
+func (rsh *rollbackSqlHandler) compactedRollback() error {
+	err := rsh.compactor.Each(func(event *models.Event, changes int) error {
+		return rsh.appendRollback(event)
+	})
+	if err != nil {
+		return err
+	}
+	if err := rsh.flushBulk(); err != nil {
+		return err
+	}
+	rsh.statements += rsh.txStatements
+	rsh.txStatements = 0
 	return nil
 }
 
 func (rsh *rollbackSqlHandler) Flush() error {
-	rsh.mu.Lock()
-	defer rsh.mu.Unlock()
+	out := bufio.NewWriter(os.Stdout)
+	if err := rsh.writeTo(out); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write rollback SQL: %w", err)
+	}
+
+	if rsh.skipped > 0 {
+		fmt.Fprintf(os.Stderr, "[跳过] %d 个没有修改任何列的 UPDATE\n", rsh.skipped)
+	}
+	if rsh.incomplete > 0 {
+		fmt.Fprintf(os.Stderr, "[警告] %d 个事件的前镜像不完整（binlog_row_image 不是 FULL），没有生成回滚 SQL\n", rsh.incomplete)
+	}
+	if rsh.uncommitted > 0 {
+		fmt.Fprintf(os.Stderr, "[跳过] %d 个在读取范围内没有提交的事务（--include-uncommitted 包含这些事务）\n", rsh.uncommitted)
+	}
+	return nil
+}
 
-	if rsh.bulk && len(rsh.buffer) > 0 {
-		fmt.Printf("-- Bulk rollback: %d statements\n", len(rsh.buffer))
-		for _, sql := range rsh.buffer {
-			fmt.Println(sql + ";")
+// writeTo 将回滚 SQL 按倒序写入 out
+func (rsh *rollbackSqlHandler) writeTo(out io.Writer) error {
+	if rsh.compactor != nil {
+		if err := rsh.compactedRollback(); err != nil {
+			return err
 		}
+		fmt.Fprintf(out, "-- Compacted rollback: %d statements, net change per row (not grouped by transaction)\n", rsh.statements)
+	}
+	if rsh.bulk && rsh.statements > 0 {
+		fmt.Fprintf(out, "-- Bulk rollback: %d statements, consecutive INSERTs into the same table are merged\n", rsh.statements)
+	}
+	if _, err := rsh.spool.WriteTo(out); err != nil {
+		return fmt.Errorf("failed to write rollback SQL: %w", err)
+	}
+	return nil
+}
+
+// skipUncommitted 判断是否跳过读取范围内没有提交的事务（范围截断或事务仍在进行），跳过时在 stderr 输出警告
+func skipUncommitted(tx *models.Transaction, include bool) bool {
+	if tx.Committed() || include {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "[警告] 事务 %s:%d 在读取范围内没有提交，已跳过\n", tx.LogName, tx.BeginPos)
+	return true
+}
+
+// transactionComment 生成事务块的注释行：GTID、binlog 位置和提交时间
+func transactionComment(tx *models.Transaction) string {
+	var parts []string
+	if tx.GTID != "" {
+		parts = append(parts, "GTID "+tx.GTID)
+	}
+	if tx.Committed() {
+		parts = append(parts, fmt.Sprintf("%s:%d-%d", tx.LogName, tx.BeginPos, tx.CommitPos),
+			"committed at "+tx.CommitTime.Format("2006-01-02 15:04:05"))
+	} else {
+		parts = append(parts, fmt.Sprintf("%s:%d", tx.LogName, tx.BeginPos), "not committed in range")
+	}
+	return "-- Transaction " + strings.Join(parts, ", ")
+}
+
+// flashbackHandler flashback binlog 处理器
+// 每个事务的行事件反转后（WRITE 与 DELETE 互换，UPDATE 交换前后镜像）按倒序排列，读取结束后按事务倒序写入 binlog 文件
+// 与 rollbackSqlHandler 相同，读取范围内没有提交的事务默认跳过
+type flashbackHandler struct {
+	path               string
+	includeUncommitted bool
+	spool              *util.ReverseSpool
+	xid                uint64 // 已生成的事务数，作为 XID_EVENT 的事务 ID
+	skipped            int    // 无法反转而跳过的行事件数
+	uncommitted        int    // 没有提交而跳过的事务数
+}
+
+// HandleTransaction 生成一个事务的 flashback 事件（事务处理器按 binlog 顺序调用，不需要加锁）
+func (fh *flashbackHandler) HandleTransaction(tx *models.Transaction) error {
+	if skipUncommitted(tx, fh.includeUncommitted) {
+		fh.uncommitted++
+		return nil
+	}
+	block := source.FlashbackTransaction(tx, fh.xid+1, func(event *models.Event, err error) {
+		fh.skipped++
+		fmt.Fprintf(os.Stderr, "[警告] %s %s.%s (%s:%d) 无法反转: %v\n",
+			event.Action, event.Database, event.Table, event.LogName, event.LogPos, err)
+	})
+	if block == nil {
+		return nil
+	}
+	fh.xid++
+	if err := fh.spool.Append(block); err != nil {
+		return fmt.Errorf("failed to write flashback binlog: %w", err)
+	}
+	return nil
+}
+
+// Flush 将所有事务按倒序写入 binlog 文件（先写临时文件再重命名）
+func (fh *flashbackHandler) Flush() error {
+	tmp := fh.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create flashback binlog: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer file.Close()
+
+	out := bufio.NewWriterSize(file, 1<<20)
+	writer, err := source.NewFlashbackWriter(out)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.spool.WriteTo(writer); err != nil {
+		return fmt.Errorf("failed to write flashback binlog: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write flashback binlog: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write flashback binlog: %w", err)
+	}
+	if err := os.Rename(tmp, fh.path); err != nil {
+		return fmt.Errorf("failed to write flashback binlog: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "[完成] %d 个事务已写入 %s\n", fh.xid, fh.path)
+	if fh.skipped > 0 {
+		fmt.Fprintf(os.Stderr, "[警告] %d 个行事件无法反转，没有写入 flashback binlog\n", fh.skipped)
+	}
+	if fh.uncommitted > 0 {
+		fmt.Fprintf(os.Stderr, "[跳过] %d 个在读取范围内没有提交的事务（--include-uncommitted 包含这些事务）\n", fh.uncommitted)
 	}
 	return nil
 }
 
 // generateRollbackSQL 生成回滚 SQL
 func generateRollbackSQL(event *models.Event, sqlGenerator *util.SQLGenerator) string {
-	switch event.Action {
-	case "INSERT":
-		// INSERT 的回滚是 DELETE
-		return sqlGenerator.GenerateDeleteSQL(event)
-	case "UPDATE":
-		// UPDATE 的回滚是 UPDATE（使用 BeforeValues）
-		// 创建临时事件用于生成回滚 SQL
-		rollbackEvent := &models.Event{
-			Database: event.Database,
-			Table:    event.Table,
-			Action:   "UPDATE",
-			// 将 BeforeValues 和 AfterValues 互换
-			BeforeValues: event.AfterValues,
-			AfterValues:  event.BeforeValues,
-		}
-		return sqlGenerator.GenerateUpdateSQL(rollbackEvent)
-	case "DELETE":
-		// DELETE 的回滚是 INSERT
-		return sqlGenerator.GenerateInsertSQL(event)
-	default:
-		return ""
-	}
+	// INSERT 回滚为 DELETE，UPDATE 回滚为前后镜像互换的 UPDATE，DELETE 回滚为 INSERT
+	return sqlGenerator.GenerateRollbackSQL(event)
 }
 
 func init() {
-	rollbackSqlCmd.Flags().BoolP("bulk", "b", false, "合并为批量 SQL，默认 false")
+	rollbackSqlCmd.Flags().BoolP("bulk", "b", false, "将连续的回滚 INSERT（DELETE 的回滚）按表合并为多行 INSERT，默认 false")
+	rollbackSqlCmd.Flags().Bool("all-columns", false, "UPDATE 的 SET 包含前镜像的所有列（默认只包含修改过的列）")
+	rollbackSqlCmd.Flags().Bool("compact", false, "按表和主键合并同一行的多次修改，只回滚读取范围内的净变化")
+	rollbackSqlCmd.Flags().Bool("include-uncommitted", false, "包含读取范围内没有提交的事务（默认跳过）")
+	rollbackSqlCmd.Flags().String("flashback", "", "输出反转后的二进制 binlog 文件（类似 mysqlbinlog --flashback），不输出 SQL")
 }
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/aitoooooo/binlogx/pkg/util"
+)
+
+// rollbackRow 构造 app.t 的行事件，t 的主键为 id
+func rollbackRow(action string, before, after map[string]interface{}) *models.Event {
+	return &models.Event{
+		Database:     "app",
+		Table:        "t",
+		Action:       action,
+		PrimaryKey:   []string{"id"},
+		Columns:      []string{"id", "name"},
+		BeforeValues: before,
+		AfterValues:  after,
+	}
+}
+
+func row(id int, name string) map[string]interface{} {
+	return map[string]interface{}{"id": id, "name": name}
+}
+
+// rollbackTx 构造事务，commitPos 为 0 表示没有提交
+func rollbackTx(gtid string, beginPos, commitPos uint32) *models.Transaction {
+	return &models.Transaction{
+		GTID:       gtid,
+		LogName:    "mysql-bin.000001",
+		BeginPos:   beginPos,
+		CommitPos:  commitPos,
+		CommitTime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local),
+	}
+}
+
+// runRollback 按流式处理的顺序把每个事务的行事件和事务交给处理器，返回输出的回滚 SQL
+func runRollback(t *testing.T, handler *rollbackSqlHandler, txs []*models.Transaction, rows [][]*models.Event) string {
+	t.Helper()
+	defer handler.spool.Close()
+	for i, tx := range txs {
+		for _, event := range rows[i] {
+			if err := handler.HandleRow(event); err != nil {
+				t.Fatalf("HandleRow: %v", err)
+			}
+		}
+		if err := handler.HandleTransaction(tx); err != nil {
+			t.Fatalf("HandleTransaction: %v", err)
+		}
+	}
+	var out bytes.Buffer
+	if err := handler.writeTo(&out); err != nil {
+		t.Fatalf("writeTo: %v", err)
+	}
+	return out.String()
+}
+
+func TestRollbackSqlHandlerStreamsTransactions(t *testing.T) {
+	txs := []*models.Transaction{
+		rollbackTx("uuid:1", 100, 300),
+		rollbackTx("uuid:2", 300, 500),
+		rollbackTx("uuid:3", 500, 0),
+	}
+	rows := [][]*models.Event{
+		{rollbackRow("INSERT", nil, row(1, "a")), rollbackRow("UPDATE", row(1, "a"), row(1, "b"))},
+		{rollbackRow("DELETE", row(2, "x"), nil), rollbackRow("DELETE", row(3, "y"), nil), rollbackRow("INSERT", nil, row(4, "z"))},
+		{rollbackRow("INSERT", nil, row(5, "w"))},
+	}
+
+	tests := []struct {
+		name string
+		bulk bool
+		want string
+	}{
+		{
+			name: "one statement per row",
+			want: "-- Transaction GTID uuid:2, mysql-bin.000001:300-500, committed at 2024-05-01 12:00:00\n" +
+				"BEGIN;\n" +
+				"DELETE FROM `app`.`t` WHERE `id`=4 LIMIT 1;\n" +
+				"INSERT INTO `app`.`t` (`id`, `name`) VALUES (3, 'y');\n" +
+				"INSERT INTO `app`.`t` (`id`, `name`) VALUES (2, 'x');\n" +
+				"COMMIT;\n" +
+				"-- Transaction GTID uuid:1, mysql-bin.000001:100-300, committed at 2024-05-01 12:00:00\n" +
+				"BEGIN;\n" +
+				"UPDATE `app`.`t` SET `name`='a' WHERE `id`=1 LIMIT 1;\n" +
+				"DELETE FROM `app`.`t` WHERE `id`=1 LIMIT 1;\n" +
+				"COMMIT;\n",
+		},
+		{
+			name: "bulk merges rollback INSERTs",
+			bulk: true,
+			want: "-- Bulk rollback: 4 statements, consecutive INSERTs into the same table are merged\n" +
+				"-- Transaction GTID uuid:2, mysql-bin.000001:300-500, committed at 2024-05-01 12:00:00\n" +
+				"BEGIN;\n" +
+				"DELETE FROM `app`.`t` WHERE `id`=4 LIMIT 1;\n" +
+				"INSERT INTO `app`.`t` (`id`, `name`) VALUES\n(3, 'y'),\n(2, 'x');\n" +
+				"COMMIT;\n" +
+				"-- Transaction GTID uuid:1, mysql-bin.000001:100-300, committed at 2024-05-01 12:00:00\n" +
+				"BEGIN;\n" +
+				"UPDATE `app`.`t` SET `name`='a' WHERE `id`=1 LIMIT 1;\n" +
+				"DELETE FROM `app`.`t` WHERE `id`=1 LIMIT 1;\n" +
+				"COMMIT;\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := newRollbackSqlHandler(util.NewSQLGenerator(nil), &CommandHelper{}, test.bulk, false)
+			handler.sqlGenerator.SetChangedColumnsOnly(true)
+			// 没有提交的事务已经写入 spool 的部分被删除
+			if got := runRollback(t, handler, txs, rows); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+			if handler.uncommitted != 1 {
+				t.Errorf("expected 1 uncommitted transaction, got %d", handler.uncommitted)
+			}
+		})
+	}
+}
+
+func TestRollbackSqlHandlerCompactSkipsUncommitted(t *testing.T) {
+	handler := newRollbackSqlHandler(util.NewSQLGenerator(nil), &CommandHelper{}, false, false)
+	handler.sqlGenerator.SetChangedColumnsOnly(true)
+	handler.compactor = util.NewRowCompactor(compactMemory, handler.sqlGenerator.PrimaryKey)
+	defer handler.compactor.Close()
+
+	txs := []*models.Transaction{rollbackTx("uuid:1", 100, 300), rollbackTx("uuid:2", 300, 0), rollbackTx("uuid:3", 500, 700)}
+	rows := [][]*models.Event{
+		{rollbackRow("UPDATE", row(1, "a"), row(1, "b"))},
+		{rollbackRow("UPDATE", row(1, "b"), row(1, "c")), rollbackRow("INSERT", nil, row(2, "x"))},
+		{rollbackRow("UPDATE", row(1, "b"), row(1, "d")), rollbackRow("INSERT", nil, row(3, "y")), rollbackRow("DELETE", row(3, "y"), nil)},
+	}
+
+	// 没有提交的事务不参与合并；同一事务中插入后又删除的行没有净变化
+	want := "-- Compacted rollback: 1 statements, net change per row (not grouped by transaction)\n" +
+		"UPDATE `app`.`t` SET `name`='a' WHERE `id`=1 LIMIT 1;\n"
+	if got := runRollback(t, handler, txs, rows); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if handler.pending != nil {
+		t.Error("pending rows were not released")
+	}
+}
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"github.com/aitoooooo/binlogx/pkg/config"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -17,7 +20,15 @@ statistics, parsing, SQL generation, rollback SQL, and multi-format export.`,
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// 收到 Ctrl+C / SIGTERM 时取消 context，数据源停止读取后正常输出结果
+	// 之后再次收到信号则按默认行为直接退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -33,5 +44,7 @@ func init() {
 	rootCmd.AddCommand(sqlCmd)
 	rootCmd.AddCommand(rollbackSqlCmd)
 	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(fetchCmd)
+	rootCmd.AddCommand(schemaCmd)
 	rootCmd.AddCommand(versionCmd)
 }
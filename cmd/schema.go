@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aitoooooo/binlogx/pkg/config"
+	"github.com/aitoooooo/binlogx/pkg/filter"
+	"github.com/aitoooooo/binlogx/pkg/schema"
+	"github.com/aitoooooo/binlogx/pkg/source"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Manage table metadata used to name binlog columns",
+}
+
+var schemaSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save table metadata from INFORMATION_SCHEMA to a file",
+	Long: `Read columns, types, nullability, defaults, primary/unique keys and enum/set values
+of all matched tables in one pass and write them to a JSON file. The file can be passed to
+--schema-file later to analyze binlogs without querying the database again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.InitConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if cfg.DBConnection == "" {
+			return fmt.Errorf("schema snapshot requires --db-connection")
+		}
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			return fmt.Errorf("--output is required")
+		}
+
+		routeFilter, err := filter.NewRouteFilter(cfg.SchemaTableRegex)
+		if err != nil {
+			return err
+		}
+		db, err := source.OpenDB(cfg.DBConnection)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		catalog, err := schema.TakeSnapshot(db, routeFilter.MatchTable)
+		if err != nil {
+			return err
+		}
+		if catalog.Len() == 0 {
+			return fmt.Errorf("no tables matched")
+		}
+		if err := catalog.SaveSnapshot(output); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "[完成] 共 %d 张表的表结构已保存到 %s\n", catalog.Len(), output)
+		return nil
+	},
+}
+
+func init() {
+	schemaSnapshotCmd.Flags().StringP("output", "o", "", "快照文件路径 (必填)")
+	schemaCmd.AddCommand(schemaSnapshotCmd)
+}
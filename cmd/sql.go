@@ -2,13 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"sync"
 
 	"github.com/aitoooooo/binlogx/pkg/config"
 	"github.com/aitoooooo/binlogx/pkg/filter"
 	"github.com/aitoooooo/binlogx/pkg/models"
 	"github.com/aitoooooo/binlogx/pkg/processor"
-	"github.com/aitoooooo/binlogx/pkg/source"
 	"github.com/aitoooooo/binlogx/pkg/util"
 	"github.com/spf13/cobra"
 )
@@ -25,22 +25,7 @@ var sqlCmd = &cobra.Command{
 		}
 
 		// 创建数据源
-		var ds source.DataSource
-		if cfg.Source != "" {
-			fileSource := source.NewFileSource(cfg.Source)
-			// 设置时间范围过滤
-			if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
-				fileSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
-			}
-			ds = fileSource
-		} else {
-			mysqlSource := source.NewMySQLSource(cfg.DBConnection)
-			// 设置时间范围过滤
-			if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
-				mysqlSource.SetTimeRange(cfg.StartTime, cfg.EndTime)
-			}
-			ds = mysqlSource
-		}
+		ds := newDataSource(cfg)
 
 		// 打开数据源
 		if err := ds.Open(cmd.Context()); err != nil {
@@ -55,17 +40,29 @@ var sqlCmd = &cobra.Command{
 		}
 
 		// 创建命令助手（包含列名缓存和映射功能）
-		helper := NewCommandHelper(cfg.DBConnection)
+		helper, err := NewCommandHelper(cfg.DBConnection, cfg.SchemaFile)
+		if err != nil {
+			return err
+		}
 
 		// 处理器
 		sqlHandler := &sqlHandler{
-			sqlGenerator: util.NewSQLGenerator(config.GlobalMonitor),
+			sqlGenerator: helper.NewSQLGenerator(),
 			helper:       helper,
 		}
+		allColumns, _ := cmd.Flags().GetBool("all-columns")
+		sqlHandler.sqlGenerator.SetChangedColumnsOnly(!allColumns)
+		if compact, _ := cmd.Flags().GetBool("compact"); compact {
+			sqlHandler.compactor = util.NewRowCompactor(compactMemory, sqlHandler.sqlGenerator.PrimaryKey)
+			defer sqlHandler.compactor.Close()
+		}
 
 		// 创建处理器
+		// SQL 脚本必须按 binlog 顺序执行，始终使用有序模式
 		proc := processor.NewEventProcessor(ds, rf, cfg.Workers)
+		proc.SetOrdered(true)
 		proc.AddHandler(sqlHandler)
+		sqlHandler.stop = proc.Stop
 
 		// 启动处理
 		if err := proc.Start(); err != nil {
@@ -77,50 +74,109 @@ var sqlCmd = &cobra.Command{
 	},
 }
 
+// compactMemory --compact 在内存中保存的行数据上限（估计值），超过后写入临时文件
+const compactMemory = 64 << 20
+
 type sqlHandler struct {
 	sqlGenerator *util.SQLGenerator
 	helper       *CommandHelper
+	compactor    *util.RowCompactor // --compact 时合并同一行的多次修改，读取结束后输出净变化
 	mu           sync.Mutex
 	count        int
-}
+	skipped      int // 没有修改任何列而跳过的 UPDATE 数
 
-func (sh *sqlHandler) Handle(event *models.Event) error {
-	sh.mu.Lock()
-	defer sh.mu.Unlock()
+	// --compact 时写入临时文件失败后净变化已经不完整：记录第一个错误并停止处理，由 Flush 返回
+	compactErr error
+	stop       func()
+}
 
-	// QUERY 事件不处理
+// Prepare 映射列名并生成 SQL（由 worker 并行调用）
+// 重要：先映射列名，再生成 SQL，这样生成的 SQL 中列名是真实的，而不是 col_N
+func (sh *sqlHandler) Prepare(event *models.Event) error {
 	if event.Action == "QUERY" {
 		return nil
 	}
+	sh.helper.PrepareEvent(event, sh.sqlGenerator)
+	return nil
+}
 
-	// 重要：先映射列名，再生成 SQL
-	// 这样生成的 SQL 中列名是真实的，而不是 col_N
-	sh.helper.MapColumnNames(event)
+func (sh *sqlHandler) Handle(event *models.Event) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
 
-	// 生成 SQL（此时列名已经映射为实际列名）
-	var sql string
+	// 只输出行事件
 	switch event.Action {
-	case "INSERT":
-		sql = sh.sqlGenerator.GenerateInsertSQL(event)
-	case "UPDATE":
-		sql = sh.sqlGenerator.GenerateUpdateSQL(event)
-	case "DELETE":
-		sql = sh.sqlGenerator.GenerateDeleteSQL(event)
+	case "INSERT", "UPDATE", "DELETE":
 	default:
 		return nil
 	}
+	if sh.compactor != nil {
+		if sh.compactErr != nil {
+			return nil
+		}
+		if err := sh.compactor.Add(event); err != nil {
+			sh.compactErr = err
+			if sh.stop != nil {
+				sh.stop()
+			}
+			return err
+		}
+		return nil
+	}
+	sh.writeStatement(event, event.SQL, 1)
+	return nil
+}
+
+// writeStatement 输出一条 SQL 及标记事件信息的注释，changes 大于 1 时为合并后的净变化
+func (sh *sqlHandler) writeStatement(event *models.Event, sql string, changes int) {
+	if sql == "" && sh.sqlGenerator.IsNoopUpdate(event) {
+		sh.skipped++
+		fmt.Printf("-- Skipped no-op UPDATE at %s (LogPos: %d), Database: %s, Table: %s\n",
+			event.Timestamp.Format("2006-01-02 15:04:05"), event.LogPos, event.Database, event.Table)
+		return
+	}
 
 	if sql != "" {
 		// 输出注释标记事件信息
 		fmt.Printf("-- %s at %s (LogPos: %d)\n",
 			event.Action, event.Timestamp.Format("2006-01-02 15:04:05"), event.LogPos)
+		if changes > 1 {
+			fmt.Printf("-- Compacted from %d row changes\n", changes)
+		}
+		if event.GTID != "" {
+			fmt.Printf("-- GTID: %s\n", event.GTID)
+		}
 		fmt.Printf("-- Database: %s, Table: %s\n", event.Database, event.Table)
 		fmt.Println(sql + ";")
 		sh.count++
 	}
-	return nil
 }
 
 func (sh *sqlHandler) Flush() error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sh.compactErr != nil {
+		return fmt.Errorf("failed to compact rows: %w", sh.compactErr)
+	}
+	if sh.compactor != nil {
+		// 输出每行的净变化，位置和时间为该行最后一次修改
+		err := sh.compactor.Each(func(event *models.Event, changes int) error {
+			sh.writeStatement(event, generateSQL(event, sh.sqlGenerator), changes)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if sh.skipped > 0 {
+		fmt.Fprintf(os.Stderr, "[跳过] %d 个没有修改任何列的 UPDATE\n", sh.skipped)
+	}
 	return nil
 }
+
+func init() {
+	sqlCmd.Flags().Bool("all-columns", false, "UPDATE 的 SET 包含后镜像的所有列（默认只包含修改过的列）")
+	sqlCmd.Flags().Bool("compact", false, "按表和主键合并同一行的多次修改，只输出读取范围内的净变化")
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/util"
+)
+
+func TestSQLHandlerStopsOnCompactError(t *testing.T) {
+	// 临时目录不存在，第一次溢出就会失败
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	gen := util.NewSQLGenerator(nil)
+	stopped := 0
+	handler := &sqlHandler{
+		sqlGenerator: gen,
+		helper:       &CommandHelper{},
+		compactor:    util.NewRowCompactor(0, gen.PrimaryKey),
+		stop:         func() { stopped++ },
+	}
+	defer handler.compactor.Close()
+
+	if err := handler.Handle(rollbackRow("INSERT", nil, row(1, "a"))); err == nil {
+		t.Fatal("expected compact error")
+	}
+	if err := handler.Handle(rollbackRow("INSERT", nil, row(2, "b"))); err != nil {
+		t.Errorf("expected later events to be ignored, got %v", err)
+	}
+	if stopped != 1 {
+		t.Errorf("expected processing to be stopped once, got %d", stopped)
+	}
+	// Flush 返回错误，不输出不完整的净变化
+	if err := handler.Flush(); err == nil || !strings.Contains(err.Error(), "failed to compact rows") {
+		t.Errorf("unexpected Flush error %v", err)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/aitoooooo/binlogx/pkg/filter"
 	"github.com/aitoooooo/binlogx/pkg/models"
 	"github.com/aitoooooo/binlogx/pkg/processor"
-	"github.com/aitoooooo/binlogx/pkg/source"
 	"github.com/spf13/cobra"
 )
 
@@ -27,12 +26,7 @@ var statCmd = &cobra.Command{
 		top, _ := cmd.Flags().GetInt("top")
 
 		// 创建数据源
-		var ds source.DataSource
-		if cfg.Source != "" {
-			ds = source.NewFileSource(cfg.Source)
-		} else {
-			ds = source.NewMySQLSource(cfg.DBConnection)
-		}
+		ds := newDataSource(cfg)
 
 		// 打开数据源
 		if err := ds.Open(cmd.Context()); err != nil {
@@ -91,7 +85,22 @@ func (sh *statHandler) Handle(event *models.Event) error {
 	sh.result.DatabaseDist[event.Database]++
 	tableKey := event.Database + "." + event.Table
 	sh.result.TableDist[tableKey]++
-	sh.result.ActionDist[event.Action]++
+	// 事务边界事件计入事件总数，不计入动作分布
+	if !event.IsTransactionBoundary() {
+		sh.result.ActionDist[event.Action]++
+	}
+
+	// 压缩事务（TRANSACTION_PAYLOAD_EVENT），内部事件会单独计入上面的分布
+	if event.CompressedSize > 0 {
+		sh.result.CompressedTransactions++
+		sh.result.CompressedSize += int64(event.CompressedSize)
+		sh.result.UncompressedSize += int64(event.UncompressedSize)
+	}
+
+	// 多行事件拆分出的各行共享同一个 RawData，只在第一行统计事件大小
+	if event.RowIndex > 0 {
+		return nil
+	}
 
 	// 检查是否是大事件
 	eventSize := int64(len(event.RawData))
@@ -137,6 +146,17 @@ func printStatResult(result *models.StatResult, top int) {
 		fmt.Println("\nLarge Event Distribution:")
 		printDist(result.LargeEventDist, top)
 	}
+
+	// 事务压缩统计
+	if result.CompressedTransactions > 0 {
+		fmt.Printf("\n=== Transaction Compression ===\n")
+		fmt.Printf("Compressed Transactions: %d\n", result.CompressedTransactions)
+		fmt.Printf("Compressed Size: %d bytes\n", result.CompressedSize)
+		fmt.Printf("Uncompressed Size: %d bytes\n", result.UncompressedSize)
+		if result.CompressedSize > 0 {
+			fmt.Printf("Compression Ratio: %.2f\n", float64(result.UncompressedSize)/float64(result.CompressedSize))
+		}
+	}
 }
 
 func printDist(dist map[string]int64, top int) {
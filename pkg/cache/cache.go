@@ -5,11 +5,13 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aitoooooo/binlogx/pkg/models"
 	"github.com/aitoooooo/binlogx/pkg/monitor"
+	"github.com/aitoooooo/binlogx/pkg/util"
 	"golang.org/x/sync/singleflight"
 )
 
@@ -24,6 +26,10 @@ func (tnc *TableNotFoundCache) IsExpired() bool {
 	return time.Since(tnc.timestamp) > tnc.ttl
 }
 
+// TableMetaLoader 加载表元数据，MetaCache 在缓存未命中时调用
+// 可以是 INFORMATION_SCHEMA 查询、schema 文件或快照文件
+type TableMetaLoader func(schema, table string) (*models.TableMeta, error)
+
 // MetaCache 表元数据缓存
 type MetaCache struct {
 	mu               sync.RWMutex
@@ -31,7 +37,7 @@ type MetaCache struct {
 	notFoundCache    map[string]*TableNotFoundCache // 表不存在的缓存，TTL=1分钟
 	maxSize          int
 	notFoundCacheTTL time.Duration
-	db               *sql.DB
+	loader           TableMetaLoader    // 缓存未命中时的加载方式，为 nil 时回退到 col_N
 	monitor          *monitor.Monitor   // 用于性能监控
 	sf               singleflight.Group // 用于防止并发查询同一个表
 	ctx              context.Context
@@ -39,8 +45,17 @@ type MetaCache struct {
 	cleanupTicker    *time.Ticker // 定期清理过期缓存
 }
 
-// NewMetaCache 创建缓存
+// NewMetaCache 创建缓存，从数据库的 INFORMATION_SCHEMA 加载表元数据
 func NewMetaCache(db *sql.DB, maxSize int, m *monitor.Monitor) *MetaCache {
+	var loader TableMetaLoader
+	if db != nil {
+		loader = DBLoader(db)
+	}
+	return NewMetaCacheWithLoader(loader, maxSize, m)
+}
+
+// NewMetaCacheWithLoader 使用指定的加载方式创建缓存
+func NewMetaCacheWithLoader(loader TableMetaLoader, maxSize int, m *monitor.Monitor) *MetaCache {
 	if maxSize <= 0 {
 		maxSize = 10000
 	}
@@ -50,7 +65,7 @@ func NewMetaCache(db *sql.DB, maxSize int, m *monitor.Monitor) *MetaCache {
 		notFoundCache:    make(map[string]*TableNotFoundCache),
 		maxSize:          maxSize,
 		notFoundCacheTTL: 1 * time.Minute, // 表不存在缓存1分钟
-		db:               db,
+		loader:           loader,
 		monitor:          m,
 		ctx:              ctx,
 		cancel:           cancel,
@@ -70,8 +85,8 @@ func (mc *MetaCache) GetTableMeta(schema, table string) (*models.TableMeta, erro
 		}
 	}()
 
-	if mc.db == nil {
-		// 无数据库连接，回退到默认列名
+	if mc.loader == nil {
+		// 无数据库连接也没有 schema 文件，回退到默认列名
 		return nil, fmt.Errorf("no database connection available")
 	}
 
@@ -104,8 +119,8 @@ func (mc *MetaCache) GetTableMeta(schema, table string) (*models.TableMeta, erro
 		}
 		mc.mu.RUnlock()
 
-		// 从数据库查询
-		meta, err := mc.queryTableMeta(schema, table)
+		// 从数据库（或 schema 文件）加载
+		meta, err := mc.loader(schema, table)
 		if err != nil {
 			// 表不存在，添加到不存在缓存，1 分钟内不再查询
 			mc.mu.Lock()
@@ -140,16 +155,37 @@ func (mc *MetaCache) GetTableMeta(schema, table string) (*models.TableMeta, erro
 	return result.(*models.TableMeta), nil
 }
 
+// DBLoader 返回从数据库 INFORMATION_SCHEMA 查询表元数据的加载方式
+func DBLoader(db *sql.DB) TableMetaLoader {
+	return func(schema, table string) (*models.TableMeta, error) {
+		return queryTableMeta(db, schema, table)
+	}
+}
+
+// ChainLoader 依次尝试多个加载方式，返回第一个成功的结果
+func ChainLoader(loaders ...TableMetaLoader) TableMetaLoader {
+	return func(schema, table string) (*models.TableMeta, error) {
+		err := fmt.Errorf("table %s.%s not found", schema, table)
+		for _, loader := range loaders {
+			var meta *models.TableMeta
+			if meta, err = loader(schema, table); err == nil {
+				return meta, nil
+			}
+		}
+		return nil, err
+	}
+}
+
 // queryTableMeta 从数据库查询表元数据
-func (mc *MetaCache) queryTableMeta(schema, table string) (*models.TableMeta, error) {
+func queryTableMeta(db *sql.DB, schema, table string) (*models.TableMeta, error) {
 	query := `
-		SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT
+		SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, CHARACTER_SET_NAME
 		FROM INFORMATION_SCHEMA.COLUMNS
 		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
 		ORDER BY ORDINAL_POSITION
 	`
 
-	rows, err := mc.db.Query(query, schema, table)
+	rows, err := db.Query(query, schema, table)
 	if err != nil {
 		return nil, err
 	}
@@ -159,15 +195,19 @@ func (mc *MetaCache) queryTableMeta(schema, table string) (*models.TableMeta, er
 	for rows.Next() {
 		var colName, colType, isNullable string
 		var defaultValue interface{}
-		if err := rows.Scan(&colName, &colType, &isNullable, &defaultValue); err != nil {
+		var charset sql.NullString
+		if err := rows.Scan(&colName, &colType, &isNullable, &defaultValue, &charset); err != nil {
 			return nil, err
 		}
 
 		columns = append(columns, models.ColumnMeta{
 			Name:     colName,
 			Type:     colType,
+			Unsigned: strings.Contains(strings.ToLower(colType), "unsigned"),
 			Nullable: isNullable == "YES",
 			Default:  defaultValue,
+			Elements: util.ParseElements(colType),
+			Charset:  charset.String,
 		})
 	}
 
@@ -179,7 +219,62 @@ func (mc *MetaCache) queryTableMeta(schema, table string) (*models.TableMeta, er
 		return nil, fmt.Errorf("table %s.%s not found", schema, table)
 	}
 
-	return &models.TableMeta{Columns: columns}, nil
+	meta := &models.TableMeta{Columns: columns}
+	if err := queryTableKeys(db, schema, table, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// queryTableKeys 查询主键和唯一索引，用于生成按索引定位行的 WHERE 条件
+// 含表达式的函数索引（COLUMN_NAME 为 NULL）无法按列定位行，忽略
+func queryTableKeys(db *sql.DB, schema, table string, meta *models.TableMeta) error {
+	query := `
+		SELECT INDEX_NAME, COLUMN_NAME
+		FROM INFORMATION_SCHEMA.STATISTICS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND NON_UNIQUE = 0
+		ORDER BY INDEX_NAME, SEQ_IN_INDEX
+	`
+
+	rows, err := db.Query(query, schema, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var indexes []string
+	keys := make(map[string][]string)
+	skipped := make(map[string]bool)
+	for rows.Next() {
+		var indexName string
+		var columnName sql.NullString
+		if err := rows.Scan(&indexName, &columnName); err != nil {
+			return err
+		}
+		if !columnName.Valid {
+			skipped[indexName] = true
+			continue
+		}
+		if _, ok := keys[indexName]; !ok {
+			indexes = append(indexes, indexName)
+		}
+		keys[indexName] = append(keys[indexName], columnName.String)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, indexName := range indexes {
+		if skipped[indexName] {
+			continue
+		}
+		if indexName == "PRIMARY" {
+			meta.PrimaryKey = keys[indexName]
+		} else {
+			meta.UniqueKeys = append(meta.UniqueKeys, keys[indexName])
+		}
+	}
+	return nil
 }
 
 // GetColumnName 获取列名，如果失败返回 col_N
@@ -29,6 +29,13 @@ func InitConfig(cmd *cobra.Command) (*models.GlobalConfig, error) {
 	cfg.Source = source
 	cfg.DBConnection = dbConnection
 
+	// 跟踪模式仅适用于离线文件
+	follow, _ := cmd.Flags().GetBool("follow")
+	if follow && source == "" {
+		return nil, fmt.Errorf("--follow requires --source")
+	}
+	cfg.Follow = follow
+
 	// 时间范围
 	startTimeStr, _ := cmd.Flags().GetString("start-time")
 	endTimeStr, _ := cmd.Flags().GetString("end-time")
@@ -49,6 +56,32 @@ func InitConfig(cmd *cobra.Command) (*models.GlobalConfig, error) {
 		cfg.EndTime = t
 	}
 
+	// 服务端类型
+	flavor, _ := cmd.Flags().GetString("flavor")
+	flavor = strings.ToLower(flavor)
+	if flavor != "" && flavor != "mysql" && flavor != "mariadb" {
+		return nil, fmt.Errorf("invalid flavor %q, must be mysql or mariadb", flavor)
+	}
+	cfg.Flavor = flavor
+
+	// GTID
+	cfg.StartGTIDSet, _ = cmd.Flags().GetString("start-gtid-set")
+	if cfg.StartGTIDSet != "" && source != "" {
+		return nil, fmt.Errorf("--start-gtid-set only works with --db-connection, use --include-gtids to filter offline files")
+	}
+	cfg.IncludeGTIDs, _ = cmd.Flags().GetString("include-gtids")
+	cfg.ExcludeGTIDs, _ = cmd.Flags().GetString("exclude-gtids")
+
+	// 在线模式断线重连
+	cfg.ReconnectAttempts, _ = cmd.Flags().GetInt("reconnect-attempts")
+	if reconnectIntervalStr, _ := cmd.Flags().GetString("reconnect-interval"); reconnectIntervalStr != "" {
+		reconnectInterval, err := time.ParseDuration(reconnectIntervalStr)
+		if err != nil || reconnectInterval <= 0 {
+			return nil, fmt.Errorf("invalid reconnect-interval %q, must be a positive duration like 1s", reconnectIntervalStr)
+		}
+		cfg.ReconnectInterval = reconnectInterval
+	}
+
 	// Action 过滤
 	actions, _ := cmd.Flags().GetStringSlice("action")
 	cfg.Action = actions
@@ -67,12 +100,17 @@ func InitConfig(cmd *cobra.Command) (*models.GlobalConfig, error) {
 	// 分库表正则
 	cfg.SchemaTableRegex, _ = cmd.Flags().GetStringSlice("schema-table-regex")
 
+	// 表结构来源
+	cfg.SchemaFile, _ = cmd.Flags().GetString("schema-file")
+	cfg.SchemaHistory, _ = cmd.Flags().GetString("schema-history")
+
 	// Worker 数量
 	workers, _ := cmd.Flags().GetInt("workers")
 	if workers <= 0 {
 		workers = runtime.NumCPU()
 	}
 	cfg.Workers = workers
+	cfg.Ordered, _ = cmd.Flags().GetBool("ordered")
 
 	GlobalConfig = cfg
 
@@ -94,6 +132,9 @@ func printConfig(cfg *models.GlobalConfig) {
 	log.Println("【数据源】")
 	if cfg.Source != "" {
 		log.Printf("  离线文件: %s", cfg.Source)
+		if cfg.Follow {
+			log.Printf("  跟踪模式: 是（Ctrl+C 退出）")
+		}
 	}
 	if cfg.DBConnection != "" {
 		// 隐藏密码
@@ -104,6 +145,22 @@ func printConfig(cfg *models.GlobalConfig) {
 			}
 		}
 		log.Printf("  在线连接: %s", dsn)
+		if cfg.ReconnectAttempts < 0 {
+			log.Printf("  断线重连: 无限次，初始间隔 %s", cfg.ReconnectInterval)
+		} else if cfg.ReconnectAttempts > 0 {
+			log.Printf("  断线重连: 最多 %d 次，初始间隔 %s", cfg.ReconnectAttempts, cfg.ReconnectInterval)
+		} else {
+			log.Println("  断线重连: 关闭")
+		}
+	}
+	if cfg.Flavor != "" {
+		log.Printf("  服务端类型: %s", cfg.Flavor)
+	}
+	if cfg.SchemaFile != "" {
+		log.Printf("  表结构文件: %s", cfg.SchemaFile)
+	}
+	if cfg.SchemaHistory != "" {
+		log.Printf("  表结构历史: %s", cfg.SchemaHistory)
 	}
 
 	// 断点续看
@@ -117,6 +174,20 @@ func printConfig(cfg *models.GlobalConfig) {
 		}
 	}
 
+	// GTID
+	if cfg.StartGTIDSet != "" || cfg.IncludeGTIDs != "" || cfg.ExcludeGTIDs != "" {
+		log.Println("【GTID】")
+		if cfg.StartGTIDSet != "" {
+			log.Printf("  起始集合: %s", cfg.StartGTIDSet)
+		}
+		if cfg.IncludeGTIDs != "" {
+			log.Printf("  包含:     %s", cfg.IncludeGTIDs)
+		}
+		if cfg.ExcludeGTIDs != "" {
+			log.Printf("  排除:     %s", cfg.ExcludeGTIDs)
+		}
+	}
+
 	// 时间范围
 	if !cfg.StartTime.IsZero() || !cfg.EndTime.IsZero() {
 		log.Println("【时间范围】")
@@ -143,6 +214,9 @@ func printConfig(cfg *models.GlobalConfig) {
 	// 性能配置
 	log.Println("【性能配置】")
 	log.Printf("  Worker数量:    %d", cfg.Workers)
+	if cfg.Ordered {
+		log.Printf("  有序输出:      是")
+	}
 	log.Printf("  慢事件阈值:    %s", cfg.SlowThreshold)
 	log.Printf("  大事件阈值:    %d 字节", cfg.EventSizeThreshold)
 
@@ -167,13 +241,23 @@ func printConfig(cfg *models.GlobalConfig) {
 }
 
 func AddGlobalFlags(cmd *cobra.Command) {
-	cmd.PersistentFlags().String("source", "", "离线 binlog 文件路径")
+	cmd.PersistentFlags().String("source", "", "离线 binlog 文件路径，也可以是目录、通配符（如 'mysql-bin.0001*'）或 mysql-bin.index 索引文件，支持 gzip/zstd/xz 压缩归档")
+	cmd.PersistentFlags().Bool("follow", false, "持续跟踪 --source 中最新的 binlog 文件，文件增长时继续读取，轮转后自动切换到下一个文件（类似 tail -f）")
 	cmd.PersistentFlags().String("db-connection", "", "在线 DSN user:pass@tcp(host:port)/dbname?charset=utf8mb4")
 	cmd.PersistentFlags().String("start-time", "", "开始时间 YYYY-MM-DD HH:MM:SS")
 	cmd.PersistentFlags().String("end-time", "", "结束时间 YYYY-MM-DD HH:MM:SS")
+	cmd.PersistentFlags().String("flavor", "", "服务端类型 mysql/mariadb，默认根据服务端版本（离线文件根据文件头）自动识别")
+	cmd.PersistentFlags().String("start-gtid-set", "", "在线模式从该 GTID 集合之后开始同步（已执行的 GTID 集合，如 'uuid:1-100'），仅用于 --db-connection")
+	cmd.PersistentFlags().String("include-gtids", "", "只处理属于该 GTID 集合的事务，如 'uuid:1-100,uuid2:5'")
+	cmd.PersistentFlags().String("exclude-gtids", "", "跳过属于该 GTID 集合的事务")
+	cmd.PersistentFlags().Int("reconnect-attempts", 10, "在线模式连接中断后的最大重连次数，0 表示不重连，-1 表示无限重连")
+	cmd.PersistentFlags().String("reconnect-interval", "1s", "在线模式首次重连的等待时间，之后每次翻倍，最长 1 分钟")
 	cmd.PersistentFlags().StringSlice("action", []string{}, "操作类型过滤 (INSERT,UPDATE,DELETE)")
 	cmd.PersistentFlags().String("slow-threshold", "50ms", "慢事件处理阈值，超过此时间则标记为慢事件（默认 50ms）")
 	cmd.PersistentFlags().Int64("event-size-threshold", 1024, "大事件大小阈值（字节），超过此大小则标记为大事件（默认 1KiB=1024字节）")
 	cmd.PersistentFlags().StringSlice("schema-table-regex", []string{}, "schema.table 的范围匹配表达式(不是正则表达式)。示例 *.my_table 或者 db_[0-3].my_table_[0-9]")
+	cmd.PersistentFlags().String("schema-file", "", "离线表结构文件，内容为 CREATE TABLE 语句（如 mysqldump --no-data 的输出），没有数据库连接时也能映射列名和主键")
+	cmd.PersistentFlags().String("schema-history", "", "表结构历史文件：按 binlog 顺序重放 DDL，行事件按当时的表结构映射列名，文件存在时先加载，结束时保存")
 	cmd.PersistentFlags().Int("workers", 0, "worker 数量，默认 0=CPU 数")
+	cmd.PersistentFlags().Bool("ordered", false, "有序模式：worker 并行映射列名和生成 SQL，输出严格按 binlog 顺序（sql 命令始终有序）")
 }
@@ -49,3 +49,36 @@ func TestInitConfigMissingSource(t *testing.T) {
 		t.Error("Expected error when source and db-connection are missing")
 	}
 }
+
+func TestInitConfigFollowRequiresSource(t *testing.T) {
+	cmd := &cobra.Command{}
+	AddGlobalFlags(cmd)
+	cmd.PersistentFlags().Set("db-connection", "root:pass@tcp(127.0.0.1:3306)/")
+	cmd.PersistentFlags().Set("follow", "true")
+
+	_, err := InitConfig(cmd)
+	if err == nil {
+		t.Error("Expected error when --follow is used without --source")
+	}
+}
+
+func TestInitConfigReconnect(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("db-connection", "root:pass@tcp(127.0.0.1:3306)/", "db-connection")
+	cmd.Flags().String("slow-threshold", "50ms", "slow-threshold")
+	cmd.Flags().Int("reconnect-attempts", -1, "reconnect-attempts")
+	cmd.Flags().String("reconnect-interval", "500ms", "reconnect-interval")
+
+	cfg, err := InitConfig(cmd)
+	if err != nil {
+		t.Fatalf("InitConfig failed: %v", err)
+	}
+	if cfg.ReconnectAttempts != -1 || cfg.ReconnectInterval != 500*time.Millisecond {
+		t.Errorf("Expected reconnect -1/500ms, got %d/%s", cfg.ReconnectAttempts, cfg.ReconnectInterval)
+	}
+
+	cmd.Flags().Set("reconnect-interval", "0s")
+	if _, err := InitConfig(cmd); err == nil {
+		t.Error("Expected error for non-positive reconnect-interval")
+	}
+}
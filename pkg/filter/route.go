@@ -38,6 +38,11 @@ func (rf *RouteFilter) Match(event *models.Event) bool {
 	return true
 }
 
+// MatchTable 检查库表是否匹配过滤条件
+func (rf *RouteFilter) MatchTable(schema, table string) bool {
+	return rf.matchDatabase(schema, table)
+}
+
 // matchDatabase 检查数据库名
 func (rf *RouteFilter) matchDatabase(schema, table string) bool {
 	if len(rf.rangeMatcher) > 0 {
@@ -1,6 +1,12 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,20 +17,204 @@ type Event struct {
 	ServerID     uint32                 `json:"server_id"`
 	LogName      string                 `json:"log_name"` // binlog 文件名
 	LogPos       uint32                 `json:"log_pos"`
+	EventSize    uint32                 `json:"event_size"` // 事件在 binlog 中的字节数，事件起始位置为 LogPos - EventSize
+	GTID         string                 `json:"gtid"`       // 所属事务的 GTID，匿名事务为空
 	Database     string                 `json:"database"`
 	Table        string                 `json:"table"`
-	Action       string                 `json:"action"` // INSERT, UPDATE, DELETE
+	Action       string                 `json:"action"`    // INSERT, UPDATE, DELETE；事务边界为 GTID, BEGIN, COMMIT
+	ThreadID     uint32                 `json:"thread_id"` // 执行语句的连接 ID（QUERY 事件，包括 BEGIN）
+	RowIndex     int                    `json:"row_index"` // 行在所属 RowsEvent 中的序号（多行事件按行拆分）
 	SQL          string                 `json:"sql"`
+	OriginalSQL  string                 `json:"original_sql"` // 行事件对应的原始 SQL（ROWS_QUERY / MariaDB ANNOTATE_ROWS 事件）
 	BeforeValues map[string]interface{} `json:"before_values"`
 	AfterValues  map[string]interface{} `json:"after_values"`
+	PrimaryKey   []string               `json:"primary_key"` // 主键列名（来自 TABLE_MAP 的可选元数据或 MetaCache）
+	Columns      []string               `json:"columns"`     // 表的全部列名，按列序排列（未映射时为 col_N）
 	RawData      []byte                 `json:"-"`
+	RawRows      *RawRowsEvent          `json:"-"` // 行事件的原始字节（多行事件拆分出的事件共享同一个对象）
+
+	// 不在镜像中的列号（对应 Columns，binlog_row_image=MINIMAL/NOBLOB），这些列不在 BeforeValues/AfterValues 中
+	BeforeMissing []int `json:"before_missing,omitempty"`
+	AfterMissing  []int `json:"after_missing,omitempty"`
+
+	// 数据源已按 TABLE_MAP 中的字符集转码为 UTF-8 的字符列号（对应 Columns），命令层不再重复转码
+	DecodedColumns []int `json:"-"`
+
+	// TRANSACTION_PAYLOAD_EVENT 的压缩信息（binlog_transaction_compression=ON）
+	CompressedSize   uint64 `json:"compressed_size"`   // 压缩后的载荷大小（字节）
+	UncompressedSize uint64 `json:"uncompressed_size"` // 解压后的载荷大小（字节）
+}
+
+// RawRowsEvent 行事件及其 TABLE_MAP 事件的原始字节，用于生成 flashback binlog（rollback-sql --flashback）
+type RawRowsEvent struct {
+	TableMap    []byte   // TABLE_MAP 事件（含事件头，可能带 CRC32 校验和）
+	Rows        []byte   // 行事件（含事件头，可能带 CRC32 校验和）
+	RowCount    int      // 行数，UPDATE 为前后镜像的对数
+	ColumnTypes []byte   // 列类型（来自 TABLE_MAP）
+	ColumnMeta  []uint16 // 列元数据（来自 TABLE_MAP）
+}
+
+// Transaction 一个事务（GTID/BEGIN … XID/COMMIT）中的行事件和提交信息
+type Transaction struct {
+	GTID       string    `json:"gtid"`        // 事务的 GTID，匿名事务为空
+	LogName    string    `json:"log_name"`    // 事务所在的 binlog 文件
+	BeginPos   uint32    `json:"begin_pos"`   // 事务第一个事件（GTID 或 BEGIN）的起始位置
+	CommitPos  uint32    `json:"commit_pos"`  // 提交事件（XID/COMMIT）的结束位置，读取范围内没有提交时为 0
+	CommitTime time.Time `json:"commit_time"` // 提交事件的时间
+	ThreadID   uint32    `json:"thread_id"`   // 执行事务的连接 ID（来自 BEGIN 事件）
+	Size       uint64    `json:"size"`        // 事务在 binlog 中占用的字节数
+	Events     []*Event  `json:"events"`      // 事务中的行事件，按 binlog 顺序排列
+}
+
+// Committed 事务是否在读取范围内提交
+func (tx *Transaction) Committed() bool {
+	return tx.CommitPos > 0
+}
+
+// JSONDiff PARTIAL_UPDATE_ROWS_EVENT 中 JSON 列的部分更新（binlog_row_value_options=PARTIAL_JSON），
+// 作为后镜像中该列的值，表示对原值的修改而不是完整的新值
+type JSONDiff struct {
+	Op    string `json:"op"`              // REPLACE / INSERT / REMOVE
+	Path  string `json:"path"`            // JSON 路径，如 $.a[0]
+	Value string `json:"value,omitempty"` // 新值的 JSON 文本，REMOVE 时为空
+}
+
+// JSONText JSON 列的值，为 MySQL 规范形式的 JSON 文本（键按 MySQL 的存储顺序排列）
+// 与普通字符串区分，生成 SQL 时输出为 CAST('...' AS JSON)
+type JSONText string
+
+// Geometry 几何列的值（POINT、POLYGON 等），生成 SQL 时输出为 ST_GeomFromText
+type Geometry struct {
+	SRID uint32 `json:"srid"` // 空间参考系 ID，0 表示未指定
+	WKT  string `json:"wkt"`  // WKT 文本，如 POINT(1 2)
+}
+
+// String 返回 WKT 文本
+func (g Geometry) String() string {
+	return g.WKT
+}
+
+// MissingColumns 返回列号对应的列名
+func (e *Event) MissingColumns(indexes []int) []string {
+	names := make([]string, 0, len(indexes))
+	for _, idx := range indexes {
+		if idx < len(e.Columns) {
+			names = append(names, e.Columns[idx])
+		} else {
+			names = append(names, "col_"+strconv.Itoa(idx))
+		}
+	}
+	return names
+}
+
+// IsTransactionBoundary 是否为事务边界事件（GTID、BEGIN、XID/COMMIT），这类事件没有库表和行数据
+func (e *Event) IsTransactionBoundary() bool {
+	switch e.Action {
+	case "GTID", "BEGIN", "COMMIT":
+		return true
+	}
+	return false
+}
+
+// OrderedColumns 按列序返回 values 中的列名，用于稳定的 SQL 和导出输出
+// 不在 Columns 中的列排在最后：col_N 按列号，其他按名称排序
+func (e *Event) OrderedColumns(values map[string]interface{}) []string {
+	result := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, column := range e.Columns {
+		if _, ok := values[column]; ok && !seen[column] {
+			result = append(result, column)
+			seen[column] = true
+		}
+	}
+	if len(result) == len(values) {
+		return result
+	}
+
+	rest := make([]string, 0, len(values)-len(result))
+	for column := range values {
+		if !seen[column] {
+			rest = append(rest, column)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		ni, iok := placeholderIndex(rest[i])
+		nj, jok := placeholderIndex(rest[j])
+		switch {
+		case iok && jok:
+			return ni < nj
+		case iok != jok:
+			return iok
+		}
+		return rest[i] < rest[j]
+	})
+	return append(result, rest...)
+}
+
+// MarshalValues 将 values 编码为 JSON 对象，键按 OrderedColumns 的列序排列（encoding/json 会按名称排序 map 的键）
+func (e *Event) MarshalValues(values map[string]interface{}) ([]byte, error) {
+	if values == nil {
+		return []byte("null"), nil
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, column := range e.OrderedColumns(values) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(column)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(values[column])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON 编码事件，before_values/after_values 按列序输出
+func (e Event) MarshalJSON() ([]byte, error) {
+	before, err := e.MarshalValues(e.BeforeValues)
+	if err != nil {
+		return nil, err
+	}
+	after, err := e.MarshalValues(e.AfterValues)
+	if err != nil {
+		return nil, err
+	}
+	// event 类型没有 MarshalJSON 方法，外层的同名字段覆盖嵌入结构体中的 map
+	type event Event
+	return json.Marshal(struct {
+		*event
+		BeforeValues json.RawMessage `json:"before_values"`
+		AfterValues  json.RawMessage `json:"after_values"`
+	}{(*event)(&e), before, after})
+}
+
+// placeholderIndex 解析 col_N 占位符中的列号
+func placeholderIndex(column string) (int, bool) {
+	if !strings.HasPrefix(column, "col_") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(column[len("col_"):])
+	return n, err == nil
 }
 
 // GlobalConfig 全局配置
 type GlobalConfig struct {
 	// 数据源（二选一）
 	Source             string        // 离线文件路径
+	Flavor             string        // mysql / mariadb，为空时自动识别
+	Follow             bool          // 持续跟踪离线 binlog 目录（类似 tail -f）
 	DBConnection       string        // 在线 DSN
+	ReconnectAttempts  int           // 在线模式断线后的最大重连次数，0 表示不重连，负数表示无限重连
+	ReconnectInterval  time.Duration // 首次重连等待时间，之后每次翻倍
 	StartTime          time.Time     // 开始时间
 	EndTime            time.Time     // 结束时间
 	Action             []string      // 操作类型过滤
@@ -34,10 +224,20 @@ type GlobalConfig struct {
 	// 断点续看
 	StartLogFile string // 起始 binlog 文件
 	StartLogPos  uint32 // 起始 binlog 位置
+	StartGTIDSet string // 在线模式的起始 GTID 集合（已执行的 GTID）
+
+	// GTID 过滤
+	IncludeGTIDs string // 只处理属于该集合的事务
+	ExcludeGTIDs string // 跳过属于该集合的事务
 
 	// 分库表正则路由
 	SchemaTableRegex []string
-	Workers          int // worker 数量，默认 0=CPU 数
+	Workers          int  // worker 数量，默认 0=CPU 数
+	Ordered          bool // 有序模式：处理器按 binlog 顺序输出
+
+	// 表结构来源
+	SchemaFile    string // 离线表结构文件（CREATE TABLE 语句），用于没有数据库连接时映射列名
+	SchemaHistory string // 表结构历史文件（DDL 重放结果），为空时不启用
 
 	// 命令专属参数
 
@@ -62,19 +262,27 @@ type StatResult struct {
 	LargeEventDist map[string]int64 // 大事件分布（table -> count）
 	MaxEventSize   int64            // 最大事件大小（字节）
 	MaxEventTable  string           // 最大事件对应的表
+
+	// 事务压缩（TRANSACTION_PAYLOAD_EVENT）
+	CompressedTransactions int64 // 压缩事务数
+	CompressedSize         int64 // 压缩后的总大小（字节）
+	UncompressedSize       int64 // 解压后的总大小（字节）
 }
 
 // ColumnMeta 列元数据
 type ColumnMeta struct {
-	Name     string
-	Type     string // INT, VARCHAR, DECIMAL, JSON, etc.
-	Unsigned bool
-	Nullable bool
-	Default  interface{}
+	Name     string      `json:"name"`
+	Type     string      `json:"type"` // INT, VARCHAR, DECIMAL, JSON, etc.
+	Unsigned bool        `json:"unsigned"`
+	Nullable bool        `json:"nullable"`
+	Default  interface{} `json:"default"`
+	Elements []string    `json:"elements,omitempty"` // ENUM/SET 的取值列表
+	Charset  string      `json:"charset,omitempty"`  // 字符列的字符集，非 UTF-8 字符集的值需要转码
 }
 
 // TableMeta 表元数据
 type TableMeta struct {
-	Columns    []ColumnMeta
-	PrimaryKey []string // 主键列名
+	Columns    []ColumnMeta `json:"columns"`
+	PrimaryKey []string     `json:"primary_key"`           // 主键列名
+	UniqueKeys [][]string   `json:"unique_keys,omitempty"` // 唯一索引的列名（不含主键）
 }
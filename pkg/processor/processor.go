@@ -14,6 +14,12 @@ import (
 
 const defaultBufferSize = 10000
 
+// defaultReorderWindow 有序模式下已经读出、尚未交给处理器的最大事件数（重排缓冲的上限）
+const defaultReorderWindow = 10000
+
+// defaultTransactionBufferSize 事务 channel 的容量（每个事务可能包含大量行事件，容量比事件 channel 小）
+const defaultTransactionBufferSize = 100
+
 // EventProcessor 生产者-消费者事件处理器
 type EventProcessor struct {
 	dataSource     source.DataSource
@@ -26,6 +32,24 @@ type EventProcessor struct {
 	cancel         context.CancelFunc
 	handlers       []EventHandler
 	mu             sync.RWMutex
+
+	// 有序模式：worker 并行准备事件，重排阶段按序号依次交给处理器
+	ordered        bool
+	orderedChannel chan *sequencedEvent
+	resultChannel  chan *sequencedEvent
+	reorderWindow  int           // 重排窗口大小
+	reorderSlots   chan struct{} // 重排窗口，生产者发送前占用一个位置，事件交给处理器后释放
+
+	// 事务处理器：生产者按 binlog 顺序组装事务，由单独的 goroutine 依次交给处理器
+	txHandlers []TransactionHandler
+	txChannel  chan txItem
+	txErr      error // 第一个事务处理错误，由 Wait 返回
+}
+
+// txItem 事务 channel 中的元素：结束的事务，或者流式处理时事务中的一个行事件
+type txItem struct {
+	tx  *models.Transaction
+	row *models.Event
 }
 
 // EventHandler 事件处理器接口
@@ -34,6 +58,19 @@ type EventHandler interface {
 	Flush() error
 }
 
+// EventPreparer 可选的处理器接口：Prepare 在 worker 中并行完成耗时的准备工作（列名映射、SQL 生成等），
+// 结果写回事件；之后再调用同一个事件的 Handle，Handle 中只做输出。Prepare 返回错误时不再调用 Handle
+type EventPreparer interface {
+	Prepare(event *models.Event) error
+}
+
+// sequencedEvent 有序模式下带序号的事件
+type sequencedEvent struct {
+	seq    uint64
+	event  *models.Event
+	failed []bool // 每个处理器的 Prepare 是否失败，全部成功时为 nil
+}
+
 // NewEventProcessor 创建事件处理器
 func NewEventProcessor(
 	dataSource source.DataSource,
@@ -53,6 +90,7 @@ func NewEventProcessor(
 		filter:         filter,
 		workerCount:    workerCount,
 		bufferSize:     defaultBufferSize,
+		reorderWindow:  defaultReorderWindow,
 		workerChannels: workerChannels,
 		ctx:            ctx,
 		cancel:         cancel,
@@ -67,11 +105,48 @@ func (ep *EventProcessor) AddHandler(handler EventHandler) {
 	ep.handlers = append(ep.handlers, handler)
 }
 
+// AddTransactionHandler 添加事务处理器，必须在 Start 之前调用
+func (ep *EventProcessor) AddTransactionHandler(handler TransactionHandler) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.txHandlers = append(ep.txHandlers, handler)
+}
+
+// SetOrdered 设置有序模式，必须在 Start 之前调用
+// 有序模式下事件不再按表和键路由到固定的 worker，任意 worker 都可以准备事件，
+// Handle 按 binlog 顺序串行调用，处理器的输出顺序与 binlog 一致
+func (ep *EventProcessor) SetOrdered(ordered bool) {
+	ep.ordered = ordered
+}
+
 // Start 启动处理
 func (ep *EventProcessor) Start() error {
+	// 有事务处理器时才组装事务
+	var builder *TransactionBuilder
+	if len(ep.txHandlers) > 0 {
+		builder = NewTransactionBuilder(ep.filter.Match)
+		if ep.streamRows() {
+			builder.StreamRows(func(event *models.Event) {
+				ep.sendTransactionItem(txItem{row: event})
+			})
+		}
+		ep.txChannel = make(chan txItem, defaultTransactionBufferSize)
+		ep.wg.Add(1)
+		go ep.transactionConsumer()
+	}
+	if ep.ordered {
+		ep.orderedChannel = make(chan *sequencedEvent, ep.bufferSize)
+		ep.reorderSlots = make(chan struct{}, ep.reorderWindow)
+	}
+
 	// 启动生产者
 	ep.wg.Add(1)
-	go ep.producer()
+	go ep.producer(builder)
+
+	if ep.ordered {
+		ep.startOrdered()
+		return nil
+	}
 
 	// 启动消费者
 	for i := 0; i < ep.workerCount; i++ {
@@ -82,7 +157,25 @@ func (ep *EventProcessor) Start() error {
 	return nil
 }
 
-// Wait 等待处理完成
+// startOrdered 启动有序模式的消费者和重排阶段，所有消费者退出后关闭结果 channel
+func (ep *EventProcessor) startOrdered() {
+	ep.resultChannel = make(chan *sequencedEvent, ep.bufferSize)
+	ep.wg.Add(1)
+	go ep.reorder()
+
+	var workers sync.WaitGroup
+	for i := 0; i < ep.workerCount; i++ {
+		ep.wg.Add(1)
+		workers.Add(1)
+		go ep.orderedConsumer(&workers)
+	}
+	go func() {
+		workers.Wait()
+		close(ep.resultChannel)
+	}()
+}
+
+// Wait 等待处理完成，事务处理器出错时返回第一个错误
 func (ep *EventProcessor) Wait() error {
 	ep.wg.Wait()
 	return ep.flush()
@@ -93,16 +186,27 @@ func (ep *EventProcessor) Stop() {
 	ep.cancel()
 }
 
-// producer 生产者：读取事件并写入对应的 worker channel
-func (ep *EventProcessor) producer() {
+// producer 生产者：读取事件并写入对应的 worker channel，builder 不为 nil 时同时组装事务
+func (ep *EventProcessor) producer(builder *TransactionBuilder) {
 	defer ep.wg.Done()
 	defer func() {
 		// 关闭所有 worker channels
+		if ep.ordered {
+			close(ep.orderedChannel)
+		}
 		for i := 0; i < ep.workerCount; i++ {
 			close(ep.workerChannels[i])
 		}
+		if builder != nil {
+			// 读取范围结束时没有提交的事务也交给处理器
+			if tx := builder.Flush(); tx != nil {
+				ep.sendTransaction(tx)
+			}
+			close(ep.txChannel)
+		}
 	}()
 
+	var seq uint64
 	for ep.dataSource.HasMore() {
 		event, err := ep.dataSource.Read()
 		if err != nil {
@@ -123,11 +227,30 @@ func (ep *EventProcessor) producer() {
 			continue
 		}
 
+		// 事务边界事件不经过库表过滤，事务中的行事件由 builder 过滤
+		if builder != nil {
+			if tx := builder.Add(event); tx != nil && !ep.sendTransaction(tx) {
+				return
+			}
+			if ep.ctx.Err() != nil {
+				return
+			}
+		}
+
 		// 过滤
 		if !ep.filter.Match(event) {
 			continue
 		}
 
+		// 有序模式：按读取顺序编号，发送到所有 worker 共享的 channel
+		if ep.ordered {
+			if !ep.sendOrdered(&sequencedEvent{seq: seq, event: event}) {
+				return
+			}
+			seq++
+			continue
+		}
+
 		// 根据 table 和 key 计算应该路由到哪个 worker
 		workerID := ep.filter.GetWorkerID(event.Table, getEventKey(event), ep.workerCount)
 
@@ -156,7 +279,77 @@ func (ep *EventProcessor) consumer(id int) {
 			}
 
 			// 处理事件
-			ep.handleEvent(event)
+			ep.handleEvent(event, ep.prepareEvent(event))
+
+		case <-ep.ctx.Done():
+			return
+		}
+	}
+}
+
+// sendOrdered 占用重排窗口中的一个位置后发送事件，窗口已满时等待，处理被取消时返回 false
+func (ep *EventProcessor) sendOrdered(item *sequencedEvent) bool {
+	select {
+	case ep.reorderSlots <- struct{}{}:
+	case <-ep.ctx.Done():
+		return false
+	}
+	select {
+	case ep.orderedChannel <- item:
+		return true
+	case <-ep.ctx.Done():
+		return false
+	}
+}
+
+// orderedConsumer 有序模式的消费者：并行准备事件，结果交给重排阶段
+func (ep *EventProcessor) orderedConsumer(workers *sync.WaitGroup) {
+	defer ep.wg.Done()
+	defer workers.Done()
+
+	for {
+		select {
+		case item, ok := <-ep.orderedChannel:
+			if !ok {
+				return
+			}
+			item.failed = ep.prepareEvent(item.event)
+			select {
+			case ep.resultChannel <- item:
+			case <-ep.ctx.Done():
+				return
+			}
+
+		case <-ep.ctx.Done():
+			return
+		}
+	}
+}
+
+// reorder 重排阶段：缓存提前完成的事件，按序号依次交给处理器
+// 缓存的事件数不超过重排窗口，生产者在窗口占满时等待
+func (ep *EventProcessor) reorder() {
+	defer ep.wg.Done()
+
+	pending := make(map[uint64]*sequencedEvent)
+	var next uint64
+	for {
+		select {
+		case item, ok := <-ep.resultChannel:
+			if !ok {
+				return
+			}
+			pending[item.seq] = item
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				ep.handleEvent(ready.event, ready.failed)
+				<-ep.reorderSlots
+			}
 
 		case <-ep.ctx.Done():
 			return
@@ -164,11 +357,97 @@ func (ep *EventProcessor) consumer(id int) {
 	}
 }
 
-// handleEvent 处理单个事件
-func (ep *EventProcessor) handleEvent(event *models.Event) {
+// streamRows 所有事务处理器都实现了 TransactionStreamer 时，行事件不在事务中缓存，读到时直接交给处理器
+func (ep *EventProcessor) streamRows() bool {
+	for _, handler := range ep.txHandlers {
+		if _, ok := handler.(TransactionStreamer); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sendTransaction 将组装好的事务交给事务消费者，处理被取消时返回 false
+func (ep *EventProcessor) sendTransaction(tx *models.Transaction) bool {
+	return ep.sendTransactionItem(txItem{tx: tx})
+}
+
+// sendTransactionItem 将事务或流式处理的行事件交给事务消费者，处理被取消时返回 false
+func (ep *EventProcessor) sendTransactionItem(item txItem) bool {
+	select {
+	case ep.txChannel <- item:
+		return true
+	case <-ep.ctx.Done():
+		return false
+	}
+}
+
+// transactionConsumer 按顺序把事务（流式处理时还有事务中的行事件）交给所有事务处理器
+// 处理器出错后输出已经不完整（例如回滚 SQL 缺少事务），记录第一个错误并停止处理
+func (ep *EventProcessor) transactionConsumer() {
+	defer ep.wg.Done()
+
+	for {
+		select {
+		case item, ok := <-ep.txChannel:
+			if !ok {
+				return
+			}
+			if err := ep.handleTransactionItem(item); err != nil {
+				ep.txErr = err
+				ep.cancel()
+				return
+			}
+
+		case <-ep.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleTransactionItem 将一个事务或行事件交给所有事务处理器，返回第一个错误
+func (ep *EventProcessor) handleTransactionItem(item txItem) error {
+	for _, handler := range ep.txHandlers {
+		var err error
+		if item.row != nil {
+			err = handler.(TransactionStreamer).HandleRow(item.row)
+		} else {
+			err = handler.HandleTransaction(item.tx)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareEvent 调用实现了 EventPreparer 的处理器的 Prepare，返回每个处理器是否失败（全部成功时为 nil）
+func (ep *EventProcessor) prepareEvent(event *models.Event) []bool {
+	var failed []bool
+	for i, handler := range ep.handlers {
+		preparer, ok := handler.(EventPreparer)
+		if !ok {
+			continue
+		}
+		if err := preparer.Prepare(event); err != nil {
+			log.Printf("Error preparing event: %v\n", err)
+			if failed == nil {
+				failed = make([]bool, len(ep.handlers))
+			}
+			failed[i] = true
+		}
+	}
+	return failed
+}
+
+// handleEvent 处理单个事件，跳过 Prepare 失败的处理器
+func (ep *EventProcessor) handleEvent(event *models.Event, failed []bool) {
 	// 注意：handlers 在 Start() 后就不会改变，所以可以在消费者中直接访问
 	// 避免每个事件都要获取 RLock，大幅降低锁竞争
-	for _, handler := range ep.handlers {
+	for i, handler := range ep.handlers {
+		if failed != nil && failed[i] {
+			continue
+		}
 		if err := handler.Handle(event); err != nil {
 			log.Printf("Error handling event: %v\n", err)
 		}
@@ -183,6 +462,15 @@ func (ep *EventProcessor) flush() error {
 			return fmt.Errorf("error flushing handler: %w", err)
 		}
 	}
+	// 事务处理器出错时输出不完整，不再刷新
+	if ep.txErr != nil {
+		return fmt.Errorf("error handling transaction: %w", ep.txErr)
+	}
+	for _, handler := range ep.txHandlers {
+		if err := handler.Flush(); err != nil {
+			return fmt.Errorf("error flushing transaction handler: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,294 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aitoooooo/binlogx/pkg/filter"
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+// listSource 按顺序返回固定事件的数据源
+type listSource struct {
+	events []*models.Event
+}
+
+func (s *listSource) Open(ctx context.Context) error { return nil }
+func (s *listSource) Close() error                   { return nil }
+func (s *listSource) HasMore() bool                  { return len(s.events) > 0 }
+
+func (s *listSource) Read() (*models.Event, error) {
+	if len(s.events) == 0 {
+		return nil, fmt.Errorf("EOF")
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+// recordingHandler 记录收到的事件动作
+type recordingHandler struct {
+	mu      sync.Mutex
+	actions []string
+}
+
+func (h *recordingHandler) Handle(event *models.Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.actions = append(h.actions, event.Action)
+	return nil
+}
+
+func (h *recordingHandler) Flush() error { return nil }
+
+// recordingTxHandler 记录收到的事务，err 不为 nil 时处理事务返回该错误
+type recordingTxHandler struct {
+	txs     []*models.Transaction
+	err     error
+	flushed bool
+}
+
+func (h *recordingTxHandler) HandleTransaction(tx *models.Transaction) error {
+	h.txs = append(h.txs, tx)
+	return h.err
+}
+
+func (h *recordingTxHandler) Flush() error {
+	h.flushed = true
+	return nil
+}
+
+// streamingTxHandler 流式接收行事件，按收到的顺序记录行事件的动作和事务的提交位置
+type streamingTxHandler struct {
+	recordingTxHandler
+	log []string
+}
+
+func (h *streamingTxHandler) HandleRow(event *models.Event) error {
+	h.log = append(h.log, event.Action)
+	return nil
+}
+
+func (h *streamingTxHandler) HandleTransaction(tx *models.Transaction) error {
+	h.log = append(h.log, fmt.Sprintf("tx %d rows=%d", tx.CommitPos, len(tx.Events)))
+	return h.recordingTxHandler.HandleTransaction(tx)
+}
+
+func runProcessor(t *testing.T, proc *EventProcessor) error {
+	t.Helper()
+	if err := proc.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	return proc.Wait()
+}
+
+func newTestFilter(t *testing.T) *filter.RouteFilter {
+	t.Helper()
+	rf, err := filter.NewRouteFilter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rf
+}
+
+func TestProcessorDeliversBoundaryEventsToEventHandlers(t *testing.T) {
+	events := []*models.Event{
+		gtidEvent(165, "uuid:1"), beginEvent(215), rowEvent("INSERT", 255), xidEvent(286),
+	}
+	proc := NewEventProcessor(&listSource{events: events}, newTestFilter(t), 1)
+	handler := &recordingHandler{}
+	txHandler := &recordingTxHandler{}
+	proc.AddHandler(handler)
+	proc.AddTransactionHandler(txHandler)
+	if err := runProcessor(t, proc); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	// 事件处理器按原样收到事务边界，事务处理器收到组装好的事务
+	if want := []string{"GTID", "BEGIN", "INSERT", "COMMIT"}; !reflect.DeepEqual(handler.actions, want) {
+		t.Errorf("event handler got %v, want %v", handler.actions, want)
+	}
+	if len(txHandler.txs) != 1 || len(txHandler.txs[0].Events) != 1 || !txHandler.txs[0].Committed() {
+		t.Errorf("unexpected transactions %+v", txHandler.txs)
+	}
+}
+
+func TestProcessorReturnsTransactionHandlerError(t *testing.T) {
+	events := []*models.Event{
+		gtidEvent(165, "uuid:1"), beginEvent(215), rowEvent("INSERT", 255), xidEvent(286),
+		gtidEvent(351, "uuid:2"), beginEvent(401), rowEvent("DELETE", 441), xidEvent(472),
+	}
+	proc := NewEventProcessor(&listSource{events: events}, newTestFilter(t), 1)
+	txHandler := &recordingTxHandler{err: fmt.Errorf("disk full")}
+	proc.AddTransactionHandler(txHandler)
+
+	err := runProcessor(t, proc)
+	if err == nil || err.Error() != "error handling transaction: disk full" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// 出错后不再处理后续事务，也不刷新不完整的输出
+	if len(txHandler.txs) != 1 || txHandler.flushed {
+		t.Errorf("handled %d transactions, flushed %v", len(txHandler.txs), txHandler.flushed)
+	}
+}
+
+func TestProcessorStreamsRowsToTransactionStreamers(t *testing.T) {
+	events := []*models.Event{
+		gtidEvent(165, "uuid:1"), beginEvent(215), rowEvent("INSERT", 255), rowEvent("UPDATE", 295), xidEvent(326),
+		gtidEvent(391, "uuid:2"), beginEvent(441), rowEvent("DELETE", 481),
+	}
+	proc := NewEventProcessor(&listSource{events: events}, newTestFilter(t), 1)
+	handler := &streamingTxHandler{}
+	proc.AddTransactionHandler(handler)
+	if err := runProcessor(t, proc); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	// 行事件先于所属的事务到达，事务中不再保存行事件；最后没有提交的事务在读取结束时交给处理器
+	want := []string{"INSERT", "UPDATE", "tx 326 rows=0", "DELETE", "tx 0 rows=0"}
+	if !reflect.DeepEqual(handler.log, want) {
+		t.Errorf("got %v, want %v", handler.log, want)
+	}
+	if !handler.flushed {
+		t.Error("handler was not flushed")
+	}
+}
+
+func TestProcessorBuffersRowsWhenAnyHandlerDoesNotStream(t *testing.T) {
+	events := []*models.Event{
+		gtidEvent(165, "uuid:1"), beginEvent(215), rowEvent("INSERT", 255), xidEvent(286),
+	}
+	proc := NewEventProcessor(&listSource{events: events}, newTestFilter(t), 1)
+	streaming := &streamingTxHandler{}
+	buffered := &recordingTxHandler{}
+	proc.AddTransactionHandler(streaming)
+	proc.AddTransactionHandler(buffered)
+	if err := runProcessor(t, proc); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	if want := []string{"tx 286 rows=1"}; !reflect.DeepEqual(streaming.log, want) {
+		t.Errorf("got %v, want %v", streaming.log, want)
+	}
+	if len(buffered.txs) != 1 || len(buffered.txs[0].Events) != 1 {
+		t.Errorf("unexpected transactions %+v", buffered.txs)
+	}
+}
+
+// orderedHandler 在 Prepare 中随机等待，模拟耗时不同的准备工作，Handle 记录事件的顺序
+type orderedHandler struct {
+	prepare func(event *models.Event)
+	handled []uint32
+}
+
+func (h *orderedHandler) Prepare(event *models.Event) error {
+	h.prepare(event)
+	return nil
+}
+
+func (h *orderedHandler) Handle(event *models.Event) error {
+	h.handled = append(h.handled, event.LogPos)
+	return nil
+}
+
+func (h *orderedHandler) Flush() error { return nil }
+
+// countingSource 记录已经读出的事件数
+type countingSource struct {
+	listSource
+	reads atomic.Int64
+}
+
+func (s *countingSource) Read() (*models.Event, error) {
+	s.reads.Add(1)
+	return s.listSource.Read()
+}
+
+func numberedEvents(n int) []*models.Event {
+	events := make([]*models.Event, n)
+	for i := range events {
+		events[i] = &models.Event{Database: "app", Table: fmt.Sprintf("t%d", i%7), Action: "INSERT", LogPos: uint32(i + 1)}
+	}
+	return events
+}
+
+// waitProcessor 等待处理完成，超时说明重排阶段死锁
+func waitProcessor(t *testing.T, proc *EventProcessor) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- runProcessor(t, proc) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		proc.Stop()
+		t.Fatal("ordered processing did not finish")
+	}
+}
+
+func assertInOrder(t *testing.T, handled []uint32, n int) {
+	t.Helper()
+	if len(handled) != n {
+		t.Fatalf("handled %d events, want %d", len(handled), n)
+	}
+	for i, pos := range handled {
+		if pos != uint32(i+1) {
+			t.Fatalf("event %d handled at position %d", pos, i+1)
+		}
+	}
+}
+
+func TestOrderedModeKeepsInputOrder(t *testing.T) {
+	const n = 2000
+	for _, workers := range []int{1, 4, 16} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			rng := rand.New(rand.NewSource(int64(workers)))
+			delays := make([]time.Duration, n)
+			for i := range delays {
+				delays[i] = time.Duration(rng.Intn(200)) * time.Microsecond
+			}
+			handler := &orderedHandler{prepare: func(event *models.Event) {
+				time.Sleep(delays[event.LogPos-1])
+			}}
+
+			proc := NewEventProcessor(&listSource{events: numberedEvents(n)}, newTestFilter(t), workers)
+			proc.SetOrdered(true)
+			proc.AddHandler(handler)
+			waitProcessor(t, proc)
+			assertInOrder(t, handler.handled, n)
+		})
+	}
+}
+
+func TestOrderedModeBoundsReorderWindow(t *testing.T) {
+	const n, window = 500, 16
+	source := &countingSource{listSource: listSource{events: numberedEvents(n)}}
+	var readAhead int64
+	handler := &orderedHandler{prepare: func(event *models.Event) {
+		if event.LogPos == 1 {
+			// 队首事件很慢：其他 worker 完成的事件只能在重排缓冲中等待，生产者在窗口占满后停止读取
+			time.Sleep(200 * time.Millisecond)
+			readAhead = source.reads.Load()
+		}
+	}}
+
+	proc := NewEventProcessor(source, newTestFilter(t), 8)
+	proc.SetOrdered(true)
+	proc.reorderWindow = window
+	proc.AddHandler(handler)
+	waitProcessor(t, proc)
+
+	assertInOrder(t, handler.handled, n)
+	// 窗口中的事件加上生产者已经读出、正在等待窗口的一个事件
+	if readAhead > window+1 {
+		t.Errorf("read %d events while the head was blocked, window is %d", readAhead, window)
+	}
+}
@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+// queryEventType QUERY_EVENT 的事件类型名称（DDL、BEGIN、非事务表的 COMMIT 以及语句格式的 DML）
+const queryEventType = "QueryEvent"
+
+// TransactionHandler 事务处理器接口，按 binlog 顺序接收完整的事务
+// 与 EventHandler 并列，需要事务边界的处理器（回滚顺序、事务大小分析等）通过 AddTransactionHandler 注册
+type TransactionHandler interface {
+	HandleTransaction(tx *models.Transaction) error
+	Flush() error
+}
+
+// TransactionStreamer 可选的事务处理器接口：事务中匹配的行事件读到时就按 binlog 顺序交给 HandleRow，
+// 事务结束后再调用 HandleTransaction，此时 tx.Events 为空。所有事务处理器都实现该接口时，
+// 组装事务不再保存行事件，大事务不需要整个放在内存中
+type TransactionStreamer interface {
+	HandleRow(event *models.Event) error
+}
+
+// TransactionBuilder 将数据源按顺序读出的事件组装为事务
+// 事务从 GTID 或 BEGIN 事件开始，到 XID（InnoDB）或 COMMIT（非事务表）结束；
+// 没有 BEGIN 的 DDL 在 QUERY 事件处结束。只有包含匹配行事件的事务才会返回
+type TransactionBuilder struct {
+	match   func(event *models.Event) bool
+	stream  func(event *models.Event) // 不为 nil 时行事件交给 stream，不加入 tx.Events
+	current *models.Transaction
+	rows    int    // 当前事务中匹配的行事件数
+	begun   bool   // 当前事务是否已经读到 BEGIN
+	lastPos uint32 // 当前事务最后一个事件的结束位置
+}
+
+// NewTransactionBuilder 创建事务组装器，match 决定行事件是否加入事务，为 nil 时全部加入
+func NewTransactionBuilder(match func(event *models.Event) bool) *TransactionBuilder {
+	return &TransactionBuilder{match: match}
+}
+
+// StreamRows 设置后匹配的行事件不再加入 tx.Events，读到时直接交给 fn
+func (b *TransactionBuilder) StreamRows(fn func(event *models.Event)) {
+	b.stream = fn
+}
+
+// Add 处理下一个事件，返回因该事件而结束的事务（没有结束或事务中没有匹配的行事件时为 nil）
+func (b *TransactionBuilder) Add(event *models.Event) *models.Transaction {
+	switch {
+	case event.Action == "GTID":
+		// 上一个事务没有读到提交事件（读取范围截断），作为未提交的事务返回
+		done := b.finish(nil)
+		b.start(event)
+		return done
+
+	case event.Action == "BEGIN":
+		if b.current == nil || b.begun {
+			done := b.finish(nil)
+			b.start(event)
+			b.current.ThreadID = event.ThreadID
+			b.begun = true
+			return done
+		}
+		b.current.ThreadID = event.ThreadID
+		b.begun = true
+		b.lastPos = event.LogPos
+
+	case event.Action == "COMMIT":
+		return b.finish(event)
+
+	case isRowEvent(event):
+		if b.current == nil {
+			// 从事务中间开始读取，或者没有 GTID/BEGIN 的 binlog
+			b.start(event)
+			b.begun = true
+		}
+		if b.match == nil || b.match(event) {
+			b.rows++
+			if b.stream != nil {
+				b.stream(event)
+			} else {
+				b.current.Events = append(b.current.Events, event)
+			}
+		}
+		b.lastPos = event.LogPos
+
+	case event.EventType == queryEventType && b.current != nil && !b.begun:
+		// GTID 之后没有 BEGIN 的 QUERY 是 DDL，自成一个事务
+		return b.finish(event)
+
+	default:
+		if b.current != nil && event.LogName == b.current.LogName && event.LogPos > b.lastPos {
+			b.lastPos = event.LogPos
+		}
+	}
+	return nil
+}
+
+// Flush 返回最后一个没有读到提交事件的事务（读取结束时调用）
+func (b *TransactionBuilder) Flush() *models.Transaction {
+	return b.finish(nil)
+}
+
+// start 以 event 作为第一个事件开始新事务
+func (b *TransactionBuilder) start(event *models.Event) {
+	b.current = &models.Transaction{
+		GTID:     event.GTID,
+		LogName:  event.LogName,
+		BeginPos: event.LogPos - min(event.EventSize, event.LogPos),
+	}
+	b.rows = 0
+	b.begun = false
+	b.lastPos = event.LogPos
+}
+
+// finish 结束当前事务，commit 为提交事件，为 nil 表示事务没有提交
+func (b *TransactionBuilder) finish(commit *models.Event) *models.Transaction {
+	tx := b.current
+	b.current = nil
+	b.begun = false
+	if tx == nil {
+		return nil
+	}
+
+	end := b.lastPos
+	if commit != nil {
+		tx.CommitPos = commit.LogPos
+		tx.CommitTime = commit.Timestamp
+		end = commit.LogPos
+	}
+	if end > tx.BeginPos {
+		tx.Size = uint64(end - tx.BeginPos)
+	}
+	if b.rows == 0 {
+		return nil
+	}
+	return tx
+}
+
+// isRowEvent 判断是否为行事件（语句格式的 DML 也是 QUERY 事件，不属于行事件）
+func isRowEvent(event *models.Event) bool {
+	switch event.Action {
+	case "INSERT", "UPDATE", "DELETE":
+		return event.EventType != queryEventType
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+// txEvent 构造 mysql-bin.000001 中结束位置为 pos、大小为 size 的事件
+func txEvent(action, eventType string, pos, size uint32) *models.Event {
+	return &models.Event{
+		LogName:   "mysql-bin.000001",
+		LogPos:    pos,
+		EventSize: size,
+		EventType: eventType,
+		Action:    action,
+		Database:  "app",
+		Table:     "t",
+		GTID:      "uuid:1",
+	}
+}
+
+func gtidEvent(pos uint32, gtid string) *models.Event {
+	event := txEvent("GTID", "GTIDEvent", pos, 65)
+	event.GTID = gtid
+	event.Table = ""
+	return event
+}
+
+func beginEvent(pos uint32) *models.Event {
+	event := txEvent("BEGIN", queryEventType, pos, 50)
+	event.ThreadID = 42
+	event.Table = ""
+	return event
+}
+
+func rowEvent(action string, pos uint32) *models.Event {
+	return txEvent(action, "WriteRowsEventV2", pos, 40)
+}
+
+func xidEvent(pos uint32) *models.Event {
+	event := txEvent("COMMIT", "XidEvent", pos, 31)
+	event.Table = ""
+	return event
+}
+
+func queryEvent(action string, pos uint32) *models.Event {
+	event := txEvent(action, queryEventType, pos, 100)
+	event.Table = ""
+	return event
+}
+
+// txSummary 事务中需要比较的字段
+type txSummary struct {
+	GTID      string
+	BeginPos  uint32
+	CommitPos uint32
+	Size      uint64
+	ThreadID  uint32
+	Rows      int
+}
+
+func TestTransactionBuilder(t *testing.T) {
+	otherTable := func(pos uint32) *models.Event {
+		event := rowEvent("INSERT", pos)
+		event.Table = "other"
+		return event
+	}
+
+	tests := []struct {
+		name   string
+		events []*models.Event
+		match  func(event *models.Event) bool
+		want   []txSummary
+	}{
+		{
+			name: "GTID, BEGIN, rows and XID",
+			events: []*models.Event{
+				gtidEvent(165, "uuid:1"), beginEvent(215), rowEvent("INSERT", 255), rowEvent("UPDATE", 295), xidEvent(326),
+			},
+			want: []txSummary{{GTID: "uuid:1", BeginPos: 100, CommitPos: 326, Size: 226, ThreadID: 42, Rows: 2}},
+		},
+		{
+			name: "consecutive transactions",
+			events: []*models.Event{
+				gtidEvent(165, "uuid:1"), beginEvent(215), rowEvent("INSERT", 255), xidEvent(286),
+				gtidEvent(351, "uuid:2"), beginEvent(401), rowEvent("DELETE", 441), xidEvent(472),
+			},
+			want: []txSummary{
+				{GTID: "uuid:1", BeginPos: 100, CommitPos: 286, Size: 186, ThreadID: 42, Rows: 1},
+				{GTID: "uuid:2", BeginPos: 286, CommitPos: 472, Size: 186, ThreadID: 42, Rows: 1},
+			},
+		},
+		{
+			name: "DDL outside a transaction ends at its QUERY event",
+			events: []*models.Event{
+				gtidEvent(165, "uuid:1"), queryEvent("CREATE", 265),
+				gtidEvent(330, "uuid:2"), beginEvent(380), rowEvent("INSERT", 420), xidEvent(451),
+			},
+			want: []txSummary{{GTID: "uuid:2", BeginPos: 265, CommitPos: 451, Size: 186, ThreadID: 42, Rows: 1}},
+		},
+		{
+			name: "non-transactional table commits with a COMMIT query",
+			events: []*models.Event{
+				beginEvent(150), rowEvent("INSERT", 190), queryEvent("COMMIT", 290),
+			},
+			want: []txSummary{{GTID: "uuid:1", BeginPos: 100, CommitPos: 290, Size: 190, ThreadID: 42, Rows: 1}},
+		},
+		{
+			name: "anonymous transactions start at BEGIN",
+			events: []*models.Event{
+				beginEvent(150), rowEvent("INSERT", 190), beginEvent(240), rowEvent("UPDATE", 280), xidEvent(311),
+			},
+			want: []txSummary{
+				{GTID: "uuid:1", BeginPos: 100, Size: 90, ThreadID: 42, Rows: 1},
+				{GTID: "uuid:1", BeginPos: 190, CommitPos: 311, Size: 121, ThreadID: 42, Rows: 1},
+			},
+		},
+		{
+			name: "reading starts inside a transaction",
+			events: []*models.Event{
+				rowEvent("UPDATE", 140), rowEvent("UPDATE", 180), xidEvent(211),
+			},
+			want: []txSummary{{GTID: "uuid:1", BeginPos: 100, CommitPos: 211, Size: 111, Rows: 2}},
+		},
+		{
+			name: "GTID without commit is returned as uncommitted when the next one starts",
+			events: []*models.Event{
+				gtidEvent(165, "uuid:1"), beginEvent(215), rowEvent("INSERT", 255),
+				gtidEvent(320, "uuid:2"), beginEvent(370), rowEvent("INSERT", 410), xidEvent(441),
+			},
+			want: []txSummary{
+				{GTID: "uuid:1", BeginPos: 100, Size: 155, ThreadID: 42, Rows: 1},
+				{GTID: "uuid:2", BeginPos: 255, CommitPos: 441, Size: 186, ThreadID: 42, Rows: 1},
+			},
+		},
+		{
+			name: "trailing transaction without commit is returned by Flush",
+			events: []*models.Event{
+				gtidEvent(165, "uuid:1"), beginEvent(215), rowEvent("INSERT", 255), rowEvent("DELETE", 295),
+			},
+			want: []txSummary{{GTID: "uuid:1", BeginPos: 100, Size: 195, ThreadID: 42, Rows: 2}},
+		},
+		{
+			name: "transactions without matching rows are dropped",
+			events: []*models.Event{
+				gtidEvent(165, "uuid:1"), beginEvent(215), otherTable(255), xidEvent(286),
+				gtidEvent(351, "uuid:2"), beginEvent(401), rowEvent("INSERT", 441), otherTable(481), xidEvent(512),
+			},
+			match: func(event *models.Event) bool { return event.Table == "t" },
+			want:  []txSummary{{GTID: "uuid:2", BeginPos: 286, CommitPos: 512, Size: 226, ThreadID: 42, Rows: 1}},
+		},
+		{
+			name: "statement-based DML inside BEGIN is not a row event",
+			events: []*models.Event{
+				gtidEvent(165, "uuid:1"), beginEvent(215), queryEvent("INSERT", 315), xidEvent(346),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := NewTransactionBuilder(test.match)
+			var got []txSummary
+			collect := func(tx *models.Transaction) {
+				if tx == nil {
+					return
+				}
+				got = append(got, txSummary{tx.GTID, tx.BeginPos, tx.CommitPos, tx.Size, tx.ThreadID, len(tx.Events)})
+			}
+			for _, event := range test.events {
+				collect(builder.Add(event))
+			}
+			collect(builder.Flush())
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("\n got  %+v\n want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+// Catalog 一组表在某一时刻的表结构（schema 文件、快照），按库名和表名查找
+type Catalog struct {
+	tables map[string]*models.TableMeta // schema.table -> 表结构
+}
+
+// NewCatalog 创建空的表结构集合
+func NewCatalog() *Catalog {
+	return &Catalog{tables: make(map[string]*models.TableMeta)}
+}
+
+// Add 添加一张表，schema 为空表示未指定库名（例如没有 USE 语句的单库 dump）
+func (c *Catalog) Add(schema, table string, meta *models.TableMeta) {
+	c.tables[tableKey(schema, table)] = meta
+}
+
+// GetTableMeta 获取表结构，精确匹配不到时再匹配未指定库名的同名表
+// 签名与 cache.MetaCache 一致，可以作为 MetaCache 的加载方式或表结构历史的基线
+func (c *Catalog) GetTableMeta(schema, table string) (*models.TableMeta, error) {
+	if meta, ok := c.tables[tableKey(schema, table)]; ok {
+		return meta, nil
+	}
+	if meta, ok := c.tables[tableKey("", table)]; ok {
+		return meta, nil
+	}
+	return nil, fmt.Errorf("table %s.%s not found", schema, table)
+}
+
+// Tables 返回所有表的 schema.table 名称（有序）
+func (c *Catalog) Tables() []string {
+	keys := make([]string, 0, len(c.tables))
+	for key := range c.tables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len 返回表的数量
+func (c *Catalog) Len() int {
+	return len(c.tables)
+}
@@ -0,0 +1,495 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/charset"
+	"github.com/pingcap/tidb/pkg/parser/format"
+	"github.com/pingcap/tidb/pkg/parser/mysql"
+	_ "github.com/pingcap/tidb/pkg/parser/test_driver" // 解析字面量所需的表达式实现
+)
+
+// ApplyDDL 重放 pos 位置的 QUERY 事件，defaultSchema 为事件中的当前库
+// 非 DDL 语句直接忽略；只记录影响列结构的变化（建表、删表、改名、增删改列、主键）
+func (h *History) ApplyDDL(defaultSchema, query string, pos Position) error {
+	if !isDDL(query) {
+		return nil
+	}
+
+	stmts, _, err := parser.New().Parse(query, "", "")
+	if err != nil {
+		return fmt.Errorf("failed to parse DDL at %s: %w", pos, err)
+	}
+
+	a := &ddlApplier{history: h, schema: defaultSchema, pos: pos, ddl: query, changes: make(map[string]*tableChange)}
+	for _, stmt := range stmts {
+		if err := a.apply(stmt); err != nil {
+			return fmt.Errorf("failed to apply DDL at %s: %w", pos, err)
+		}
+	}
+	a.commit()
+	return nil
+}
+
+// isDDL 根据第一个关键字（跳过开头的注释）判断是否为可能改变表结构的语句
+func isDDL(query string) bool {
+	keywords := strings.Fields(stripLeadingComments(query))
+	if len(keywords) == 0 {
+		return false
+	}
+	switch strings.ToUpper(keywords[0]) {
+	case "CREATE", "ALTER", "DROP", "RENAME":
+		return true
+	}
+	return false
+}
+
+// tableChange 一条 DDL 中对某张表的修改结果
+type tableChange struct {
+	schema, table string
+	meta          *models.TableMeta
+}
+
+// ddlApplier 在一条 DDL 内累积各表的修改，保证 RENAME a TO tmp, b TO a, tmp TO b 这类语句按顺序生效
+type ddlApplier struct {
+	history *History
+	schema  string
+	pos     Position
+	ddl     string
+	changes map[string]*tableChange
+	order   []string
+}
+
+// get 返回表在本条 DDL 之前（或本条 DDL 已做的修改之后）的结构
+func (a *ddlApplier) get(tn *ast.TableName) *models.TableMeta {
+	schema, table := a.name(tn)
+	if change, ok := a.changes[tableKey(schema, table)]; ok {
+		return change.meta
+	}
+	versions := a.history.versions(schema, table)
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Pos.Compare(a.pos) < 0 {
+			return versions[i].Meta
+		}
+	}
+	return nil
+}
+
+// set 记录表的新结构，meta 为 nil 表示表被删除
+func (a *ddlApplier) set(tn *ast.TableName, meta *models.TableMeta) {
+	schema, table := a.name(tn)
+	key := tableKey(schema, table)
+	if _, ok := a.changes[key]; !ok {
+		a.order = append(a.order, key)
+	}
+	a.changes[key] = &tableChange{schema: schema, table: table, meta: meta}
+}
+
+// name 返回表的库名和表名，未指定库名时使用事件的当前库
+func (a *ddlApplier) name(tn *ast.TableName) (string, string) {
+	schema := tn.Schema.O
+	if schema == "" {
+		schema = a.schema
+	}
+	return schema, tn.Name.O
+}
+
+// commit 将修改记录为新版本，删除从未见过的表不记录
+func (a *ddlApplier) commit() {
+	for _, key := range a.order {
+		change := a.changes[key]
+		if change.meta == nil && len(a.history.versions(change.schema, change.table)) == 0 {
+			continue
+		}
+		a.history.addVersion(change.schema, change.table, TableVersion{Pos: a.pos, DDL: a.ddl, Meta: change.meta})
+	}
+}
+
+// apply 应用一条语句
+func (a *ddlApplier) apply(stmt ast.StmtNode) error {
+	switch s := stmt.(type) {
+	case *ast.CreateTableStmt:
+		if s.IfNotExists && a.get(s.Table) != nil {
+			return nil
+		}
+		if s.ReferTable != nil {
+			// CREATE TABLE ... LIKE
+			if refer := a.get(s.ReferTable); refer != nil {
+				a.set(s.Table, cloneTableMeta(refer))
+			}
+			return nil
+		}
+		if len(s.Cols) == 0 {
+			// CREATE TABLE ... SELECT 未显式定义列，无法得知表结构
+			return nil
+		}
+		a.set(s.Table, TableMetaFromCreate(s))
+
+	case *ast.DropTableStmt:
+		if s.IsView {
+			return nil
+		}
+		for _, tn := range s.Tables {
+			a.set(tn, nil)
+		}
+
+	case *ast.RenameTableStmt:
+		for _, t2t := range s.TableToTables {
+			meta := a.get(t2t.OldTable)
+			a.set(t2t.OldTable, nil)
+			if meta != nil {
+				a.set(t2t.NewTable, meta)
+			}
+		}
+
+	case *ast.DropDatabaseStmt:
+		for _, table := range a.history.tablesInSchema(s.Name.O) {
+			a.set(&ast.TableName{Schema: s.Name, Name: ast.NewCIStr(table)}, nil)
+		}
+
+	case *ast.AlterTableStmt:
+		return a.alterTable(s)
+	}
+	return nil
+}
+
+// alterTable 应用 ALTER TABLE，表结构未知时忽略
+func (a *ddlApplier) alterTable(s *ast.AlterTableStmt) error {
+	current := a.get(s.Table)
+	if current == nil {
+		return nil
+	}
+	meta := cloneTableMeta(current)
+	target := s.Table
+
+	for _, spec := range s.Specs {
+		switch spec.Tp {
+		case ast.AlterTableAddColumns:
+			for i, def := range spec.NewColumns {
+				if columnIndex(meta, def.Name.Name.O) >= 0 {
+					return fmt.Errorf("duplicate column %s", def.Name.Name.O)
+				}
+				position := spec.Position
+				if i > 0 && position != nil && position.Tp != ast.ColumnPositionNone {
+					// ADD COLUMN (a, b) 不支持位置；ADD COLUMN a AFTER x 只有一列
+					position = nil
+				}
+				if err := insertColumn(meta, def, position); err != nil {
+					return err
+				}
+			}
+
+		case ast.AlterTableDropColumn:
+			idx := columnIndex(meta, spec.OldColumnName.Name.O)
+			if idx < 0 {
+				if spec.IfExists {
+					continue
+				}
+				return fmt.Errorf("unknown column %s", spec.OldColumnName.Name.O)
+			}
+			removeKeyColumn(meta, meta.Columns[idx].Name)
+			meta.Columns = append(meta.Columns[:idx], meta.Columns[idx+1:]...)
+
+		case ast.AlterTableModifyColumn, ast.AlterTableChangeColumn:
+			oldName := spec.NewColumns[0].Name.Name.O
+			if spec.Tp == ast.AlterTableChangeColumn {
+				oldName = spec.OldColumnName.Name.O
+			}
+			idx := columnIndex(meta, oldName)
+			if idx < 0 {
+				return fmt.Errorf("unknown column %s", oldName)
+			}
+			old := meta.Columns[idx]
+			meta.Columns = append(meta.Columns[:idx], meta.Columns[idx+1:]...)
+			position := spec.Position
+			if position == nil || position.Tp == ast.ColumnPositionNone {
+				// 保持原来的位置
+				position = positionAt(meta, idx)
+			}
+			if err := insertColumn(meta, spec.NewColumns[0], position); err != nil {
+				return err
+			}
+			renameKeyColumn(meta, old.Name, spec.NewColumns[0].Name.Name.O)
+
+		case ast.AlterTableRenameColumn:
+			idx := columnIndex(meta, spec.OldColumnName.Name.O)
+			if idx < 0 {
+				return fmt.Errorf("unknown column %s", spec.OldColumnName.Name.O)
+			}
+			renameKeyColumn(meta, meta.Columns[idx].Name, spec.NewColumnName.Name.O)
+			meta.Columns[idx].Name = spec.NewColumnName.Name.O
+
+		case ast.AlterTableAddConstraint:
+			if spec.Constraint == nil {
+				continue
+			}
+			switch spec.Constraint.Tp {
+			case ast.ConstraintPrimaryKey:
+				setPrimaryKey(meta, spec.Constraint)
+			case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+				addUniqueKey(meta, spec.Constraint)
+			}
+
+		case ast.AlterTableDropPrimaryKey:
+			meta.PrimaryKey = nil
+
+		case ast.AlterTableRenameTable:
+			a.set(target, nil)
+			target = spec.NewTable
+		}
+	}
+
+	a.set(target, meta)
+	return nil
+}
+
+// TableMetaFromCreate 根据 CREATE TABLE 语句生成表元数据
+func TableMetaFromCreate(s *ast.CreateTableStmt) *models.TableMeta {
+	meta := &models.TableMeta{}
+	tableCharset := tableDefaultCharset(s.Options)
+	for _, def := range s.Cols {
+		column, primary := columnMetaFromDef(def)
+		if column.Charset == "" && isCharacterType(def.Tp.GetType(), def.Tp.GetCharset()) {
+			column.Charset = tableCharset
+		}
+		meta.Columns = append(meta.Columns, column)
+		if primary {
+			meta.PrimaryKey = []string{column.Name}
+		}
+		for _, opt := range def.Options {
+			if opt.Tp == ast.ColumnOptionUniqKey {
+				meta.UniqueKeys = append(meta.UniqueKeys, []string{column.Name})
+			}
+		}
+	}
+	for _, constraint := range s.Constraints {
+		switch constraint.Tp {
+		case ast.ConstraintPrimaryKey:
+			setPrimaryKey(meta, constraint)
+		case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+			addUniqueKey(meta, constraint)
+		}
+	}
+	return meta
+}
+
+// columnMetaFromDef 根据列定义生成列元数据，第二个返回值表示列上定义了 PRIMARY KEY
+func columnMetaFromDef(def *ast.ColumnDef) (models.ColumnMeta, bool) {
+	column := models.ColumnMeta{
+		Name:     def.Name.Name.O,
+		Type:     def.Tp.InfoSchemaStr(),
+		Unsigned: mysql.HasUnsignedFlag(def.Tp.GetFlag()),
+		Nullable: true,
+	}
+	if tp := def.Tp.GetType(); tp == mysql.TypeEnum || tp == mysql.TypeSet {
+		column.Elements = append([]string(nil), def.Tp.GetElems()...)
+	}
+	primary := false
+	for _, opt := range def.Options {
+		switch opt.Tp {
+		case ast.ColumnOptionNotNull:
+			column.Nullable = false
+		case ast.ColumnOptionNull:
+			column.Nullable = true
+		case ast.ColumnOptionPrimaryKey:
+			column.Nullable = false
+			primary = true
+		case ast.ColumnOptionDefaultValue:
+			column.Default = defaultValue(opt.Expr)
+		}
+	}
+	if isCharacterType(def.Tp.GetType(), def.Tp.GetCharset()) {
+		column.Charset = def.Tp.GetCharset()
+		for _, opt := range def.Options {
+			if opt.Tp == ast.ColumnOptionCollate && column.Charset == "" {
+				column.Charset = collationCharset(opt.StrValue)
+			}
+		}
+	}
+	return column, primary
+}
+
+// isCharacterType 判断列是否为字符列（CHAR/VARCHAR/TEXT），BINARY/BLOB 的字符集为 binary
+func isCharacterType(tp byte, cs string) bool {
+	switch tp {
+	case mysql.TypeString, mysql.TypeVarchar, mysql.TypeVarString,
+		mysql.TypeTinyBlob, mysql.TypeBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob:
+		return cs != charset.CharsetBin
+	}
+	return false
+}
+
+// tableDefaultCharset 返回建表语句中的默认字符集（DEFAULT CHARSET 或 COLLATE），未指定时为空
+func tableDefaultCharset(options []*ast.TableOption) string {
+	var collate string
+	for _, opt := range options {
+		switch opt.Tp {
+		case ast.TableOptionCharset:
+			return opt.StrValue
+		case ast.TableOptionCollate:
+			collate = opt.StrValue
+		}
+	}
+	return collationCharset(collate)
+}
+
+// collationCharset 返回排序规则所属的字符集，未知排序规则返回空字符串
+func collationCharset(name string) string {
+	if name == "" {
+		return ""
+	}
+	collation, err := charset.GetCollationByName(name)
+	if err != nil {
+		return ""
+	}
+	return collation.CharsetName
+}
+
+// defaultValue 返回列默认值，字面量返回其值，表达式（如 CURRENT_TIMESTAMP）返回表达式文本
+func defaultValue(expr ast.ExprNode) interface{} {
+	if v, ok := expr.(ast.ValueExpr); ok {
+		return v.GetValue()
+	}
+	var sb strings.Builder
+	if err := expr.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)); err != nil {
+		return nil
+	}
+	return sb.String()
+}
+
+// insertColumn 按位置插入列
+func insertColumn(meta *models.TableMeta, def *ast.ColumnDef, position *ast.ColumnPosition) error {
+	column, primary := columnMetaFromDef(def)
+	idx := len(meta.Columns)
+	if position != nil {
+		switch position.Tp {
+		case ast.ColumnPositionFirst:
+			idx = 0
+		case ast.ColumnPositionAfter:
+			after := columnIndex(meta, position.RelativeColumn.Name.O)
+			if after < 0 {
+				return fmt.Errorf("unknown column %s", position.RelativeColumn.Name.O)
+			}
+			idx = after + 1
+		}
+	}
+	meta.Columns = append(meta.Columns, models.ColumnMeta{})
+	copy(meta.Columns[idx+1:], meta.Columns[idx:])
+	meta.Columns[idx] = column
+	if primary {
+		meta.PrimaryKey = []string{column.Name}
+	}
+	return nil
+}
+
+// positionAt 返回插入到第 idx 列的位置
+func positionAt(meta *models.TableMeta, idx int) *ast.ColumnPosition {
+	if idx == 0 {
+		return &ast.ColumnPosition{Tp: ast.ColumnPositionFirst}
+	}
+	return &ast.ColumnPosition{
+		Tp:             ast.ColumnPositionAfter,
+		RelativeColumn: &ast.ColumnName{Name: ast.NewCIStr(meta.Columns[idx-1].Name)},
+	}
+}
+
+// setPrimaryKey 根据 PRIMARY KEY 约束设置主键列
+func setPrimaryKey(meta *models.TableMeta, constraint *ast.Constraint) {
+	meta.PrimaryKey = nil
+	for _, key := range constraint.Keys {
+		if key.Column == nil {
+			continue
+		}
+		name := key.Column.Name.O
+		if idx := columnIndex(meta, name); idx >= 0 {
+			name = meta.Columns[idx].Name
+			meta.Columns[idx].Nullable = false
+		}
+		meta.PrimaryKey = append(meta.PrimaryKey, name)
+	}
+}
+
+// addUniqueKey 根据 UNIQUE 约束添加唯一索引，包含表达式的函数索引忽略
+func addUniqueKey(meta *models.TableMeta, constraint *ast.Constraint) {
+	var key []string
+	for _, part := range constraint.Keys {
+		if part.Column == nil {
+			return
+		}
+		name := part.Column.Name.O
+		if idx := columnIndex(meta, name); idx >= 0 {
+			name = meta.Columns[idx].Name
+		}
+		key = append(key, name)
+	}
+	meta.UniqueKeys = append(meta.UniqueKeys, key)
+}
+
+// removeKeyColumn 删除列时同步从主键和唯一索引中移除，唯一索引的列全部删除后索引也删除
+func removeKeyColumn(meta *models.TableMeta, name string) {
+	meta.PrimaryKey = withoutColumn(meta.PrimaryKey, name)
+	var uniqueKeys [][]string
+	for _, key := range meta.UniqueKeys {
+		if key = withoutColumn(key, name); len(key) > 0 {
+			uniqueKeys = append(uniqueKeys, key)
+		}
+	}
+	meta.UniqueKeys = uniqueKeys
+}
+
+// withoutColumn 返回去掉指定列后的列名列表
+func withoutColumn(columns []string, name string) []string {
+	var result []string
+	for _, column := range columns {
+		if !strings.EqualFold(column, name) {
+			result = append(result, column)
+		}
+	}
+	return result
+}
+
+// renameKeyColumn 列改名时同步修改主键和唯一索引中的列名
+func renameKeyColumn(meta *models.TableMeta, oldName, newName string) {
+	for _, key := range append([][]string{meta.PrimaryKey}, meta.UniqueKeys...) {
+		for i, column := range key {
+			if strings.EqualFold(column, oldName) {
+				key[i] = newName
+			}
+		}
+	}
+}
+
+// columnIndex 按列名（不区分大小写）查找列号，不存在时返回 -1
+func columnIndex(meta *models.TableMeta, name string) int {
+	for i, column := range meta.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// cloneTableMeta 复制表元数据，历史中的版本不可修改
+func cloneTableMeta(meta *models.TableMeta) *models.TableMeta {
+	return &models.TableMeta{
+		Columns:    append([]models.ColumnMeta(nil), meta.Columns...),
+		PrimaryKey: append([]string(nil), meta.PrimaryKey...),
+		UniqueKeys: cloneKeys(meta.UniqueKeys),
+	}
+}
+
+// cloneKeys 复制索引列表
+func cloneKeys(keys [][]string) [][]string {
+	if keys == nil {
+		return nil
+	}
+	result := make([][]string, len(keys))
+	for i, key := range keys {
+		result[i] = append([]string(nil), key...)
+	}
+	return result
+}
@@ -0,0 +1,269 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+// Position binlog 位置，表结构版本从该位置开始生效
+type Position struct {
+	LogName string `json:"log_name"`
+	LogPos  uint32 `json:"log_pos"`
+}
+
+// Compare 比较两个位置，先比较文件序号再比较文件内偏移
+// 文件名按序号比较（mysql-bin.999999 早于 mysql-bin.1000000），无法解析序号时按字符串比较；
+// 零值位置（基线）小于任何位置
+func (p Position) Compare(o Position) int {
+	if c := compareLogNames(p.LogName, o.LogName); c != 0 {
+		return c
+	}
+	switch {
+	case p.LogPos < o.LogPos:
+		return -1
+	case p.LogPos > o.LogPos:
+		return 1
+	}
+	return 0
+}
+
+// compareLogNames 比较两个 binlog 文件名，前缀相同时按数字序号比较
+func compareLogNames(a, b string) int {
+	prefixA, seqA, okA := logSequence(a)
+	prefixB, seqB, okB := logSequence(b)
+	if !okA || !okB || prefixA != prefixB {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case seqA < seqB:
+		return -1
+	case seqA > seqB:
+		return 1
+	}
+	return 0
+}
+
+// logSequence 从文件名中解析前缀和序号，例如 mysql-bin.000123 -> ("mysql-bin", 123)
+func logSequence(name string) (string, uint64, bool) {
+	idx := strings.LastIndex(name, ".")
+	if idx <= 0 || idx == len(name)-1 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(name[idx+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:idx], seq, true
+}
+
+// String 返回 file:pos 形式的位置
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d", p.LogName, p.LogPos)
+}
+
+// TableVersion 表结构的一个版本，从 Pos 开始生效
+// Meta 为 nil 表示表在该位置被删除；基线版本的 Pos 为零值
+type TableVersion struct {
+	Pos  Position          `json:"pos"`
+	DDL  string            `json:"ddl,omitempty"` // 产生该版本的 DDL，基线版本为空
+	Meta *models.TableMeta `json:"meta"`
+}
+
+// TableMetaProvider 提供基线表结构，例如 cache.MetaCache
+type TableMetaProvider interface {
+	GetTableMeta(schema, table string) (*models.TableMeta, error)
+}
+
+// History 表结构历史
+// 从基线快照开始，按 binlog 顺序重放 DDL，为每张表记录各个版本，
+// 行事件按所在位置查找当时生效的表结构
+type History struct {
+	mu       sync.RWMutex
+	tables   map[string][]TableVersion // schema.table -> 按位置排序的版本
+	baseline TableMetaProvider         // 起始位置的表结构，作为基线版本参与 DDL 重放
+	current  TableMetaProvider         // 当前的表结构，只用于历史中没有任何版本的表
+}
+
+// historyFile 持久化文件格式
+type historyFile struct {
+	Tables map[string][]TableVersion `json:"tables"`
+}
+
+// NewHistory 创建空的表结构历史
+func NewHistory() *History {
+	return &History{tables: make(map[string][]TableVersion)}
+}
+
+// LoadHistory 从文件加载表结构历史，文件不存在时返回空历史
+func LoadHistory(path string) (*History, error) {
+	h := NewHistory()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema history: %w", err)
+	}
+
+	var file historyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid schema history %s: %w", path, err)
+	}
+	for key, versions := range file.Tables {
+		sort.SliceStable(versions, func(i, j int) bool { return versions[i].Pos.Compare(versions[j].Pos) < 0 })
+		h.tables[key] = versions
+	}
+	return h, nil
+}
+
+// Save 将表结构历史写入文件（先写临时文件再重命名，避免中断时留下不完整的文件）
+func (h *History) Save(path string) error {
+	h.mu.RLock()
+	data, err := json.MarshalIndent(historyFile{Tables: h.tables}, "", "  ")
+	h.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode schema history: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create schema history directory: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema history: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write schema history: %w", err)
+	}
+	return nil
+}
+
+// SetBaseline 设置基线快照的来源
+// 历史中没有记录的表在第一次用到时从 provider 读取，作为该表的基线版本（位置为零值），之后的 DDL 在其上重放，
+// 因此 provider 必须反映读取范围起始位置的表结构（例如在起始位置导出的 schema 文件或快照）
+func (h *History) SetBaseline(provider TableMetaProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.baseline = provider
+}
+
+// SetCurrentSchema 设置当前表结构的来源（例如 INFORMATION_SCHEMA）
+// 当前结构已经包含读取范围内的 DDL，不能作为基线重放：只有历史中没有任何版本的表才在查询时使用，
+// 不记录到历史中；这类表的 ALTER 因为不知道之前的结构而被忽略
+func (h *History) SetCurrentSchema(provider TableMetaProvider) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.current = provider
+}
+
+// AddBaseline 添加一张表的基线版本，历史中已有该表时忽略
+func (h *History) AddBaseline(schema, table string, meta *models.TableMeta) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := tableKey(schema, table)
+	if _, ok := h.tables[key]; !ok {
+		h.tables[key] = []TableVersion{{Meta: meta}}
+	}
+}
+
+// TableAt 返回表在 pos 位置生效的表结构，表未知、尚未创建或已被删除时返回 nil
+// 历史中没有该表的任何版本时返回当前的表结构（见 SetCurrentSchema）
+func (h *History) TableAt(schema, table string, pos Position) *models.TableMeta {
+	versions := h.versions(schema, table)
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Pos.Compare(pos) <= 0 {
+			return versions[i].Meta
+		}
+	}
+	if len(versions) > 0 {
+		return nil
+	}
+
+	h.mu.RLock()
+	current := h.current
+	h.mu.RUnlock()
+	if current == nil {
+		return nil
+	}
+	meta, err := current.GetTableMeta(schema, table)
+	if err != nil {
+		return nil
+	}
+	return meta
+}
+
+// Versions 返回表的所有版本
+func (h *History) Versions(schema, table string) []TableVersion {
+	versions := h.versions(schema, table)
+	return append([]TableVersion(nil), versions...)
+}
+
+// versions 返回表的版本列表，历史中没有该表时尝试加载基线
+func (h *History) versions(schema, table string) []TableVersion {
+	key := tableKey(schema, table)
+	h.mu.RLock()
+	versions, ok := h.tables[key]
+	baseline := h.baseline
+	h.mu.RUnlock()
+	if ok || baseline == nil {
+		return versions
+	}
+
+	meta, err := baseline.GetTableMeta(schema, table)
+	if err != nil || meta == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if versions, ok := h.tables[key]; ok {
+		return versions
+	}
+	h.tables[key] = []TableVersion{{Meta: meta}}
+	return h.tables[key]
+}
+
+// addVersion 记录表在 pos 位置的新版本，同一位置已有版本时（重复重放同一段 binlog）忽略
+func (h *History) addVersion(schema, table string, version TableVersion) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := tableKey(schema, table)
+	versions := h.tables[key]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].Pos.Compare(version.Pos) >= 0 })
+	if i < len(versions) && versions[i].Pos.Compare(version.Pos) == 0 {
+		return
+	}
+	versions = append(versions, TableVersion{})
+	copy(versions[i+1:], versions[i:])
+	versions[i] = version
+	h.tables[key] = versions
+}
+
+// tablesInSchema 返回历史中属于 schema 的所有表名
+func (h *History) tablesInSchema(schema string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var tables []string
+	prefix := schema + "."
+	for key := range h.tables {
+		if strings.HasPrefix(key, prefix) {
+			tables = append(tables, strings.TrimPrefix(key, prefix))
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// tableKey 返回 schema.table 形式的键
+func tableKey(schema, table string) string {
+	return schema + "." + table
+}
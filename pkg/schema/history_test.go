@@ -0,0 +1,289 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+func pos(file int, offset uint32) Position {
+	return Position{LogName: fmt.Sprintf("mysql-bin.%06d", file), LogPos: offset}
+}
+
+func columnNames(meta *models.TableMeta) []string {
+	if meta == nil {
+		return nil
+	}
+	var names []string
+	for _, column := range meta.Columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func mustApply(t *testing.T, h *History, schema, query string, p Position) {
+	t.Helper()
+	if err := h.ApplyDDL(schema, query, p); err != nil {
+		t.Fatalf("apply %q: %v", query, err)
+	}
+}
+
+func TestApplyDDLReplay(t *testing.T) {
+	h := NewHistory()
+	mustApply(t, h, "app", "CREATE TABLE users (id INT UNSIGNED NOT NULL, name VARCHAR(64), PRIMARY KEY (id))", pos(1, 100))
+	mustApply(t, h, "app", "ALTER TABLE users ADD COLUMN email VARCHAR(128) AFTER id", pos(1, 300))
+	mustApply(t, h, "other", "ALTER TABLE app.users DROP COLUMN name, CHANGE COLUMN email mail VARCHAR(255) NOT NULL DEFAULT ''", pos(2, 200))
+	mustApply(t, h, "app", "RENAME TABLE users TO members", pos(2, 400))
+
+	tests := []struct {
+		table string
+		at    Position
+		want  []string
+	}{
+		{"users", pos(1, 50), nil},
+		{"users", pos(1, 200), []string{"id", "name"}},
+		{"users", pos(1, 300), []string{"id", "email", "name"}},
+		{"users", pos(2, 100), []string{"id", "email", "name"}},
+		{"users", pos(2, 300), []string{"id", "mail"}},
+		{"users", pos(2, 500), nil},
+		{"members", pos(2, 500), []string{"id", "mail"}},
+	}
+	for _, tt := range tests {
+		if got := columnNames(h.TableAt("app", tt.table, tt.at)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s at %s: expected %v, got %v", tt.table, tt.at, tt.want, got)
+		}
+	}
+
+	meta := h.TableAt("app", "members", pos(3, 4))
+	if !reflect.DeepEqual(meta.PrimaryKey, []string{"id"}) {
+		t.Errorf("expected primary key [id], got %v", meta.PrimaryKey)
+	}
+	if !meta.Columns[0].Unsigned || meta.Columns[0].Nullable {
+		t.Errorf("unexpected id column %+v", meta.Columns[0])
+	}
+	if mail := meta.Columns[1]; mail.Nullable || mail.Default != "" {
+		t.Errorf("unexpected mail column %+v", mail)
+	}
+}
+
+func TestApplyDDLIgnoresOtherStatements(t *testing.T) {
+	h := NewHistory()
+	for _, query := range []string{"BEGIN", "COMMIT", "INSERT INTO t VALUES (1)", "/* comment */ FLUSH TABLES", "GRANT SELECT ON *.* TO 'u'@'%'"} {
+		mustApply(t, h, "app", query, pos(1, 100))
+	}
+	// 表结构未知时 ALTER 不产生版本
+	mustApply(t, h, "app", "ALTER TABLE unknown ADD COLUMN c INT", pos(1, 200))
+	mustApply(t, h, "app", "DROP TABLE IF EXISTS unknown", pos(1, 300))
+	if versions := h.Versions("app", "unknown"); len(versions) != 0 {
+		t.Errorf("expected no versions, got %+v", versions)
+	}
+	if err := h.ApplyDDL("app", "CREATE TABLE broken (", pos(1, 400)); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestApplyDDLRenameSwap(t *testing.T) {
+	h := NewHistory()
+	mustApply(t, h, "app", "CREATE TABLE a (x INT)", pos(1, 100))
+	mustApply(t, h, "app", "CREATE TABLE b (y INT)", pos(1, 200))
+	mustApply(t, h, "app", "RENAME TABLE a TO tmp, b TO a, tmp TO b", pos(1, 300))
+
+	if got := columnNames(h.TableAt("app", "a", pos(1, 400))); !reflect.DeepEqual(got, []string{"y"}) {
+		t.Errorf("expected a to have column y, got %v", got)
+	}
+	if got := columnNames(h.TableAt("app", "b", pos(1, 400))); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Errorf("expected b to have column x, got %v", got)
+	}
+}
+
+// staticProvider 固定的基线表结构
+type staticProvider map[string]*models.TableMeta
+
+func (p staticProvider) GetTableMeta(schema, table string) (*models.TableMeta, error) {
+	if meta, ok := p[schema+"."+table]; ok {
+		return meta, nil
+	}
+	return nil, fmt.Errorf("table %s.%s not found", schema, table)
+}
+
+func TestHistoryBaselineAndPersistence(t *testing.T) {
+	h := NewHistory()
+	h.SetBaseline(staticProvider{"app.orders": {
+		Columns:    []models.ColumnMeta{{Name: "id"}, {Name: "amount"}},
+		PrimaryKey: []string{"id"},
+	}})
+	mustApply(t, h, "app", "ALTER TABLE orders ADD COLUMN note TEXT FIRST", pos(5, 1000))
+
+	if got := columnNames(h.TableAt("app", "orders", pos(5, 500))); !reflect.DeepEqual(got, []string{"id", "amount"}) {
+		t.Errorf("expected baseline before ALTER, got %v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := h.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// 重复处理同一段 binlog 时已记录的 DDL 不会再次应用
+	mustApply(t, loaded, "app", "ALTER TABLE orders ADD COLUMN note TEXT FIRST", pos(5, 1000))
+	if versions := loaded.Versions("app", "orders"); len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	if got := columnNames(loaded.TableAt("app", "orders", pos(5, 2000))); !reflect.DeepEqual(got, []string{"note", "id", "amount"}) {
+		t.Errorf("unexpected columns after reload %v", got)
+	}
+	if got := loaded.TableAt("app", "orders", pos(5, 2000)).PrimaryKey; !reflect.DeepEqual(got, []string{"id"}) {
+		t.Errorf("unexpected primary key after reload %v", got)
+	}
+}
+
+func TestHistoryCurrentSchemaIsNotReplayed(t *testing.T) {
+	// 当前结构已经包含读取范围内的 ADD COLUMN
+	current := staticProvider{
+		"app.orders": {Columns: []models.ColumnMeta{{Name: "id"}, {Name: "note"}, {Name: "amount"}}},
+		"app.logs":   {Columns: []models.ColumnMeta{{Name: "id"}, {Name: "msg"}}},
+	}
+	const alter = "ALTER TABLE orders ADD COLUMN note TEXT AFTER id"
+
+	t.Run("current schema only", func(t *testing.T) {
+		h := NewHistory()
+		h.SetCurrentSchema(current)
+		mustApply(t, h, "app", alter, pos(5, 1000))
+
+		// 不知道 ALTER 之前的结构，不记录版本，也不会在当前结构上再加一次列
+		if versions := h.Versions("app", "orders"); len(versions) != 0 {
+			t.Errorf("expected no versions, got %+v", versions)
+		}
+		if got := columnNames(h.TableAt("app", "orders", pos(5, 2000))); !reflect.DeepEqual(got, []string{"id", "note", "amount"}) {
+			t.Errorf("unexpected columns after ALTER %v", got)
+		}
+	})
+
+	t.Run("start baseline and current schema", func(t *testing.T) {
+		h := NewHistory()
+		h.SetBaseline(staticProvider{"app.orders": {Columns: []models.ColumnMeta{{Name: "id"}, {Name: "amount"}}}})
+		h.SetCurrentSchema(current)
+		mustApply(t, h, "app", alter, pos(5, 1000))
+
+		// ALTER 在起始位置的结构上重放，之前的事件使用 ALTER 之前的列
+		if got := columnNames(h.TableAt("app", "orders", pos(5, 500))); !reflect.DeepEqual(got, []string{"id", "amount"}) {
+			t.Errorf("unexpected columns before ALTER %v", got)
+		}
+		if got := columnNames(h.TableAt("app", "orders", pos(5, 2000))); !reflect.DeepEqual(got, []string{"id", "note", "amount"}) {
+			t.Errorf("unexpected columns after ALTER %v", got)
+		}
+	})
+
+	t.Run("table created in range", func(t *testing.T) {
+		h := NewHistory()
+		h.SetCurrentSchema(current)
+		mustApply(t, h, "app", "CREATE TABLE logs (id INT PRIMARY KEY, msg TEXT)", pos(5, 1000))
+
+		// 建表之前表不存在，不使用当前结构
+		if meta := h.TableAt("app", "logs", pos(5, 500)); meta != nil {
+			t.Errorf("expected no table before CREATE, got %v", columnNames(meta))
+		}
+		if got := columnNames(h.TableAt("app", "logs", pos(5, 2000))); !reflect.DeepEqual(got, []string{"id", "msg"}) {
+			t.Errorf("unexpected columns after CREATE %v", got)
+		}
+	})
+}
+
+// listSource 按顺序返回固定事件的数据源
+type listSource struct {
+	events []*models.Event
+}
+
+func (s *listSource) Open(ctx context.Context) error { return nil }
+func (s *listSource) Close() error                   { return nil }
+func (s *listSource) HasMore() bool                  { return len(s.events) > 0 }
+func (s *listSource) Read() (*models.Event, error) {
+	if len(s.events) == 0 {
+		return nil, fmt.Errorf("EOF")
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+func TestTrackingSourceMapsColumnsByPosition(t *testing.T) {
+	row := func(offset uint32, values map[string]interface{}) *models.Event {
+		return &models.Event{LogName: "mysql-bin.000001", LogPos: offset, Database: "app", Table: "t", Action: "INSERT", AfterValues: values}
+	}
+	// 文件数据源把 DDL 标记为 QUERY，MySQL 数据源标记为 CREATE/ALTER 等
+	ddl := func(offset uint32, action, query string) *models.Event {
+		return &models.Event{LogName: "mysql-bin.000001", LogPos: offset, EventType: "QueryEvent", Database: "app", Action: action, SQL: query}
+	}
+	ds := &listSource{events: []*models.Event{
+		ddl(100, "CREATE", "CREATE TABLE t (id INT PRIMARY KEY, a INT)"),
+		row(200, map[string]interface{}{"col_0": 1, "col_1": 2}),
+		ddl(300, "QUERY", "ALTER TABLE t DROP COLUMN a, ADD COLUMN b INT"),
+		row(400, map[string]interface{}{"col_0": 3, "col_1": 4}),
+	}}
+
+	path := filepath.Join(t.TempDir(), "history.json")
+	ts := NewTrackingSource(ds, path)
+	if err := ts.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var rows []*models.Event
+	for ts.HasMore() {
+		event, err := ts.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Action == "INSERT" {
+			rows = append(rows, event)
+		}
+	}
+	if err := ts.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(rows[0].AfterValues, map[string]interface{}{"id": 1, "a": 2}) {
+		t.Errorf("unexpected first row %v", rows[0].AfterValues)
+	}
+	if !reflect.DeepEqual(rows[1].AfterValues, map[string]interface{}{"id": 3, "b": 4}) {
+		t.Errorf("unexpected second row %v", rows[1].AfterValues)
+	}
+	if !reflect.DeepEqual(rows[1].PrimaryKey, []string{"id"}) {
+		t.Errorf("unexpected primary key %v", rows[1].PrimaryKey)
+	}
+
+	// 后续运行从持久化的历史中取得表结构，不需要重新读到 DDL
+	ds = &listSource{events: []*models.Event{row(250, map[string]interface{}{"col_0": 5, "col_1": 6})}}
+	ts = NewTrackingSource(ds, path)
+	if err := ts.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	event, _ := ts.Read()
+	if !reflect.DeepEqual(event.AfterValues, map[string]interface{}{"id": 5, "a": 6}) {
+		t.Errorf("unexpected row from persisted history %v", event.AfterValues)
+	}
+}
+
+func TestPositionCompareBySequence(t *testing.T) {
+	cases := []struct {
+		a, b Position
+		want int
+	}{
+		{Position{LogName: "mysql-bin.999999", LogPos: 100}, Position{LogName: "mysql-bin.1000000", LogPos: 4}, -1},
+		{Position{LogName: "mysql-bin.1000000", LogPos: 4}, Position{LogName: "mysql-bin.999999", LogPos: 100}, 1},
+		{pos(2, 100), pos(2, 200), -1},
+		{pos(2, 100), pos(2, 100), 0},
+		{Position{}, pos(1, 4), -1},
+		{Position{LogName: "a-bin.000002"}, Position{LogName: "b-bin.000001"}, -1},
+	}
+	for _, c := range cases {
+		if got := c.a.Compare(c.b); got != c.want {
+			t.Errorf("%s vs %s: got %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// LoadSchemaFile 从文件加载表结构，文件内容为 CREATE TABLE 语句
+// （例如 mysqldump --no-data 的输出或多条 SHOW CREATE TABLE 的结果），
+// 或 schema snapshot 命令生成的 JSON 快照
+func LoadSchemaFile(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+	var catalog *Catalog
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		catalog, err = parseSnapshot(data)
+	} else {
+		catalog, err = ParseSchemaSQL(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return catalog, nil
+}
+
+// ParseSchemaSQL 解析 SQL 文本中的 CREATE TABLE 语句
+// USE 语句切换之后未指定库名的表所属的库；其他语句（SET、DROP TABLE IF EXISTS、LOCK TABLES 等）忽略；
+// 无法解析的 CREATE TABLE 打印警告后跳过，一张表都没有解析出来时返回错误
+func ParseSchemaSQL(sql string) (*Catalog, error) {
+	catalog := NewCatalog()
+	p := parser.New()
+	currentSchema := ""
+	skipped := 0
+
+	for _, stmt := range splitStatements(sql) {
+		keywords := strings.Fields(strings.ToUpper(stripLeadingComments(stmt)))
+		if len(keywords) == 0 {
+			continue
+		}
+
+		switch {
+		case keywords[0] == "USE" && len(keywords) > 1:
+			currentSchema = unquoteIdentifier(strings.Fields(stripLeadingComments(stmt))[1])
+
+		case keywords[0] == "CREATE" && containsTableKeyword(keywords):
+			nodes, _, err := p.Parse(stmt, "", "")
+			if err != nil {
+				log.Printf("schema 文件: 跳过无法解析的语句: %v", err)
+				skipped++
+				continue
+			}
+			for _, node := range nodes {
+				create, ok := node.(*ast.CreateTableStmt)
+				if !ok || len(create.Cols) == 0 {
+					continue
+				}
+				schema := create.Table.Schema.O
+				if schema == "" {
+					schema = currentSchema
+				}
+				catalog.Add(schema, create.Table.Name.O, TableMetaFromCreate(create))
+			}
+		}
+	}
+
+	if catalog.Len() == 0 {
+		if skipped > 0 {
+			return nil, fmt.Errorf("none of the %d CREATE TABLE statements could be parsed", skipped)
+		}
+		return nil, fmt.Errorf("no CREATE TABLE statements found")
+	}
+	return catalog, nil
+}
+
+// containsTableKeyword 判断 CREATE 语句是否为建表语句（CREATE [TEMPORARY] TABLE）
+func containsTableKeyword(keywords []string) bool {
+	for _, keyword := range keywords[1:] {
+		switch keyword {
+		case "TABLE":
+			return true
+		case "TEMPORARY":
+			continue
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// stripLeadingComments 去掉语句开头的空白和注释
+func stripLeadingComments(stmt string) string {
+	s := strings.TrimSpace(stmt)
+	for {
+		switch {
+		case strings.HasPrefix(s, "/*"):
+			end := strings.Index(s, "*/")
+			if end < 0 {
+				return ""
+			}
+			s = strings.TrimSpace(s[end+2:])
+		case strings.HasPrefix(s, "--"), strings.HasPrefix(s, "#"):
+			end := strings.Index(s, "\n")
+			if end < 0 {
+				return ""
+			}
+			s = strings.TrimSpace(s[end+1:])
+		default:
+			return s
+		}
+	}
+}
+
+// unquoteIdentifier 去掉标识符两边的反引号和末尾的分号
+func unquoteIdentifier(name string) string {
+	name = strings.TrimSuffix(name, ";")
+	if len(name) >= 2 && name[0] == '`' && name[len(name)-1] == '`' {
+		name = strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+	}
+	return name
+}
+
+// splitStatements 按分号拆分 SQL 文本，忽略引号和注释中的分号，支持 mysql 客户端的 DELIMITER 命令
+func splitStatements(sql string) []string {
+	var stmts []string
+	delimiter := ";"
+	var current strings.Builder
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(sql); {
+		// DELIMITER 只能出现在行首
+		if (i == 0 || sql[i-1] == '\n') && strings.HasPrefix(strings.ToUpper(sql[i:]), "DELIMITER ") {
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			if d := strings.TrimSpace(sql[i+len("DELIMITER ") : i+end]); d != "" {
+				flush()
+				delimiter = d
+			}
+			i += end
+			continue
+		}
+
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(sql) && sql[end] != c {
+				if sql[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(sql))
+			current.WriteString(sql[i:end])
+			i = end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end = i + 2 + end + 2
+			}
+			current.WriteString(sql[i:end])
+			i = end
+		case c == '#' || (strings.HasPrefix(sql[i:], "--") && (i+2 == len(sql) || sql[i+2] == ' ' || sql[i+2] == '\t' || sql[i+2] == '\n' || sql[i+2] == '\r')):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			i += end
+		case strings.HasPrefix(sql[i:], delimiter):
+			flush()
+			i += len(delimiter)
+		default:
+			current.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return stmts
+}
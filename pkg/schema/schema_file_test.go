@@ -0,0 +1,134 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/cache"
+)
+
+const testDump = `-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)
+--
+-- Host: 127.0.0.1    Database: shop
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET NAMES utf8mb4 */;
+
+CREATE DATABASE /*!32312 IF NOT EXISTS*/ ` + "`shop`" + ` /*!40100 DEFAULT CHARACTER SET utf8mb4 */;
+
+USE ` + "`shop`" + `;
+
+--
+-- Table structure for table ` + "`orders`" + `
+--
+
+DROP TABLE IF EXISTS ` + "`orders`" + `;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+CREATE TABLE ` + "`orders`" + ` (
+  ` + "`id`" + ` bigint unsigned NOT NULL AUTO_INCREMENT,
+  ` + "`user_id`" + ` int NOT NULL,
+  ` + "`note`" + ` varchar(255) DEFAULT 'a;b',
+  ` + "`status`" + ` enum('new','paid') NOT NULL DEFAULT 'new',
+  ` + "`created_at`" + ` datetime DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (` + "`id`" + `,` + "`user_id`" + `),
+  KEY ` + "`idx_user`" + ` (` + "`user_id`" + `)
+) ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+/*!40101 SET character_set_client = @saved_cs_client */;
+
+DELIMITER ;;
+/*!50003 CREATE*/ /*!50017 DEFINER=` + "`root`@`%`" + `*/ /*!50003 TRIGGER ` + "`trg`" + ` BEFORE INSERT ON ` + "`orders`" + ` FOR EACH ROW SET NEW.note = 'x'; */;;
+DELIMITER ;
+
+CREATE TABLE audit.log (id INT PRIMARY KEY, msg TEXT);
+`
+
+func TestParseSchemaSQL(t *testing.T) {
+	catalog, err := ParseSchemaSQL(testDump)
+	if err != nil {
+		t.Fatalf("ParseSchemaSQL failed: %v", err)
+	}
+	if !reflect.DeepEqual(catalog.Tables(), []string{"audit.log", "shop.orders"}) {
+		t.Fatalf("unexpected tables %v", catalog.Tables())
+	}
+
+	meta, err := catalog.GetTableMeta("shop", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := columnNames(meta); !reflect.DeepEqual(got, []string{"id", "user_id", "note", "status", "created_at"}) {
+		t.Errorf("unexpected columns %v", got)
+	}
+	if !reflect.DeepEqual(meta.PrimaryKey, []string{"id", "user_id"}) {
+		t.Errorf("unexpected primary key %v", meta.PrimaryKey)
+	}
+	id := meta.Columns[0]
+	if !id.Unsigned || id.Nullable || id.Type != "bigint(20) unsigned" {
+		t.Errorf("unexpected id column %+v", id)
+	}
+	if id.Charset != "" {
+		t.Errorf("expected no charset for numeric column, got %q", id.Charset)
+	}
+	if note := meta.Columns[2]; !note.Nullable || note.Default != "a;b" || note.Charset != "utf8mb4" {
+		t.Errorf("unexpected note column %+v", note)
+	}
+	if status := meta.Columns[3]; status.Type != "enum('new','paid')" || status.Default != "new" {
+		t.Errorf("unexpected status column %+v", status)
+	}
+
+	latin, err := ParseSchemaSQL("CREATE TABLE t (a varchar(10), b text CHARACTER SET gbk, c varchar(5) COLLATE latin2_bin, d blob) DEFAULT CHARSET=latin1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm, _ := latin.GetTableMeta("", "t")
+	var charsets []string
+	for _, column := range tm.Columns {
+		charsets = append(charsets, column.Charset)
+	}
+	if !reflect.DeepEqual(charsets, []string{"latin1", "gbk", "latin2", ""}) {
+		t.Errorf("unexpected column charsets %q", charsets)
+	}
+
+	log, _ := catalog.GetTableMeta("audit", "log")
+	if !reflect.DeepEqual(log.PrimaryKey, []string{"id"}) {
+		t.Errorf("unexpected primary key for audit.log %v", log.PrimaryKey)
+	}
+}
+
+func TestParseSchemaSQLWithoutDatabase(t *testing.T) {
+	// SHOW CREATE TABLE 的结果没有库名，匹配任意库中的同名表
+	catalog, err := ParseSchemaSQL("CREATE TABLE `t` (`a` int, `b` int)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := catalog.GetTableMeta("anydb", "t"); err != nil {
+		t.Errorf("expected unqualified table to match: %v", err)
+	}
+	if _, err := catalog.GetTableMeta("anydb", "other"); err == nil {
+		t.Error("expected error for unknown table")
+	}
+
+	if _, err := ParseSchemaSQL("SET NAMES utf8mb4;"); err == nil {
+		t.Error("expected error when no CREATE TABLE found")
+	}
+}
+
+func TestSchemaFileAsMetaCacheBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.sql")
+	if err := os.WriteFile(path, []byte(testDump), 0644); err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := LoadSchemaFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mc := cache.NewMetaCacheWithLoader(catalog.GetTableMeta, 100, nil)
+	defer mc.Close()
+	if name := mc.GetColumnName("shop", "orders", 1); name != "user_id" {
+		t.Errorf("expected user_id, got %s", name)
+	}
+	if name := mc.GetColumnName("shop", "missing", 1); name != "col_1" {
+		t.Errorf("expected col_1 fallback, got %s", name)
+	}
+}
@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/aitoooooo/binlogx/pkg/util"
+)
+
+// snapshotVersion 快照文件格式版本
+const snapshotVersion = 1
+
+// snapshotFile 快照文件格式
+type snapshotFile struct {
+	Version   int                          `json:"version"`
+	CreatedAt time.Time                    `json:"created_at"`
+	Tables    map[string]*models.TableMeta `json:"tables"` // schema.table -> 表结构
+}
+
+// snapshotColumnsQuery 查询所有用户表的列定义，系统库和视图除外
+// 不带参数的查询走文本协议，避免在受限账号或代理上使用预处理语句
+const snapshotColumnsQuery = `
+	SELECT c.TABLE_SCHEMA, c.TABLE_NAME, c.COLUMN_NAME, c.COLUMN_TYPE, c.IS_NULLABLE, c.COLUMN_DEFAULT, c.CHARACTER_SET_NAME
+	FROM INFORMATION_SCHEMA.COLUMNS c
+	JOIN INFORMATION_SCHEMA.TABLES t ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+	WHERE t.TABLE_TYPE = 'BASE TABLE'
+	  AND c.TABLE_SCHEMA NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')
+	ORDER BY c.TABLE_SCHEMA, c.TABLE_NAME, c.ORDINAL_POSITION
+`
+
+// snapshotKeysQuery 查询主键和唯一索引的列
+const snapshotKeysQuery = `
+	SELECT TABLE_SCHEMA, TABLE_NAME, INDEX_NAME, COLUMN_NAME
+	FROM INFORMATION_SCHEMA.STATISTICS
+	WHERE NON_UNIQUE = 0
+	  AND TABLE_SCHEMA NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')
+	ORDER BY TABLE_SCHEMA, TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX
+`
+
+// TakeSnapshot 从 INFORMATION_SCHEMA 一次性读取所有匹配表的表结构
+// match 为 nil 时读取全部用户表
+func TakeSnapshot(db *sql.DB, match func(schema, table string) bool) (*Catalog, error) {
+	catalog := NewCatalog()
+
+	rows, err := db.Query(snapshotColumnsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schema, table, name, columnType, isNullable string
+		var defaultValue, charset sql.NullString
+		if err := rows.Scan(&schema, &table, &name, &columnType, &isNullable, &defaultValue, &charset); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		if match != nil && !match(schema, table) {
+			continue
+		}
+
+		column := models.ColumnMeta{
+			Name:     name,
+			Type:     columnType,
+			Unsigned: strings.Contains(strings.ToLower(columnType), "unsigned"),
+			Nullable: isNullable == "YES",
+			Elements: util.ParseElements(columnType),
+			Charset:  charset.String,
+		}
+		if defaultValue.Valid {
+			column.Default = defaultValue.String
+		}
+
+		meta, _ := catalog.GetTableMeta(schema, table)
+		if meta == nil {
+			meta = &models.TableMeta{}
+			catalog.Add(schema, table, meta)
+		}
+		meta.Columns = append(meta.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+
+	if err := loadSnapshotKeys(db, catalog); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// loadSnapshotKeys 读取主键和唯一索引，填充到已读取的表中
+// 含表达式的函数索引（COLUMN_NAME 为 NULL）无法按列定位行，忽略
+func loadSnapshotKeys(db *sql.DB, catalog *Catalog) error {
+	rows, err := db.Query(snapshotKeysQuery)
+	if err != nil {
+		return fmt.Errorf("failed to query keys: %w", err)
+	}
+	defer rows.Close()
+
+	type indexKey struct{ table, index string }
+	keys := make(map[indexKey][]string)
+	var order []indexKey
+	skipped := make(map[indexKey]bool)
+	for rows.Next() {
+		var schema, table, index string
+		var column sql.NullString
+		if err := rows.Scan(&schema, &table, &index, &column); err != nil {
+			return fmt.Errorf("failed to scan key: %w", err)
+		}
+		if _, ok := catalog.tables[tableKey(schema, table)]; !ok {
+			continue
+		}
+		key := indexKey{tableKey(schema, table), index}
+		if !column.Valid {
+			skipped[key] = true
+			continue
+		}
+		if _, ok := keys[key]; !ok {
+			order = append(order, key)
+		}
+		keys[key] = append(keys[key], column.String)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query keys: %w", err)
+	}
+
+	for _, key := range order {
+		if skipped[key] {
+			continue
+		}
+		meta := catalog.tables[key.table]
+		if key.index == "PRIMARY" {
+			meta.PrimaryKey = keys[key]
+		} else {
+			meta.UniqueKeys = append(meta.UniqueKeys, keys[key])
+		}
+	}
+	return nil
+}
+
+// SaveSnapshot 将表结构写入快照文件（先写临时文件再重命名）
+func (c *Catalog) SaveSnapshot(path string) error {
+	data, err := json.MarshalIndent(snapshotFile{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now().UTC(),
+		Tables:    c.tables,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema snapshot: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create snapshot directory: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write schema snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write schema snapshot: %w", err)
+	}
+	return nil
+}
+
+// parseSnapshot 解析快照文件内容，由 LoadSchemaFile 按文件内容识别后调用
+func parseSnapshot(data []byte) (*Catalog, error) {
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid schema snapshot: %w", err)
+	}
+	if file.Version > snapshotVersion {
+		return nil, fmt.Errorf("unsupported schema snapshot version %d", file.Version)
+	}
+	if len(file.Tables) == 0 {
+		return nil, fmt.Errorf("schema snapshot contains no tables")
+	}
+
+	catalog := NewCatalog()
+	for key, meta := range file.Tables {
+		if meta != nil {
+			catalog.tables[key] = meta
+		}
+	}
+	return catalog, nil
+}
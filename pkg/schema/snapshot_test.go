@@ -0,0 +1,180 @@
+package schema
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/source"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/server"
+)
+
+// fakeInformationSchema 本地模拟的 MySQL，只回答快照需要的两条 INFORMATION_SCHEMA 查询
+type fakeInformationSchema struct {
+	server.EmptyHandler
+	columns [][]interface{}
+	keys    [][]interface{}
+}
+
+func (f *fakeInformationSchema) HandleQuery(query string) (*mysql.Result, error) {
+	var rs *mysql.Resultset
+	var err error
+	switch {
+	case strings.Contains(query, "INFORMATION_SCHEMA.COLUMNS"):
+		rs, err = mysql.BuildSimpleResultset([]string{"TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "COLUMN_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "CHARACTER_SET_NAME"}, f.columns, false)
+	case strings.Contains(query, "INFORMATION_SCHEMA.STATISTICS"):
+		rs, err = mysql.BuildSimpleResultset([]string{"TABLE_SCHEMA", "TABLE_NAME", "INDEX_NAME", "COLUMN_NAME"}, f.keys, false)
+	default:
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func startFakeInformationSchema(t *testing.T, f *fakeInformationSchema) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	srv := server.NewServer("8.0.36", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				conn, err := srv.NewConn(c, "root", "pass", f)
+				if err != nil {
+					return
+				}
+				for !conn.Closed() {
+					if err := conn.HandleCommand(); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return fmt.Sprintf("root:pass@tcp(%s)/", listener.Addr().String())
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	dsn := startFakeInformationSchema(t, &fakeInformationSchema{
+		columns: [][]interface{}{
+			{"shop", "orders", "id", "bigint unsigned", "NO", nil, nil},
+			{"shop", "orders", "code", "varchar(32)", "NO", nil, "latin1"},
+			{"shop", "orders", "status", "enum('new','it''s paid')", "NO", "new", "utf8mb4"},
+			{"shop", "orders", "tags", "set('a','b')", "YES", nil, "utf8mb4"},
+			{"shop", "users", "id", "int", "NO", nil, nil},
+			{"audit", "log", "id", "int", "NO", nil, nil},
+		},
+		keys: [][]interface{}{
+			{"shop", "orders", "PRIMARY", "id"},
+			{"shop", "orders", "uk_code", "code"},
+			{"shop", "orders", "uk_code", "status"},
+			{"shop", "orders", "uk_expr", nil},
+			{"audit", "log", "PRIMARY", "id"},
+		},
+	})
+	db, err := source.OpenDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	catalog, err := TakeSnapshot(db, func(schema, table string) bool { return schema == "shop" })
+	if err != nil {
+		t.Fatalf("TakeSnapshot failed: %v", err)
+	}
+	if !reflect.DeepEqual(catalog.Tables(), []string{"shop.orders", "shop.users"}) {
+		t.Fatalf("unexpected tables %v", catalog.Tables())
+	}
+
+	orders, _ := catalog.GetTableMeta("shop", "orders")
+	if !reflect.DeepEqual(orders.PrimaryKey, []string{"id"}) {
+		t.Errorf("unexpected primary key %v", orders.PrimaryKey)
+	}
+	if !reflect.DeepEqual(orders.UniqueKeys, [][]string{{"code", "status"}}) {
+		t.Errorf("unexpected unique keys %v", orders.UniqueKeys)
+	}
+	if id := orders.Columns[0]; !id.Unsigned || id.Nullable || id.Default != nil {
+		t.Errorf("unexpected id column %+v", id)
+	}
+	if code := orders.Columns[1]; code.Charset != "latin1" {
+		t.Errorf("unexpected code column %+v", code)
+	}
+	status := orders.Columns[2]
+	if status.Default != "new" || !reflect.DeepEqual(status.Elements, []string{"new", "it's paid"}) {
+		t.Errorf("unexpected status column %+v", status)
+	}
+	if tags := orders.Columns[3]; !tags.Nullable || !reflect.DeepEqual(tags.Elements, []string{"a", "b"}) {
+		t.Errorf("unexpected tags column %+v", tags)
+	}
+
+	// 快照文件可以直接作为 --schema-file 使用
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := catalog.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	loaded, err := LoadSchemaFile(path)
+	if err != nil {
+		t.Fatalf("LoadSchemaFile failed: %v", err)
+	}
+	reloaded, _ := loaded.GetTableMeta("shop", "orders")
+	if !reflect.DeepEqual(reloaded, orders) {
+		t.Errorf("snapshot round trip mismatch:\n got  %+v\n want %+v", reloaded, orders)
+	}
+}
+
+func TestLoadSchemaFileRejectsInvalidSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.json":  `{"version": 1, "tables": {}}`,
+		"future.json": `{"version": 99, "tables": {"a.b": {"columns": [{"name": "id"}]}}}`,
+		"broken.json": `{"version": 1, "tables": `,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSchemaFile(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSchemaFileKeysAndElements(t *testing.T) {
+	catalog, err := ParseSchemaSQL("CREATE TABLE t (id INT PRIMARY KEY, email VARCHAR(64) UNIQUE, a INT, b INT, kind ENUM('x','y'), UNIQUE KEY uk_ab (a, b))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := catalog.GetTableMeta("app", "t")
+	if !reflect.DeepEqual(meta.UniqueKeys, [][]string{{"email"}, {"a", "b"}}) {
+		t.Errorf("unexpected unique keys %v", meta.UniqueKeys)
+	}
+	if !reflect.DeepEqual(meta.Columns[4].Elements, []string{"x", "y"}) {
+		t.Errorf("unexpected enum elements %v", meta.Columns[4].Elements)
+	}
+
+	h := NewHistory()
+	h.SetBaseline(catalog)
+	mustApply(t, h, "app", "ALTER TABLE t DROP COLUMN a, CHANGE b c INT", pos(1, 100))
+	if got := h.TableAt("app", "t", pos(1, 200)).UniqueKeys; !reflect.DeepEqual(got, [][]string{{"email"}, {"c"}}) {
+		t.Errorf("unexpected unique keys after ALTER %v", got)
+	}
+	if !reflect.DeepEqual(meta.UniqueKeys, [][]string{{"email"}, {"a", "b"}}) {
+		t.Errorf("baseline modified by ALTER: %v", meta.UniqueKeys)
+	}
+}
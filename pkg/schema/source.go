@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/aitoooooo/binlogx/pkg/source"
+)
+
+// queryEventType QUERY_EVENT 的事件类型名称
+const queryEventType = "QueryEvent"
+
+// TrackingSource 包装数据源，按事件顺序重放 DDL，
+// 并把行事件中的 col_N 占位符按事件所在位置生效的表结构映射为列名
+// 数据源只有一个读取者（处理器的生产者），因此 DDL 一定先于之后的行事件生效
+type TrackingSource struct {
+	source.DataSource
+	history *History
+	path    string // 持久化文件，为空时不加载也不保存
+}
+
+// NewTrackingSource 创建带表结构历史的数据源，path 不为空时在 Open 时加载、Close 时保存
+func NewTrackingSource(ds source.DataSource, path string) *TrackingSource {
+	return &TrackingSource{DataSource: ds, history: NewHistory(), path: path}
+}
+
+// History 返回表结构历史
+func (ts *TrackingSource) History() *History {
+	return ts.history
+}
+
+// SetBaseline 设置起始位置表结构的来源，见 History.SetBaseline
+func (ts *TrackingSource) SetBaseline(provider TableMetaProvider) {
+	ts.history.SetBaseline(provider)
+}
+
+// SetCurrentSchema 设置当前表结构的来源，见 History.SetCurrentSchema
+func (ts *TrackingSource) SetCurrentSchema(provider TableMetaProvider) {
+	ts.history.SetCurrentSchema(provider)
+}
+
+// Open 加载持久化的表结构历史并打开数据源
+func (ts *TrackingSource) Open(ctx context.Context) error {
+	if ts.path != "" {
+		history, err := LoadHistory(ts.path)
+		if err != nil {
+			return err
+		}
+		history.SetBaseline(ts.history.baseline)
+		history.SetCurrentSchema(ts.history.current)
+		ts.history = history
+	}
+	return ts.DataSource.Open(ctx)
+}
+
+// Close 关闭数据源并保存表结构历史
+func (ts *TrackingSource) Close() error {
+	err := ts.DataSource.Close()
+	if ts.path != "" {
+		if saveErr := ts.history.Save(ts.path); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
+
+// Read 读取下一个事件，DDL 更新表结构历史，行事件映射列名
+func (ts *TrackingSource) Read() (*models.Event, error) {
+	event, err := ts.DataSource.Read()
+	if err != nil || event == nil {
+		return event, err
+	}
+
+	pos := Position{LogName: event.LogName, LogPos: event.LogPos}
+	if event.EventType == queryEventType {
+		// 不同数据源对 DDL 的 Action 标记不同（QUERY 或 CREATE/ALTER 等），按事件类型和语句判断
+		if isDDL(event.SQL) {
+			if err := ts.history.ApplyDDL(event.Database, event.SQL, pos); err != nil {
+				// 无法解析的 DDL 不影响事件处理，之后该表按已知的结构映射
+				log.Printf("表结构历史: %v", err)
+			}
+		}
+		return event, nil
+	}
+
+	switch event.Action {
+	case "INSERT", "UPDATE", "DELETE":
+		if !hasPlaceholders(event.BeforeValues) && !hasPlaceholders(event.AfterValues) {
+			break
+		}
+		meta := ts.history.TableAt(event.Database, event.Table, pos)
+		if meta == nil {
+			break
+		}
+		event.BeforeValues = MapColumns(event.BeforeValues, meta)
+		event.AfterValues = MapColumns(event.AfterValues, meta)
+		event.Columns = MapColumnList(event.Columns, meta)
+		if event.PrimaryKey == nil {
+			event.PrimaryKey = meta.PrimaryKey
+		}
+	}
+	return event, nil
+}
+
+// MapColumns 将 col_N 占位符映射为 meta 中第 N 列的列名，超出范围的列保持不变
+func MapColumns(values map[string]interface{}, meta *models.TableMeta) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if idx, ok := placeholderIndex(key); ok && idx < len(meta.Columns) {
+			result[meta.Columns[idx].Name] = value
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// MapColumnList 将列名列表中的 col_N 占位符映射为 meta 中第 N 列的列名，返回新的列表
+func MapColumnList(columns []string, meta *models.TableMeta) []string {
+	if columns == nil {
+		return nil
+	}
+	result := make([]string, len(columns))
+	for i, column := range columns {
+		if idx, ok := placeholderIndex(column); ok && idx < len(meta.Columns) {
+			column = meta.Columns[idx].Name
+		}
+		result[i] = column
+	}
+	return result
+}
+
+// hasPlaceholders 检查是否还有未映射的 col_N 列
+func hasPlaceholders(values map[string]interface{}) bool {
+	for key := range values {
+		if _, ok := placeholderIndex(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderIndex 解析 col_N 占位符中的列号
+func placeholderIndex(key string) (int, bool) {
+	if !strings.HasPrefix(key, "col_") {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(key[len("col_"):])
+	if err != nil || idx < 0 {
+		return 0, false
+	}
+	return idx, true
+}
@@ -0,0 +1,85 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// 支持的压缩格式
+const (
+	compressionNone = ""
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+	compressionXz   = "xz"
+)
+
+// 各压缩格式的文件头魔数
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// compressedSuffixes 压缩归档的文件名后缀，解析 binlog 序号和文件名时会去掉
+var compressedSuffixes = []string{".gz", ".zst", ".xz"}
+
+// detectCompression 根据文件头魔数识别压缩格式，不会消耗 br 中的数据
+func detectCompression(br *bufio.Reader) (string, error) {
+	head, err := br.Peek(len(xzMagic))
+	if err != nil && err != io.EOF {
+		return compressionNone, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return compressionGzip, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		return compressionZstd, nil
+	case bytes.HasPrefix(head, xzMagic):
+		return compressionXz, nil
+	}
+	return compressionNone, nil
+}
+
+// newDecompressReader 创建流式解压 reader，解压后的数据不落盘，适用于 GB 级归档文件
+func newDecompressReader(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case compressionGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return gr, nil
+	case compressionZstd:
+		// 单 goroutine 解码，避免为大文件预分配过多内存
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case compressionXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xz stream: %w", err)
+		}
+		return io.NopCloser(xr), nil
+	}
+	return nil, fmt.Errorf("unsupported compression: %s", compression)
+}
+
+// trimCompressedSuffix 去掉压缩归档的后缀，例如 mysql-bin.000123.gz -> mysql-bin.000123
+func trimCompressedSuffix(name string) string {
+	for _, suffix := range compressedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
@@ -0,0 +1,117 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func compressData(t *testing.T, compression string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch compression {
+	case compressionGzip:
+		w = gzip.NewWriter(&buf)
+	case compressionZstd:
+		w, err = zstd.NewWriter(&buf)
+	case compressionXz:
+		w, err = xz.NewWriter(&buf)
+	}
+	if err != nil {
+		t.Fatalf("create %s writer: %v", compression, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close %s writer: %v", compression, err)
+	}
+	return buf.Bytes()
+}
+
+// readAllQueries 读取数据源中的全部 QUERY 事件，返回 SQL 和所属文件名
+func readAllQueries(t *testing.T, fs *FileSource) (queries, logNames []string) {
+	t.Helper()
+	for {
+		event, err := fs.Read()
+		if err != nil {
+			if err.Error() != "EOF" {
+				t.Fatalf("read: %v", err)
+			}
+			return
+		}
+		if event != nil && event.Action == "QUERY" {
+			queries = append(queries, event.SQL)
+			logNames = append(logNames, event.LogName)
+		}
+	}
+}
+
+func TestFileSourceCompressed(t *testing.T) {
+	data := newBinlogBuilder().query("db", "q1").query("db", "q2").take()
+
+	tests := []struct {
+		compression string
+		suffix      string
+	}{
+		{compressionGzip, ".gz"},
+		{compressionZstd, ".zst"},
+		{compressionXz, ".xz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.compression, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "mysql-bin.000123"+tt.suffix)
+			if err := os.WriteFile(path, compressData(t, tt.compression, data), 0644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			fs := NewFileSource(path)
+			if err := fs.Open(context.Background()); err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer fs.Close()
+
+			queries, logNames := readAllQueries(t, fs)
+			assertNames(t, queries, "q1", "q2")
+			assertNames(t, logNames, "mysql-bin.000123", "mysql-bin.000123")
+		})
+	}
+}
+
+func TestFileSourceMixedArchiveDirectory(t *testing.T) {
+	dir := t.TempDir()
+	archived := compressData(t, compressionGzip, newBinlogBuilder().query("db", "q1").rotate("mysql-bin.000002").take())
+	if err := os.WriteFile(filepath.Join(dir, "mysql-bin.000001.gz"), archived, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	appendFile(t, filepath.Join(dir, "mysql-bin.000002"), newBinlogBuilder().query("db", "q2").take())
+
+	fs := NewFileSource(dir)
+	fs.SetStartPosition("mysql-bin.000001", 0)
+	if err := fs.Open(context.Background()); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer fs.Close()
+
+	queries, logNames := readAllQueries(t, fs)
+	assertNames(t, queries, "q1", "q2")
+	assertNames(t, logNames, "mysql-bin.000001", "mysql-bin.000002")
+}
+
+func TestDetectCompressionPlainBinlog(t *testing.T) {
+	data := newBinlogBuilder().take()
+	compression, err := detectCompression(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil || compression != compressionNone {
+		t.Fatalf("expected plain binlog, got %q (%v)", compression, err)
+	}
+}
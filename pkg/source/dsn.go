@@ -0,0 +1,195 @@
+package source
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// binlogx 专用的 DSN 参数，go-sql-driver 不认识这些参数，会被当作系统变量 SET 到会话上，
+// 因此解析后需要从 Params 中移除
+const (
+	dsnParamServerID  = "serverID"        // 复制用的 server_id，数字或 random（默认随机）
+	dsnParamHeartbeat = "heartbeatPeriod" // 主库心跳间隔，如 10s
+	dsnParamTLSCA     = "tlsCA"           // CA 证书文件（PEM）
+	dsnParamTLSCert   = "tlsCert"         // 客户端证书文件（PEM）
+	dsnParamTLSKey    = "tlsKey"          // 客户端私钥文件（PEM）
+)
+
+// 随机 server_id 的范围，避开常见的手工配置值（1、2、100、1000 等）
+const (
+	minRandomServerID = 1 << 20
+	maxRandomServerID = 1<<31 - 1
+)
+
+// dsnConfig 从 DSN 解析出的连接配置
+// 标准参数（tls、readTimeout、charset 等）由 go-sql-driver 解析，binlogx 专用参数单独解析
+type dsnConfig struct {
+	driver    *mysqldriver.Config // database/sql 使用的配置，已去掉 binlogx 专用参数
+	serverID  uint32              // 复制用的 server_id
+	heartbeat time.Duration       // 主库心跳间隔，0 表示不开启
+}
+
+// parseDSN 解析 DSN
+// 支持 go-sql-driver 的全部参数，以及 serverID、heartbeatPeriod、tlsCA、tlsCert、tlsKey
+func parseDSN(dsn string) (*dsnConfig, error) {
+	driver, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &dsnConfig{driver: driver}
+
+	params := make(map[string]string)
+	for _, name := range []string{dsnParamServerID, dsnParamHeartbeat, dsnParamTLSCA, dsnParamTLSCert, dsnParamTLSKey} {
+		if value, ok := driver.Params[name]; ok {
+			params[name] = value
+			delete(driver.Params, name)
+		}
+	}
+
+	// server_id：显式指定或随机生成，同一台服务器上的多个实例不能相同，否则会互相踢掉
+	switch value := params[dsnParamServerID]; value {
+	case "", "random":
+		cfg.serverID = randomServerID()
+	default:
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid %s %q, must be a positive integer or random", dsnParamServerID, value)
+		}
+		cfg.serverID = uint32(id)
+	}
+
+	if value := params[dsnParamHeartbeat]; value != "" {
+		cfg.heartbeat, err = time.ParseDuration(value)
+		if err != nil || cfg.heartbeat <= 0 {
+			return nil, fmt.Errorf("invalid %s %q, must be a positive duration like 10s", dsnParamHeartbeat, value)
+		}
+	} else if driver.ReadTimeout > 0 {
+		// 设置了读超时但没有心跳时，空闲的复制连接会被误判为超时，默认按读超时的一半发送心跳
+		cfg.heartbeat = driver.ReadTimeout / 2
+	}
+
+	if params[dsnParamTLSCA] != "" || params[dsnParamTLSCert] != "" || params[dsnParamTLSKey] != "" {
+		tlsConfig, err := loadTLSConfig(driver, params[dsnParamTLSCA], params[dsnParamTLSCert], params[dsnParamTLSKey])
+		if err != nil {
+			return nil, err
+		}
+		driver.TLS = tlsConfig
+	}
+
+	return cfg, nil
+}
+
+// loadTLSConfig 根据证书文件构造 TLS 配置，tls=skip-verify 时不校验服务端证书
+func loadTLSConfig(driver *mysqldriver.Config, caFile, certFile, keyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if driver.TLS != nil {
+		tlsConfig.InsecureSkipVerify = driver.TLS.InsecureSkipVerify
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dsnParamTLSCA, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in %s %s", dsnParamTLSCA, caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("%s and %s must be specified together", dsnParamTLSCert, dsnParamTLSKey)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if !tlsConfig.InsecureSkipVerify {
+		if host, _, err := net.SplitHostPort(driver.Addr); err == nil {
+			tlsConfig.ServerName = host
+		}
+	}
+	return tlsConfig, nil
+}
+
+// syncerConfig 构造 BinlogSyncer 的配置
+func (c *dsnConfig) syncerConfig(flavor string) replication.BinlogSyncerConfig {
+	syncerCfg := replication.BinlogSyncerConfig{
+		ServerID:        c.serverID,
+		Flavor:          flavor,
+		User:            c.driver.User,
+		Password:        c.driver.Passwd,
+		HeartbeatPeriod: c.heartbeat,
+		ReadTimeout:     c.driver.ReadTimeout,
+		// 关闭 go-mysql 内置的重试：它会从事务中间或事务开头重新读取，导致事件重复，
+		// 断线重连由 reconnect 按事务边界处理
+		DisableRetrySync: true,
+		// DECIMAL 解析为 decimal.Decimal，避免转换为 float64 丢失精度
+		UseDecimal: true,
+	}
+
+	// unix socket 时 Host 直接使用 socket 路径，Port 为 0
+	if c.driver.Net == "unix" {
+		syncerCfg.Host = c.driver.Addr
+	} else {
+		host, port, err := net.SplitHostPort(c.driver.Addr)
+		if err != nil {
+			host, port = c.driver.Addr, "3306"
+		}
+		syncerCfg.Host = host
+		syncerCfg.Port = parsePort(port)
+	}
+
+	if charset := c.driver.Params["charset"]; charset != "" {
+		// go-sql-driver 支持逗号分隔的多个候选字符集，复制连接取第一个
+		syncerCfg.Charset = strings.Split(charset, ",")[0]
+	}
+	if c.driver.TLS != nil {
+		syncerCfg.TLSConfig = c.driver.TLS.Clone()
+	}
+	return syncerCfg
+}
+
+// OpenDB 按 DSN 打开 database/sql 连接
+// 与 sql.Open 不同，会去掉 binlogx 专用参数并应用 tlsCA/tlsCert/tlsKey
+func OpenDB(dsn string) (*sql.DB, error) {
+	cfg, err := parseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DSN: %w", err)
+	}
+	connector, err := mysqldriver.NewConnector(cfg.driver)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// randomServerID 生成随机的 server_id
+func randomServerID() uint32 {
+	return uint32(minRandomServerID + rand.IntN(maxRandomServerID-minRandomServerID))
+}
+
+// parsePort 解析端口号
+func parsePort(portStr string) uint16 {
+	port := 3306
+	if p, err := strconv.Atoi(portStr); err == nil {
+		port = p
+	}
+	return uint16(port)
+}
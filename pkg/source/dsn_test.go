@@ -0,0 +1,136 @@
+package source
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseDSNSyncerConfig(t *testing.T) {
+	cfg, err := parseDSN("repl:secret@tcp(db.example.com:3307)/app?charset=utf8mb4,utf8&readTimeout=30s&serverID=4242&heartbeatPeriod=5s&tls=skip-verify")
+	if err != nil {
+		t.Fatalf("parseDSN failed: %v", err)
+	}
+	if _, ok := cfg.driver.Params[dsnParamServerID]; ok {
+		t.Error("binlogx specific params should be removed from driver params")
+	}
+
+	syncerCfg := cfg.syncerConfig("mysql")
+	if syncerCfg.Host != "db.example.com" || syncerCfg.Port != 3307 {
+		t.Errorf("unexpected address %s:%d", syncerCfg.Host, syncerCfg.Port)
+	}
+	if syncerCfg.User != "repl" || syncerCfg.Password != "secret" {
+		t.Errorf("unexpected credentials %s/%s", syncerCfg.User, syncerCfg.Password)
+	}
+	if syncerCfg.ServerID != 4242 {
+		t.Errorf("expected server id 4242, got %d", syncerCfg.ServerID)
+	}
+	if syncerCfg.HeartbeatPeriod != 5*time.Second || syncerCfg.ReadTimeout != 30*time.Second {
+		t.Errorf("unexpected heartbeat/read timeout %s/%s", syncerCfg.HeartbeatPeriod, syncerCfg.ReadTimeout)
+	}
+	if syncerCfg.Charset != "utf8mb4" {
+		t.Errorf("expected charset utf8mb4, got %s", syncerCfg.Charset)
+	}
+	if syncerCfg.TLSConfig == nil || !syncerCfg.TLSConfig.InsecureSkipVerify {
+		t.Error("expected TLS with skip-verify")
+	}
+	if !syncerCfg.DisableRetrySync {
+		t.Error("go-mysql retry must be disabled")
+	}
+}
+
+func TestParseDSNDefaults(t *testing.T) {
+	cfg, err := parseDSN("root@tcp(127.0.0.1)/?readTimeout=20s")
+	if err != nil {
+		t.Fatalf("parseDSN failed: %v", err)
+	}
+	syncerCfg := cfg.syncerConfig("mysql")
+	if syncerCfg.Port != 3306 {
+		t.Errorf("expected default port 3306, got %d", syncerCfg.Port)
+	}
+	if syncerCfg.ServerID < minRandomServerID {
+		t.Errorf("expected random server id, got %d", syncerCfg.ServerID)
+	}
+	if syncerCfg.HeartbeatPeriod != 10*time.Second {
+		t.Errorf("expected heartbeat to default to half of readTimeout, got %s", syncerCfg.HeartbeatPeriod)
+	}
+	if syncerCfg.TLSConfig != nil {
+		t.Error("TLS should be disabled by default")
+	}
+
+	socket, err := parseDSN("root@unix(/var/run/mysqld/mysqld.sock)/")
+	if err != nil {
+		t.Fatalf("parseDSN failed: %v", err)
+	}
+	if syncerCfg := socket.syncerConfig("mysql"); syncerCfg.Host != "/var/run/mysqld/mysqld.sock" || syncerCfg.Port != 0 {
+		t.Errorf("unexpected unix socket address %s:%d", syncerCfg.Host, syncerCfg.Port)
+	}
+}
+
+func TestParseDSNInvalid(t *testing.T) {
+	tests := []string{
+		"root@tcp(127.0.0.1:3306)/?serverID=abc",
+		"root@tcp(127.0.0.1:3306)/?serverID=0",
+		"root@tcp(127.0.0.1:3306)/?heartbeatPeriod=soon",
+		"root@tcp(127.0.0.1:3306)/?tlsCert=client.pem",
+		"root@tcp(127.0.0.1:3306)/?tlsCA=%2Fnonexistent%2Fca.pem",
+	}
+	for _, dsn := range tests {
+		if _, err := parseDSN(dsn); err == nil {
+			t.Errorf("expected error for %s", dsn)
+		}
+	}
+}
+
+func TestParseDSNTLSCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestCA(t, caFile)
+
+	cfg, err := parseDSN("root@tcp(db.example.com:3306)/?tlsCA=" + url.QueryEscape(caFile))
+	if err != nil {
+		t.Fatalf("parseDSN failed: %v", err)
+	}
+	syncerCfg := cfg.syncerConfig("mysql")
+	if syncerCfg.TLSConfig == nil || syncerCfg.TLSConfig.RootCAs == nil {
+		t.Fatal("expected TLS with custom CA")
+	}
+	if syncerCfg.TLSConfig.ServerName != "db.example.com" || syncerCfg.TLSConfig.InsecureSkipVerify {
+		t.Errorf("expected server certificate verification against db.example.com, got %q", syncerCfg.TLSConfig.ServerName)
+	}
+	if cfg.driver.TLS == nil || cfg.driver.TLS.RootCAs == nil {
+		t.Error("database/sql connection should use the same TLS config")
+	}
+}
+
+// writeTestCA 生成一个自签名 CA 证书
+func writeTestCA(t *testing.T, path string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "binlogx test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
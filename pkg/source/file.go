@@ -1,35 +1,69 @@
 package source
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
 )
 
 // FileSource 离线 binlog 文件数据源
+// filePath 可以是单个文件、目录、通配符或 mysql-bin.index 索引文件，
+// 多个文件按序号排序后作为一个连续的事件流读取
 type FileSource struct {
 	filePath   string
+	files      []string // 解析后按序号排序的 binlog 文件列表
+	singleFile bool     // filePath 是否直接指向单个 binlog 文件
+	follow     bool     // 跟踪模式：读到末尾后等待文件增长并切换到后续文件
 	parser     *replication.BinlogParser
 	streamer   *replication.BinlogStreamer
 	eof        bool
-	eventChan  chan *replication.BinlogEvent
+	eventChan  chan *fileEvent
 	errChan    chan error
+	cancel     context.CancelFunc
 	mu         sync.RWMutex
 	startTime  time.Time
 	endTime    time.Time
 	startPos   uint32 // 断点续看的起始位置
 	startFile  string // 断点续看的起始文件（用于多文件场景）
+
+	// GTID 过滤
+	flavor       string // mysql / mariadb，为空时根据 FORMAT_DESCRIPTION_EVENT 自动识别
+	includeGTIDs string
+	excludeGTIDs string
+	gtids        *gtidTracker
+
+	// 当前语句的原始 SQL（ROWS_QUERY / ANNOTATE_ROWS 事件，仅由 Read 所在的 goroutine 访问）
+	rowsQuery string
+
+	// 多行 RowsEvent 拆分后尚未返回的行事件（仅由 Read 所在的 goroutine 访问）
+	pending []*models.Event
+
+	// TABLE_MAP 事件的原始字节，键为 table id（仅由 Read 所在的 goroutine 访问）
+	tableMaps map[uint64][]byte
+}
+
+// fileEvent 带有所属 binlog 文件名的原始事件
+type fileEvent struct {
+	event   *replication.BinlogEvent
+	logName string
 }
 
 // NewFileSource 创建文件数据源
 func NewFileSource(filePath string) *FileSource {
 	return &FileSource{
-		filePath: filePath,
+		filePath:  filePath,
+		tableMaps: make(map[uint64][]byte),
 	}
 }
 
@@ -42,6 +76,7 @@ func (fs *FileSource) SetTimeRange(start, end time.Time) {
 }
 
 // SetStartPosition 设置起始位置（用于断点续看）
+// file 为 binlog 文件名（不含目录），为空时 pos 作用于第一个文件
 func (fs *FileSource) SetStartPosition(file string, pos uint32) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
@@ -49,35 +84,207 @@ func (fs *FileSource) SetStartPosition(file string, pos uint32) {
 	fs.startPos = pos
 }
 
-// Open 打开文件并初始化解析器
+// SetFlavor 设置 binlog 类型（mysql / mariadb），为空时根据文件中的服务端版本自动识别
+func (fs *FileSource) SetFlavor(flavor string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.flavor = flavor
+}
+
+// SetGTIDFilter 设置 GTID 过滤，include/exclude 为 GTID 集合字符串，为空表示不限制
+func (fs *FileSource) SetGTIDFilter(include, exclude string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.includeGTIDs = include
+	fs.excludeGTIDs = exclude
+}
+
+// SetFollow 设置跟踪模式
+// 开启后读到最新文件末尾时不会结束，而是等待文件继续增长，
+// 遇到 ROTATE 事件或出现新的 binlog 文件时切换到下一个文件，直到 ctx 被取消
+func (fs *FileSource) SetFollow(follow bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.follow = follow
+}
+
+// Open 解析文件列表并启动后台读取
 func (fs *FileSource) Open(ctx context.Context) error {
-	// 创建 binlog 解析器
-	parser := replication.NewBinlogParser()
+	fs.mu.RLock()
+	flavor, include, exclude := fs.flavor, fs.includeGTIDs, fs.excludeGTIDs
+	fs.mu.RUnlock()
+	filter, err := newGTIDFilter(flavor, include, exclude)
+	if err != nil {
+		return err
+	}
+	fs.gtids = newGTIDTracker(filter)
+
+	files, err := resolveBinlogFiles(fs.filePath)
+	if err != nil {
+		return err
+	}
+	fs.singleFile = len(files) == 1 && files[0] == fs.filePath
+
+	// 跳过起始文件之前的文件
+	fs.mu.RLock()
+	startFile := fs.startFile
+	follow := fs.follow
+	fs.mu.RUnlock()
+	if startFile != "" {
+		idx := -1
+		for i, f := range files {
+			if trimCompressedSuffix(filepath.Base(f)) == trimCompressedSuffix(filepath.Base(startFile)) {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return fmt.Errorf("start log file %s not found in source %s", startFile, fs.filePath)
+		}
+		files = files[idx:]
+	} else if follow {
+		// 跟踪模式未指定起始文件时，从最新的文件开始读取
+		files = files[len(files)-1:]
+	}
+	fs.files = files
 
 	// 创建流处理器
 	fs.streamer = replication.NewBinlogStreamer()
 	fs.eof = false
-	fs.eventChan = make(chan *replication.BinlogEvent, 100)
+	fs.eventChan = make(chan *fileEvent, 100)
 	fs.errChan = make(chan error, 1)
 
-	// 启动异步事件读取
-	go fs.readEvents(ctx, parser)
+	// 启动异步事件读取，Close 时取消
+	ctx, fs.cancel = context.WithCancel(ctx)
+	go fs.readEvents(ctx)
 
 	return nil
 }
 
-// readEvents 后台读取事件的 goroutine
-func (fs *FileSource) readEvents(ctx context.Context, parser *replication.BinlogParser) {
+// readEvents 后台读取事件的 goroutine，按顺序依次解析每个文件
+func (fs *FileSource) readEvents(ctx context.Context) {
 	defer close(fs.eventChan)
 	defer close(fs.errChan)
 
 	// 获取起始位置配置
 	fs.mu.RLock()
 	startPos := fs.startPos
+	follow := fs.follow
+	flavor := fs.flavor
 	fs.mu.RUnlock()
 
+	files := fs.files
+	for i := 0; i < len(files); i++ {
+		// 起始位置只作用于第一个文件（即起始文件）
+		filePos := uint32(0)
+		if i == 0 {
+			filePos = startPos
+		}
+
+		err := fs.parseFile(ctx, files[i], filePos, follow, flavor)
+		if ctx.Err() != nil {
+			// 被取消（Ctrl+C 或 Close）时正常结束
+			break
+		}
+		if err != nil {
+			fs.mu.Lock()
+			fs.eof = true
+			fs.mu.Unlock()
+			fs.errChan <- fmt.Errorf("failed to parse binlog file %s: %w", files[i], err)
+			return
+		}
+
+		// 跟踪模式下读完最后一个已知文件后，继续读取它的下一个文件
+		if follow && i == len(files)-1 {
+			next, ok := fs.waitNextFile(ctx, files[i])
+			if !ok {
+				break
+			}
+			files = append(files, next)
+		}
+	}
+
+	fs.mu.Lock()
+	fs.eof = true
+	fs.mu.Unlock()
+}
+
+// parseFile 解析单个 binlog 文件，跳过 startPos 及之前的事件
+// gzip/zstd/xz 压缩的归档文件按文件头魔数识别并流式解压；
+// 跟踪模式下读到未压缩文件的末尾会等待文件继续增长，直到文件被轮转；
+// flavor 为空时根据 FORMAT_DESCRIPTION_EVENT 中的服务端版本自动识别
+func (fs *FileSource) parseFile(ctx context.Context, file string, startPos uint32, follow bool, flavor string) error {
+	// 每个文件使用独立的解析器，文件开头的 FORMAT_DESCRIPTION_EVENT 会重新初始化格式
+	parser := replication.NewBinlogParser()
+	// DECIMAL 解析为 decimal.Decimal，避免转换为 float64 丢失精度
+	parser.SetUseDecimal(true)
+	if flavor != "" {
+		parser.SetFlavor(flavor)
+	}
+	logName := trimCompressedSuffix(filepath.Base(file))
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// 当前文件是否已经轮转（读到 ROTATE 事件）
+	rotated := false
+
+	var r io.Reader
+	if follow {
+		r = &tailReader{
+			ctx:  ctx,
+			file: f,
+			done: func() bool {
+				if rotated {
+					return true
+				}
+				_, ok := fs.nextFile(file)
+				return ok
+			},
+		}
+	} else {
+		r = f
+	}
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	r = br
+	compression, err := detectCompression(br)
+	if err != nil {
+		return err
+	}
+	if compression != compressionNone {
+		dr, err := newDecompressReader(br, compression)
+		if err != nil {
+			return err
+		}
+		defer dr.Close()
+		r = dr
+	}
+
+	// 校验 binlog 文件头
+	header := make([]byte, len(replication.BinLogFileHeader))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("failed to read binlog header: %w", err)
+	}
+	if !bytes.Equal(header, replication.BinLogFileHeader) {
+		return fmt.Errorf("%s is not a valid binlog file", file)
+	}
+
 	// 定义事件回调函数，在解析器中被调用
 	onEvent := func(e *replication.BinlogEvent) error {
+		switch ev := e.Event.(type) {
+		case *replication.RotateEvent:
+			rotated = true
+		case *replication.FormatDescriptionEvent:
+			// 未指定 flavor 时根据服务端版本识别 MariaDB 生成的 binlog
+			if flavor == "" && isMariaDBVersion(ev.ServerVersion) {
+				parser.SetFlavor(mysql.MariaDBFlavor)
+			}
+		}
+
 		// 如果指定了起始位置，跳过在这个位置之前的事件
 		// 注意：LogPos 是事件的结束位置，所以我们需要 > startPos 而不是 >= startPos
 		if startPos > 0 && e.Header.LogPos <= startPos {
@@ -85,7 +292,7 @@ func (fs *FileSource) readEvents(ctx context.Context, parser *replication.Binlog
 		}
 
 		select {
-		case fs.eventChan <- e:
+		case fs.eventChan <- &fileEvent{event: e, logName: logName}:
 		case <-ctx.Done():
 			return ctx.Err()
 		}
@@ -93,29 +300,52 @@ func (fs *FileSource) readEvents(ctx context.Context, parser *replication.Binlog
 	}
 
 	// 解析文件，使用回调处理每个事件
-	if err := parser.ParseFile(fs.filePath, 0, onEvent); err != nil {
-		fs.mu.Lock()
-		fs.eof = true
-		fs.mu.Unlock()
-		fs.errChan <- fmt.Errorf("failed to parse binlog file: %w", err)
-		return
-	}
-
-	fs.mu.Lock()
-	fs.eof = true
-	fs.mu.Unlock()
+	return parser.ParseReader(r, onEvent)
 }
 
-// Close 关闭文件
+// Close 关闭文件并停止后台读取
 func (fs *FileSource) Close() error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 	fs.eof = true
+	if fs.cancel != nil {
+		fs.cancel()
+	}
 	return nil
 }
 
 // Read 读取下一个事件并转换为内部模型
+// 被时间范围过滤掉的事件会被跳过，超过结束时间后返回 EOF
 func (fs *FileSource) Read() (*models.Event, error) {
+	for {
+		// 优先返回多行事件中尚未取走的行
+		if len(fs.pending) > 0 {
+			event := fs.pending[0]
+			fs.pending = fs.pending[1:]
+			return event, nil
+		}
+
+		event, err := fs.readRaw()
+		if err != nil || event == nil {
+			return nil, err
+		}
+
+		events, err := fs.convertEvent(event.event, event.logName)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			// 早于开始时间的事件，继续读下一个
+			continue
+		}
+		fs.pending = events[1:]
+		return events[0], nil
+	}
+}
+
+// readRaw 从后台读取的 channel 中取出下一个原始事件
+// 短时间内没有事件到达时返回 nil, nil
+func (fs *FileSource) readRaw() (*fileEvent, error) {
 	fs.mu.RLock()
 	eof := fs.eof
 	hasEvents := len(fs.eventChan) > 0
@@ -132,12 +362,12 @@ func (fs *FileSource) Read() (*models.Event, error) {
 		if !ok {
 			return nil, fmt.Errorf("EOF")
 		}
-		return fs.convertEvent(event)
-	case err := <-fs.errChan:
-		if err != nil {
+		return event, nil
+	case err, ok := <-fs.errChan:
+		if ok && err != nil {
 			return nil, err
 		}
-		return nil, fmt.Errorf("EOF")
+		return fs.drainRaw()
 	default:
 		// 没有立即可用的事件
 		if eof {
@@ -149,12 +379,12 @@ func (fs *FileSource) Read() (*models.Event, error) {
 			if !ok {
 				return nil, fmt.Errorf("EOF")
 			}
-			return fs.convertEvent(event)
-		case err := <-fs.errChan:
-			if err != nil {
+			return event, nil
+		case err, ok := <-fs.errChan:
+			if ok && err != nil {
 				return nil, err
 			}
-			return nil, fmt.Errorf("EOF")
+			return fs.drainRaw()
 		case <-time.After(100 * time.Millisecond):
 			// 超时仍未收到事件，返回 nil 而不是继续阻塞
 			return nil, nil
@@ -162,132 +392,125 @@ func (fs *FileSource) Read() (*models.Event, error) {
 	}
 }
 
+// drainRaw 后台读取已结束（errChan 已关闭）时，继续取出 eventChan 中剩余的事件
+func (fs *FileSource) drainRaw() (*fileEvent, error) {
+	event, ok := <-fs.eventChan
+	if !ok {
+		return nil, fmt.Errorf("EOF")
+	}
+	return event, nil
+}
+
 // HasMore 是否还有更多数据
 func (fs *FileSource) HasMore() bool {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
-	return !fs.eof || len(fs.eventChan) > 0
+	return !fs.eof || len(fs.eventChan) > 0 || len(fs.pending) > 0
 }
 
 // convertEvent 将 go-mysql 的事件转换为内部模型
-func (fs *FileSource) convertEvent(event *replication.BinlogEvent) (*models.Event, error) {
+// 多行 RowsEvent 会按行拆分为多个事件
+func (fs *FileSource) convertEvent(event *replication.BinlogEvent, logName string) ([]*models.Event, error) {
 	if event == nil {
 		return nil, fmt.Errorf("nil event")
 	}
 
+	// TABLE_MAP 的原始字节在过滤之前记录，GTID 和时间范围过滤只决定事件是否输出
+	if e, ok := event.Event.(*replication.TableMapEvent); ok {
+		// 随后的行事件引用它生成 flashback binlog
+		fs.tableMaps[e.TableID] = event.RawData
+	}
+
 	internalEvent := &models.Event{
 		Timestamp: time.Unix(int64(event.Header.Timestamp), 0),
 		EventType: event.Header.EventType.String(),
 		ServerID:  event.Header.ServerID,
 		LogPos:    event.Header.LogPos,
-		LogName:   filepath.Base(fs.filePath), // 从文件路径提取文件名
+		EventSize: event.Header.EventSize,
+		LogName:   logName, // 事件所属的 binlog 文件名
 		RawData:   event.RawData,
 	}
 
+	// 记录所属事务的 GTID，跳过被 GTID 过滤掉的事务
+	gtid, matched := fs.gtids.observe(event)
+	if !matched {
+		return nil, nil
+	}
+	internalEvent.GTID = gtid
+	if _, ok := eventGTID(event); ok {
+		internalEvent.Action = "GTID"
+	}
+
 	// 检查时间范围
 	fs.mu.RLock()
 	startTime := fs.startTime
 	endTime := fs.endTime
 	fs.mu.RUnlock()
 
+	// 早于开始时间的事件跳过
 	if !startTime.IsZero() && internalEvent.Timestamp.Before(startTime) {
-		return nil, fmt.Errorf("before start time")
+		return nil, nil
 	}
+	// 晚于结束时间后停止读取（跟踪模式下同样结束）
 	if !endTime.IsZero() && internalEvent.Timestamp.After(endTime) {
-		return nil, fmt.Errorf("after end time")
+		fs.Close()
+		return nil, fmt.Errorf("EOF")
 	}
 
 	// 根据事件类型解析详细内容
 	switch e := event.Event.(type) {
 	case *replication.RowsEvent:
 		return fs.parseRowsEvent(internalEvent, e, event.Header)
+	case *replication.TransactionPayloadEvent:
+		return expandTransactionPayload(internalEvent, e, func(inner *replication.BinlogEvent) ([]*models.Event, error) {
+			return fs.convertEvent(inner, logName)
+		})
+	case *replication.RowsQueryEvent:
+		// 原始 SQL，附加到随后的行事件
+		fs.rowsQuery = string(e.Query)
+		internalEvent.OriginalSQL = fs.rowsQuery
+	case *replication.MariadbAnnotateRowsEvent:
+		fs.rowsQuery = string(e.Query)
+		internalEvent.OriginalSQL = fs.rowsQuery
+	case *replication.XIDEvent:
+		fs.rowsQuery = ""
+		internalEvent.Action = "COMMIT"
 	case *replication.QueryEvent:
+		fs.rowsQuery = ""
 		internalEvent.SQL = string(e.Query)
 		internalEvent.Database = string(e.Schema)
-		internalEvent.Action = "QUERY"
+		internalEvent.Action = queryAction(internalEvent.SQL)
+		internalEvent.ThreadID = e.SlaveProxyID
 	}
 
-	return internalEvent, nil
+	return []*models.Event{internalEvent}, nil
 }
 
-// parseRowsEvent 解析行事件
-func (fs *FileSource) parseRowsEvent(event *models.Event, e *replication.RowsEvent, header *replication.EventHeader) (*models.Event, error) {
+// queryAction 返回 QUERY 事件的操作类型：事务边界为 BEGIN / COMMIT（非事务表），其他语句为 QUERY
+func queryAction(query string) string {
+	switch strings.ToUpper(strings.TrimSpace(query)) {
+	case "BEGIN":
+		return "BEGIN"
+	case "COMMIT":
+		return "COMMIT"
+	}
+	return "QUERY"
+}
+
+// parseRowsEvent 解析行事件，多行事件按行拆分为多个内部事件
+func (fs *FileSource) parseRowsEvent(event *models.Event, e *replication.RowsEvent, header *replication.EventHeader) ([]*models.Event, error) {
 	// 从 TableMapEvent 获取数据库和表信息
 	if e.Table == nil {
-		return event, fmt.Errorf("missing table map event")
+		return []*models.Event{event}, fmt.Errorf("missing table map event")
 	}
 
 	event.Database = string(e.Table.Schema)
 	event.Table = string(e.Table.Table)
 
 	// 根据事件类型确定操作类型
-	switch header.EventType {
-	case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
-		event.Action = "INSERT"
-	case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
-		event.Action = "UPDATE"
-	case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
-		event.Action = "DELETE"
-	}
-
-	// 解析列和值
-	if len(e.Rows) > 0 {
-		event.AfterValues = fs.rowToMap(e.Rows[0], e)
-		if len(e.Rows) > 1 && event.Action == "UPDATE" {
-			event.BeforeValues = fs.rowToMap(e.Rows[1], e)
-		} else if len(e.Rows) > 1 && (event.Action == "DELETE" || event.Action == "UPDATE") {
-			// For DELETE and UPDATE, first row might be the before image
-			event.BeforeValues = fs.rowToMap(e.Rows[0], e)
-			if len(e.Rows) > 1 {
-				event.AfterValues = fs.rowToMap(e.Rows[1], e)
-			}
-		}
-	}
-
-	return event, nil
-}
-
-// rowToMap 将行数据转换为 map
-// 注意：RowsEvent.Rows 中的数据与 ColumnBitmap1 对应
-// ColumnBitmap1 指示了哪些列被包含在行数据中
-func (fs *FileSource) rowToMap(row []interface{}, rowsEvent *replication.RowsEvent) map[string]interface{} {
-	if row == nil || rowsEvent == nil || rowsEvent.Table == nil {
-		return make(map[string]interface{})
-	}
-
-	result := make(map[string]interface{})
-
-	// 根据 ColumnBitmap1 确定哪些列被包含
-	// ColumnBitmap1 是一个字节数组，每一位表示对应列是否被包含
-	includedCols := getIncludedColumnIndices(int(rowsEvent.Table.ColumnCount), rowsEvent.ColumnBitmap1)
-
-	// 将 row 中的数据按照 includedCols 映射到正确的列号
-	for i, col := range row {
-		if i < len(includedCols) {
-			colName := fmt.Sprintf("col_%d", includedCols[i])
-			result[colName] = col
-		}
-	}
-
-	return result
-}
-
-// getIncludedColumnIndices 根据 ColumnBitmap1 获取被包含的列号列表
-// ColumnBitmap1 中的每一位对应一列，1 表示包含，0 表示不包含
-func getIncludedColumnIndices(totalColumns int, columnBitmap []byte) []int {
-	var included []int
-
-	for colIdx := 0; colIdx < totalColumns; colIdx++ {
-		byteIdx := colIdx / 8
-		bitIdx := colIdx % 8
-
-		if byteIdx < len(columnBitmap) {
-			// 检查对应的位是否为 1
-			if (columnBitmap[byteIdx] & (1 << uint(bitIdx))) != 0 {
-				included = append(included, colIdx)
-			}
-		}
-	}
+	event.Action = rowsEventAction(header.EventType)
+	event.OriginalSQL = fs.rowsQuery
+	event.RawRows = newRawRowsEvent(event.RawData, fs.tableMaps[e.TableID], e)
 
-	return included
+	return splitRowsEvent(event, e), nil
 }
@@ -0,0 +1,172 @@
+package source
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errNoBinlogFiles 目录中没有 binlog 文件
+var errNoBinlogFiles = errors.New("no binlog files found")
+
+// resolveBinlogFiles 将 --source 参数解析为按序号排序的 binlog 文件列表
+// 支持以下形式：
+//   - 单个 binlog 文件：/var/lib/mysql/mysql-bin.000120
+//   - 目录：读取目录下所有形如 prefix.NNNNNN 的文件
+//   - 通配符：/var/lib/mysql/mysql-bin.0001[2-4]*
+//   - 索引文件：/var/lib/mysql/mysql-bin.index（相对路径相对于索引文件所在目录）
+func resolveBinlogFiles(source string) ([]string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		// 路径不存在时尝试作为通配符处理
+		if !os.IsNotExist(err) || !strings.ContainsAny(source, "*?[") {
+			return nil, fmt.Errorf("failed to access source %s: %w", source, err)
+		}
+		matches, err := filepath.Glob(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source pattern %s: %w", source, err)
+		}
+		var files []string
+		for _, m := range matches {
+			if fi, err := os.Stat(m); err == nil && !fi.IsDir() {
+				files = append(files, m)
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no binlog files match %s", source)
+		}
+		files = dedupBinlogFiles(files)
+		sortBinlogFiles(files)
+		return files, nil
+	}
+
+	if info.IsDir() {
+		return listBinlogDir(source)
+	}
+
+	if strings.HasSuffix(source, ".index") {
+		return readBinlogIndex(source)
+	}
+
+	return []string{source}, nil
+}
+
+// listBinlogDir 列出目录中的 binlog 文件（文件名形如 prefix.NNNNNN，或带 .gz/.zst/.xz 后缀的压缩归档）
+func listBinlogDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binlog directory %s: %w", dir, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if _, _, ok := binlogSequence(entry.Name()); ok {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w in directory %s", errNoBinlogFiles, dir)
+	}
+	files = dedupBinlogFiles(files)
+	sortBinlogFiles(files)
+	return files, nil
+}
+
+// dedupBinlogFiles 同一个 binlog 同时存在原始文件和压缩归档（如 mysql-bin.000123 和 mysql-bin.000123.gz）时只保留一个，
+// 优先使用未压缩的文件
+func dedupBinlogFiles(files []string) []string {
+	index := make(map[string]int, len(files))
+	result := files[:0]
+	for _, file := range files {
+		key := filepath.Join(filepath.Dir(file), trimCompressedSuffix(filepath.Base(file)))
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(result)
+			result = append(result, file)
+			continue
+		}
+		if filepath.Base(file) == filepath.Base(key) {
+			result[i] = file
+		}
+	}
+	return result
+}
+
+// readBinlogIndex 读取 mysql-bin.index 索引文件中列出的 binlog 文件
+func readBinlogIndex(indexPath string) ([]string, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open binlog index %s: %w", indexPath, err)
+	}
+	defer f.Close()
+
+	baseDir := filepath.Dir(indexPath)
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(baseDir, line)
+		}
+		files = append(files, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read binlog index %s: %w", indexPath, err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("binlog index %s is empty", indexPath)
+	}
+	sortBinlogFiles(files)
+	return files, nil
+}
+
+// sortBinlogFiles 按前缀和序号排序，无法识别序号的文件按文件名排在后面
+func sortBinlogFiles(files []string) {
+	sort.SliceStable(files, func(i, j int) bool {
+		pi, si, oki := binlogSequence(files[i])
+		pj, sj, okj := binlogSequence(files[j])
+		if oki != okj {
+			return oki
+		}
+		if !oki || pi != pj {
+			return filepath.Base(files[i]) < filepath.Base(files[j])
+		}
+		return si < sj
+	})
+}
+
+// binlogSequence 从文件名中解析前缀和序号，例如 mysql-bin.000123 -> ("mysql-bin", 123)
+// 压缩归档（如 mysql-bin.000123.gz）按去掉后缀后的文件名解析
+func binlogSequence(path string) (string, int, bool) {
+	name := trimCompressedSuffix(filepath.Base(path))
+	idx := strings.LastIndex(name, ".")
+	if idx <= 0 || idx == len(name)-1 {
+		return "", 0, false
+	}
+
+	ext := name[idx+1:]
+	for _, c := range ext {
+		if c < '0' || c > '9' {
+			return "", 0, false
+		}
+	}
+
+	seq, err := strconv.Atoi(ext)
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:idx], seq, true
+}
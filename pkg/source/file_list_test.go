@@ -0,0 +1,130 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("test"), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func baseNames(files []string) []string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = filepath.Base(f)
+	}
+	return names
+}
+
+func assertNames(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestResolveBinlogFilesDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "mysql-bin.000010", "mysql-bin.000009", "mysql-bin.1000000", "mysql-bin.index", "README")
+
+	files, err := resolveBinlogFiles(dir)
+	if err != nil {
+		t.Fatalf("resolveBinlogFiles failed: %v", err)
+	}
+	assertNames(t, baseNames(files), "mysql-bin.000009", "mysql-bin.000010", "mysql-bin.1000000")
+}
+
+func TestResolveBinlogFilesDirectoryCompressedDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "mysql-bin.000009.gz", "mysql-bin.000009", "mysql-bin.000010.zst", "mysql-bin.000011")
+
+	files, err := resolveBinlogFiles(dir)
+	if err != nil {
+		t.Fatalf("resolveBinlogFiles failed: %v", err)
+	}
+	assertNames(t, baseNames(files), "mysql-bin.000009", "mysql-bin.000010.zst", "mysql-bin.000011")
+
+	files, err = resolveBinlogFiles(filepath.Join(dir, "mysql-bin.00000*"))
+	if err != nil {
+		t.Fatalf("resolveBinlogFiles failed: %v", err)
+	}
+	assertNames(t, baseNames(files), "mysql-bin.000009")
+}
+
+func TestResolveBinlogFilesGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "mysql-bin.000121", "mysql-bin.000120", "mysql-bin.000130")
+
+	files, err := resolveBinlogFiles(filepath.Join(dir, "mysql-bin.00012*"))
+	if err != nil {
+		t.Fatalf("resolveBinlogFiles failed: %v", err)
+	}
+	assertNames(t, baseNames(files), "mysql-bin.000120", "mysql-bin.000121")
+}
+
+func TestResolveBinlogFilesIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "mysql-bin.000001", "mysql-bin.000002")
+	index := "./mysql-bin.000002\n./mysql-bin.000001\n\n"
+	if err := os.WriteFile(filepath.Join(dir, "mysql-bin.index"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := resolveBinlogFiles(filepath.Join(dir, "mysql-bin.index"))
+	if err != nil {
+		t.Fatalf("resolveBinlogFiles failed: %v", err)
+	}
+	assertNames(t, files, filepath.Join(dir, "mysql-bin.000001"), filepath.Join(dir, "mysql-bin.000002"))
+}
+
+func TestResolveBinlogFilesNotFound(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := resolveBinlogFiles(filepath.Join(dir, "missing.000001")); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if _, err := resolveBinlogFiles(filepath.Join(dir, "mysql-bin.*")); err == nil {
+		t.Error("expected error for glob without matches")
+	}
+	if _, err := resolveBinlogFiles(dir); err == nil {
+		t.Error("expected error for directory without binlog files")
+	}
+}
+
+func TestBinlogSequence(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		seq    int
+		ok     bool
+	}{
+		{"/data/mysql-bin.000123", "mysql-bin", 123, true},
+		{"binlog.000001", "binlog", 1, true},
+		{"/archive/mysql-bin.000124.gz", "mysql-bin", 124, true},
+		{"mysql-bin.000125.zst", "mysql-bin", 125, true},
+		{"mysql-bin.index.gz", "", 0, false},
+		{"mysql-bin.index", "", 0, false},
+		{"mysql-bin.", "", 0, false},
+		{"mysql-bin.+12", "", 0, false},
+	}
+
+	for _, test := range tests {
+		prefix, seq, ok := binlogSequence(test.name)
+		if prefix != test.prefix || seq != test.seq || ok != test.ok {
+			t.Errorf("binlogSequence(%s) = (%s, %d, %v), expected (%s, %d, %v)",
+				test.name, prefix, seq, ok, test.prefix, test.seq, test.ok)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package source
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// flashbackServerVersion flashback binlog 的 FORMAT_DESCRIPTION_EVENT 中记录的服务端版本
+const flashbackServerVersion = "8.0.36-binlogx-flashback"
+
+// flashbackPostHeaderLengths MySQL 8.0 各类事件的 post-header 长度，下标为事件类型减 1
+var flashbackPostHeaderLengths = []byte{
+	56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 98, 0, 4, 26, 8, 0,
+	0, 0, 8, 8, 8, 2, 0, 0, 0, 10, 10, 10, 42, 42, 0, 18, 52, 0, 10, 40, 0,
+}
+
+// flashbackEventTypes 行事件反转后的事件类型：WRITE 与 DELETE 互换，UPDATE 不变（交换前后镜像）
+var flashbackEventTypes = map[replication.EventType]replication.EventType{
+	replication.WRITE_ROWS_EVENTv1:  replication.DELETE_ROWS_EVENTv1,
+	replication.DELETE_ROWS_EVENTv1: replication.WRITE_ROWS_EVENTv1,
+	replication.UPDATE_ROWS_EVENTv1: replication.UPDATE_ROWS_EVENTv1,
+	replication.WRITE_ROWS_EVENTv2:  replication.DELETE_ROWS_EVENTv2,
+	replication.DELETE_ROWS_EVENTv2: replication.WRITE_ROWS_EVENTv2,
+	replication.UPDATE_ROWS_EVENTv2: replication.UPDATE_ROWS_EVENTv2,
+}
+
+// FlashbackTransaction 生成一个事务的 flashback 事件：BEGIN、按倒序排列的 TABLE_MAP 和反转后的行事件、XID
+// 每个行事件单独成为一条语句（带 STMT_END_F），行事件中的行也按倒序排列。
+// 无法反转的行事件（镜像不完整、压缩行事件等）跳过并通过 skip 报告，没有可反转的行事件时返回 nil。
+// 事件的 log_pos 和校验和在 FlashbackWriter 写出时填写
+func FlashbackTransaction(tx *models.Transaction, xid uint64, skip func(event *models.Event, err error)) []byte {
+	var rows []byte
+	var last *models.RawRowsEvent
+	for i := len(tx.Events) - 1; i >= 0; i-- {
+		event := tx.Events[i]
+		raw := event.RawRows
+		if raw == nil {
+			skip(event, fmt.Errorf("raw rows event is not available"))
+			continue
+		}
+		// 多行事件拆分出的事件共享同一个 RawRowsEvent，只处理一次
+		if raw == last {
+			continue
+		}
+		last = raw
+
+		data, err := flashbackRowsEvent(raw)
+		if err != nil {
+			skip(event, err)
+			continue
+		}
+		rows = append(rows, data...)
+	}
+	if rows == nil {
+		return nil
+	}
+
+	first := tx.Events[0]
+	timestamp := uint32(first.Timestamp.Unix())
+	if tx.Committed() {
+		timestamp = uint32(tx.CommitTime.Unix())
+	}
+
+	var data []byte
+	data = append(data, encodeFlashbackEvent(timestamp, replication.QUERY_EVENT, first.ServerID, flashbackQueryBody(tx.ThreadID, "BEGIN"))...)
+	data = append(data, rows...)
+	data = append(data, encodeFlashbackEvent(timestamp, replication.XID_EVENT, first.ServerID, binary.LittleEndian.AppendUint64(nil, xid))...)
+	return data
+}
+
+// flashbackRowsEvent 反转一个行事件，返回 TABLE_MAP 事件和反转后的行事件
+// 行数据按列类型逐列计算长度，解析完所有行后剩余 4 字节即为 CRC32 校验和
+func flashbackRowsEvent(raw *models.RawRowsEvent) ([]byte, error) {
+	if len(raw.Rows) < replication.EventHeaderSize || len(raw.TableMap) < replication.EventHeaderSize {
+		return nil, fmt.Errorf("invalid raw rows event")
+	}
+	eventType := replication.EventType(raw.Rows[4])
+	flipped, ok := flashbackEventTypes[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported rows event %s", eventType)
+	}
+	update := flipped == eventType
+	body := raw.Rows[replication.EventHeaderSize:]
+
+	// post-header：6 字节 table id、2 字节 flags，v2 行事件还有 extra data
+	const flagsPos = 6
+	pos := flagsPos + 2
+	if len(body) < pos+2 {
+		return nil, fmt.Errorf("rows event is truncated")
+	}
+	flags := binary.LittleEndian.Uint16(body[flagsPos:])
+	if eventType >= replication.WRITE_ROWS_EVENTv2 {
+		extraLen := int(binary.LittleEndian.Uint16(body[pos:]))
+		if extraLen < 2 {
+			return nil, fmt.Errorf("invalid extra data length %d", extraLen)
+		}
+		pos += extraLen
+	}
+	if pos >= len(body) {
+		return nil, fmt.Errorf("rows event is truncated")
+	}
+	columnCount, _, n := mysql.LengthEncodedInt(body[pos:])
+	pos += n
+	if int(columnCount) != len(raw.ColumnTypes) || len(raw.ColumnMeta) != len(raw.ColumnTypes) {
+		return nil, fmt.Errorf("column count %d does not match table map", columnCount)
+	}
+	bitmapSize := (int(columnCount) + 7) / 8
+	images := 1
+	if update {
+		images = 2
+	}
+	if len(body) < pos+bitmapSize*images {
+		return nil, fmt.Errorf("rows event is truncated")
+	}
+	bitmaps := make([][]byte, images)
+	for i := range bitmaps {
+		bitmaps[i] = body[pos : pos+bitmapSize]
+		pos += bitmapSize
+		if !fullBitmap(bitmaps[i], int(columnCount)) {
+			return nil, fmt.Errorf("row image is incomplete (binlog_row_image is not FULL)")
+		}
+	}
+	headerEnd := pos
+
+	// 按行切分，UPDATE 每行为前后两个镜像
+	rows := make([][]byte, 0, raw.RowCount*images)
+	for i := 0; i < raw.RowCount*images; i++ {
+		size, err := rowImageSize(body[pos:], bitmaps[i%images], raw.ColumnTypes, raw.ColumnMeta)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, body[pos:pos+size])
+		pos += size
+	}
+	checksum := false
+	switch len(body) - pos {
+	case 0:
+	case replication.BinlogChecksumLength:
+		checksum = true
+	default:
+		return nil, fmt.Errorf("unexpected %d bytes after %d rows", len(body)-pos, raw.RowCount)
+	}
+
+	out := make([]byte, 0, pos)
+	out = append(out, body[:headerEnd-bitmapSize*images]...)
+	binary.LittleEndian.PutUint16(out[flagsPos:], flags|replication.RowsEventStmtEndFlag)
+	for i := images - 1; i >= 0; i-- {
+		out = append(out, bitmaps[i]...)
+	}
+	for i := raw.RowCount - 1; i >= 0; i-- {
+		for j := images - 1; j >= 0; j-- {
+			out = append(out, rows[i*images+j]...)
+		}
+	}
+
+	tableMap := raw.TableMap[replication.EventHeaderSize:]
+	if checksum {
+		if len(tableMap) < replication.BinlogChecksumLength {
+			return nil, fmt.Errorf("invalid table map event")
+		}
+		tableMap = tableMap[:len(tableMap)-replication.BinlogChecksumLength]
+	}
+
+	data := encodeFlashbackEvent(binary.LittleEndian.Uint32(raw.TableMap), replication.TABLE_MAP_EVENT,
+		binary.LittleEndian.Uint32(raw.TableMap[5:]), tableMap)
+	return append(data, encodeFlashbackEvent(binary.LittleEndian.Uint32(raw.Rows), flipped,
+		binary.LittleEndian.Uint32(raw.Rows[5:]), out)...), nil
+}
+
+// fullBitmap 判断列位图是否包含全部列
+func fullBitmap(bitmap []byte, columns int) bool {
+	for i := 0; i < columns; i++ {
+		if bitmap[i/8]&(1<<(i%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rowImageSize 计算一个行镜像的字节数：NULL 位图加上每个非 NULL 列的值
+func rowImageSize(data, bitmap, types []byte, meta []uint16) (int, error) {
+	present := 0
+	for i := range types {
+		if bitmap[i/8]&(1<<(i%8)) != 0 {
+			present++
+		}
+	}
+	pos := (present + 7) / 8
+	if len(data) < pos {
+		return 0, fmt.Errorf("row image is truncated")
+	}
+	nulls := data[:pos]
+
+	idx := 0
+	for i, tp := range types {
+		if bitmap[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		null := nulls[idx/8]&(1<<(idx%8)) != 0
+		idx++
+		if null {
+			continue
+		}
+		size, err := fieldSize(data[pos:], tp, meta[i])
+		if err != nil {
+			return 0, fmt.Errorf("column %d: %w", i, err)
+		}
+		pos += size
+		if pos > len(data) {
+			return 0, fmt.Errorf("row image is truncated")
+		}
+	}
+	return pos, nil
+}
+
+// decimalCompressedBytes DECIMAL 不足 9 位的数字占用的字节数
+var decimalCompressedBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// fieldSize 按列类型和元数据计算行事件中一个列值的字节数（与 go-mysql 的元数据格式一致）
+func fieldSize(data []byte, tp byte, meta uint16) (int, error) {
+	switch tp {
+	case mysql.MYSQL_TYPE_NULL:
+		return 0, nil
+	case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_YEAR:
+		return 1, nil
+	case mysql.MYSQL_TYPE_SHORT:
+		return 2, nil
+	case mysql.MYSQL_TYPE_INT24, mysql.MYSQL_TYPE_DATE, mysql.MYSQL_TYPE_TIME, mysql.MYSQL_TYPE_NEWDATE:
+		return 3, nil
+	case mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_FLOAT, mysql.MYSQL_TYPE_TIMESTAMP:
+		return 4, nil
+	case mysql.MYSQL_TYPE_LONGLONG, mysql.MYSQL_TYPE_DOUBLE, mysql.MYSQL_TYPE_DATETIME:
+		return 8, nil
+	case mysql.MYSQL_TYPE_TIME2:
+		return 3 + int(meta+1)/2, nil
+	case mysql.MYSQL_TYPE_TIMESTAMP2:
+		return 4 + int(meta+1)/2, nil
+	case mysql.MYSQL_TYPE_DATETIME2:
+		return 5 + int(meta+1)/2, nil
+	case mysql.MYSQL_TYPE_NEWDECIMAL:
+		precision, scale := int(meta>>8), int(meta&0xFF)
+		integral := precision - scale
+		return integral/9*4 + decimalCompressedBytes[integral%9] + scale/9*4 + decimalCompressedBytes[scale%9], nil
+	case mysql.MYSQL_TYPE_BIT:
+		bits := int(meta>>8)*8 + int(meta&0xFF)
+		return (bits + 7) / 8, nil
+	case mysql.MYSQL_TYPE_ENUM, mysql.MYSQL_TYPE_SET:
+		return int(meta & 0xFF), nil
+	case mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_VAR_STRING:
+		if meta < 256 {
+			return lengthPrefixedSize(data, 1)
+		}
+		return lengthPrefixedSize(data, 2)
+	case mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_GEOMETRY, mysql.MYSQL_TYPE_JSON, mysql.MYSQL_TYPE_VECTOR:
+		if meta < 1 || meta > 4 {
+			return 0, fmt.Errorf("invalid length size %d", meta)
+		}
+		return lengthPrefixedSize(data, int(meta))
+	case mysql.MYSQL_TYPE_STRING:
+		// CHAR、BINARY、ENUM、SET 在 TABLE_MAP 中都是 STRING，元数据高字节为实际类型
+		length := int(meta)
+		if meta >= 256 {
+			realType, low := byte(meta>>8), int(meta&0xFF)
+			if realType&0x30 != 0x30 {
+				length = low | int((realType&0x30)^0x30)<<4
+				realType |= 0x30
+			} else {
+				length = low
+			}
+			if realType == mysql.MYSQL_TYPE_ENUM || realType == mysql.MYSQL_TYPE_SET {
+				return length, nil
+			}
+		}
+		if length < 256 {
+			return lengthPrefixedSize(data, 1)
+		}
+		return lengthPrefixedSize(data, 2)
+	}
+	return 0, fmt.Errorf("unsupported column type %d", tp)
+}
+
+// lengthPrefixedSize 计算带长度前缀的值的字节数，prefix 为小端长度前缀的字节数
+func lengthPrefixedSize(data []byte, prefix int) (int, error) {
+	if len(data) < prefix {
+		return 0, fmt.Errorf("value is truncated")
+	}
+	length := 0
+	for i := prefix - 1; i >= 0; i-- {
+		length = length<<8 | int(data[i])
+	}
+	return prefix + length, nil
+}
+
+// flashbackQueryBody 构造 QUERY_EVENT 的事件体，不带状态变量和默认库
+func flashbackQueryBody(threadID uint32, query string) []byte {
+	body := binary.LittleEndian.AppendUint32(nil, threadID)
+	body = binary.LittleEndian.AppendUint32(body, 0) // execution time
+	body = append(body, 0)                           // schema length
+	body = binary.LittleEndian.AppendUint16(body, 0) // error code
+	body = binary.LittleEndian.AppendUint16(body, 0) // status vars length
+	body = append(body, 0)                           // schema 的结尾
+	return append(body, query...)
+}
+
+// encodeFlashbackEvent 编码一个事件，log_pos 和 CRC32 校验和留空，由 FlashbackWriter 填写
+func encodeFlashbackEvent(timestamp uint32, eventType replication.EventType, serverID uint32, body []byte) []byte {
+	size := replication.EventHeaderSize + len(body) + replication.BinlogChecksumLength
+	data := make([]byte, 0, size)
+	data = binary.LittleEndian.AppendUint32(data, timestamp)
+	data = append(data, byte(eventType))
+	data = binary.LittleEndian.AppendUint32(data, serverID)
+	data = binary.LittleEndian.AppendUint32(data, uint32(size))
+	data = binary.LittleEndian.AppendUint32(data, 0) // log_pos
+	data = binary.LittleEndian.AppendUint16(data, 0) // flags
+	data = append(data, body...)
+	return append(data, make([]byte, replication.BinlogChecksumLength)...)
+}
+
+// FlashbackWriter 写出 flashback binlog 文件：文件头、FORMAT_DESCRIPTION_EVENT，以及 FlashbackTransaction 生成的事件
+// 写入的数据可以在任意位置分块，按事件写出时依次填写 log_pos 并计算 CRC32 校验和
+type FlashbackWriter struct {
+	w       io.Writer
+	pos     uint32 // 已写出的字节数，即下一个事件的起始位置
+	pending []byte // 尚未收到完整数据的事件
+}
+
+// NewFlashbackWriter 创建 flashback binlog 写入器，立即写出文件头和 FORMAT_DESCRIPTION_EVENT
+func NewFlashbackWriter(w io.Writer) (*FlashbackWriter, error) {
+	fw := &FlashbackWriter{w: w}
+	if _, err := w.Write(replication.BinLogFileHeader); err != nil {
+		return nil, fmt.Errorf("failed to write binlog header: %w", err)
+	}
+	fw.pos = uint32(len(replication.BinLogFileHeader))
+
+	body := binary.LittleEndian.AppendUint16(nil, 4) // binlog v4
+	version := make([]byte, 50)
+	copy(version, flashbackServerVersion)
+	body = append(body, version...)
+	body = binary.LittleEndian.AppendUint32(body, 0) // create timestamp
+	body = append(body, byte(replication.EventHeaderSize))
+	body = append(body, flashbackPostHeaderLengths...)
+	body = append(body, replication.BINLOG_CHECKSUM_ALG_CRC32)
+	event := encodeFlashbackEvent(uint32(time.Now().Unix()), replication.FORMAT_DESCRIPTION_EVENT, 0, body)
+	if err := fw.writeEvent(event); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+// Write 写入 FlashbackTransaction 生成的事件数据
+func (fw *FlashbackWriter) Write(p []byte) (int, error) {
+	fw.pending = append(fw.pending, p...)
+	consumed := 0
+	for len(fw.pending)-consumed >= replication.EventHeaderSize {
+		event := fw.pending[consumed:]
+		size := int(binary.LittleEndian.Uint32(event[9:]))
+		if size < replication.EventHeaderSize+replication.BinlogChecksumLength {
+			return 0, fmt.Errorf("invalid flashback event size %d", size)
+		}
+		if len(event) < size {
+			break
+		}
+		if err := fw.writeEvent(event[:size]); err != nil {
+			return 0, err
+		}
+		consumed += size
+	}
+	fw.pending = append(fw.pending[:0], fw.pending[consumed:]...)
+	return len(p), nil
+}
+
+// writeEvent 填写事件的 log_pos 和校验和后写出
+func (fw *FlashbackWriter) writeEvent(event []byte) error {
+	fw.pos += uint32(len(event))
+	binary.LittleEndian.PutUint32(event[13:], fw.pos)
+	checksumPos := len(event) - replication.BinlogChecksumLength
+	binary.LittleEndian.PutUint32(event[checksumPos:], crc32.ChecksumIEEE(event[:checksumPos]))
+	if _, err := fw.w.Write(event); err != nil {
+		return fmt.Errorf("failed to write flashback binlog: %w", err)
+	}
+	return nil
+}
+
+// Close 检查是否所有事件都已完整写出
+func (fw *FlashbackWriter) Close() error {
+	if len(fw.pending) > 0 {
+		return fmt.Errorf("flashback binlog ends with an incomplete event (%d bytes)", len(fw.pending))
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// readTestTransactions 读取 binlog 文件，按 COMMIT 把行事件分组为事务
+func readTestTransactions(t *testing.T, path string) []*models.Transaction {
+	t.Helper()
+	fs := NewFileSource(path)
+	if err := fs.Open(context.Background()); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer fs.Close()
+
+	var txs []*models.Transaction
+	current := &models.Transaction{}
+	for {
+		event, err := fs.Read()
+		if err != nil {
+			if err.Error() != "EOF" {
+				t.Fatalf("read: %v", err)
+			}
+			return txs
+		}
+		if event == nil {
+			continue
+		}
+		switch event.Action {
+		case "INSERT", "UPDATE", "DELETE":
+			current.Events = append(current.Events, event)
+		case "COMMIT":
+			current.CommitPos = event.LogPos
+			current.CommitTime = event.Timestamp
+			txs = append(txs, current)
+			current = &models.Transaction{}
+		}
+	}
+}
+
+func TestFlashbackTransactions(t *testing.T) {
+	data := newBinlogBuilder().
+		query("test", "BEGIN").tableMap(100, "test", "t1").
+		writeRows(100, testRow{1, "a"}, testRow{2, "b"}).
+		tableMap(100, "test", "t1").
+		updateRows(100, testRow{1, "a"}, testRow{1, "x"}).
+		xid(1).
+		query("test", "BEGIN").tableMap(100, "test", "t1").
+		deleteRows(100, testRow{2, "b"}).
+		xid(2).
+		take()
+	dir := t.TempDir()
+	input := filepath.Join(dir, "mysql-bin.000001")
+	appendFile(t, input, data)
+
+	txs := readTestTransactions(t, input)
+	if len(txs) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(txs))
+	}
+
+	// 按事务倒序写出
+	var out bytes.Buffer
+	fw, err := NewFlashbackWriter(&out)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	for i := len(txs) - 1; i >= 0; i-- {
+		block := FlashbackTransaction(txs[i], uint64(i+1), func(event *models.Event, err error) {
+			t.Fatalf("unexpected skip: %v", err)
+		})
+		// 分块写入，验证跨块的事件拼接
+		for len(block) > 0 {
+			n := min(len(block), 7)
+			if _, err := fw.Write(block[:n]); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			block = block[n:]
+		}
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	output := filepath.Join(dir, "flashback.bin")
+	if err := os.WriteFile(output, out.Bytes(), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	type parsedEvent struct {
+		eventType replication.EventType
+		rows      [][]interface{}
+	}
+	var events []parsedEvent
+	parser := replication.NewBinlogParser()
+	parser.SetVerifyChecksum(true)
+	var end uint32 = 4
+	err = parser.ParseFile(output, 0, func(e *replication.BinlogEvent) error {
+		end += e.Header.EventSize
+		if e.Header.LogPos != end {
+			t.Errorf("%s: log_pos %d, want %d", e.Header.EventType, e.Header.LogPos, end)
+		}
+		event := parsedEvent{eventType: e.Header.EventType}
+		if rows, ok := e.Event.(*replication.RowsEvent); ok {
+			event.rows = rows.Rows
+			if rows.Flags&replication.RowsEventStmtEndFlag == 0 {
+				t.Errorf("%s: STMT_END_F is not set", e.Header.EventType)
+			}
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("parse flashback binlog: %v", err)
+	}
+	if end != uint32(out.Len()) {
+		t.Fatalf("events end at %d, file size %d", end, out.Len())
+	}
+
+	row := func(id int32, name string) []interface{} { return []interface{}{id, name} }
+	want := []parsedEvent{
+		{eventType: replication.FORMAT_DESCRIPTION_EVENT},
+		{eventType: replication.QUERY_EVENT},
+		{eventType: replication.TABLE_MAP_EVENT},
+		{eventType: replication.WRITE_ROWS_EVENTv2, rows: [][]interface{}{row(2, "b")}},
+		{eventType: replication.XID_EVENT},
+		{eventType: replication.QUERY_EVENT},
+		{eventType: replication.TABLE_MAP_EVENT},
+		{eventType: replication.UPDATE_ROWS_EVENTv2, rows: [][]interface{}{row(1, "x"), row(1, "a")}},
+		{eventType: replication.TABLE_MAP_EVENT},
+		{eventType: replication.DELETE_ROWS_EVENTv2, rows: [][]interface{}{row(2, "b"), row(1, "a")}},
+		{eventType: replication.XID_EVENT},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %v", len(want), len(events), events)
+	}
+	for i := range want {
+		if events[i].eventType != want[i].eventType || !reflect.DeepEqual(events[i].rows, want[i].rows) {
+			t.Errorf("event %d: got %s %v, want %s %v", i, events[i].eventType, events[i].rows, want[i].eventType, want[i].rows)
+		}
+	}
+}
+
+func TestFlashbackTransactionSkipsIncompleteImage(t *testing.T) {
+	data := newBinlogBuilder().
+		query("test", "BEGIN").tableMap(100, "test", "t1").
+		writeRows(100, testRow{1, "a"}).
+		xid(1).
+		take()
+	input := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	appendFile(t, input, data)
+
+	txs := readTestTransactions(t, input)
+	if len(txs) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(txs))
+	}
+	// 模拟 binlog_row_image=MINIMAL：列位图中去掉 name 列
+	raw := txs[0].Events[0].RawRows
+	rows := append([]byte(nil), raw.Rows...)
+	rows[replication.EventHeaderSize+11] = 0x01
+	raw.Rows = rows
+
+	var skipped int
+	block := FlashbackTransaction(txs[0], 1, func(event *models.Event, err error) { skipped++ })
+	if block != nil || skipped != 1 {
+		t.Fatalf("expected the rows event to be skipped, got %d bytes, %d skipped", len(block), skipped)
+	}
+}
@@ -0,0 +1,119 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// detectFlavor 根据服务端版本号识别 MySQL / MariaDB
+func detectFlavor(ctx context.Context, db *sql.DB) (string, error) {
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to query server version: %w", err)
+	}
+	if isMariaDBVersion(version) {
+		return mysql.MariaDBFlavor, nil
+	}
+	return mysql.MySQLFlavor, nil
+}
+
+// isMariaDBVersion 判断版本号（VERSION() 或 FORMAT_DESCRIPTION_EVENT 中的 server version）是否属于 MariaDB
+func isMariaDBVersion(version string) bool {
+	return strings.Contains(strings.ToLower(version), "mariadb")
+}
+
+// gtidSetFlavor 确定解析 GTID 集合时使用的 flavor
+// 未指定 flavor 时按格式推断：MySQL 为 uuid:1-100，MariaDB 为 domain-server-seq
+func gtidSetFlavor(flavor, gtidSet string) string {
+	if flavor != "" {
+		return flavor
+	}
+	if strings.Contains(gtidSet, ":") {
+		return mysql.MySQLFlavor
+	}
+	return mysql.MariaDBFlavor
+}
+
+// queryBinlogStatus 查询主库当前的 binlog 文件和位置
+// MariaDB 10.5.2+ 使用 SHOW BINLOG STATUS，MySQL 8.4+ 使用 SHOW BINARY LOG STATUS，
+// 均不支持时回退到 SHOW MASTER STATUS；没有结果时返回 sql.ErrNoRows
+func queryBinlogStatus(ctx context.Context, db *sql.DB, flavor string) (string, uint32, error) {
+	statements := []string{"SHOW BINARY LOG STATUS", "SHOW MASTER STATUS"}
+	if flavor == mysql.MariaDBFlavor {
+		statements = []string{"SHOW BINLOG STATUS", "SHOW MASTER STATUS"}
+	}
+
+	var lastErr error
+	for _, stmt := range statements {
+		file, pos, err := queryFilePos(ctx, db, stmt)
+		if err == nil || err == sql.ErrNoRows {
+			return file, pos, err
+		}
+		lastErr = err
+	}
+	return "", 0, lastErr
+}
+
+// queryFilePos 执行状态查询语句，取第一行的前两列作为 binlog 文件和位置
+func queryFilePos(ctx context.Context, db *sql.DB, stmt string) (string, uint32, error) {
+	rows, err := db.QueryContext(ctx, stmt)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", 0, err
+		}
+		return "", 0, sql.ErrNoRows
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get columns: %w", err)
+	}
+	if len(cols) < 2 {
+		return "", 0, fmt.Errorf("unexpected result of %s: %d columns", stmt, len(cols))
+	}
+
+	// 不同版本返回的列数不同，用 interface{} 接收所有列
+	values := make([]interface{}, len(cols))
+	for i := range values {
+		values[i] = new(interface{})
+	}
+	if err := rows.Scan(values...); err != nil {
+		return "", 0, fmt.Errorf("failed to scan %s: %w", stmt, err)
+	}
+
+	file := ""
+	switch v := (*values[0].(*interface{})).(type) {
+	case string:
+		file = v
+	case []uint8:
+		file = string(v)
+	}
+	if file == "" {
+		return "", 0, sql.ErrNoRows
+	}
+
+	var pos uint64
+	switch v := (*values[1].(*interface{})).(type) {
+	case int64:
+		pos = uint64(v)
+	case uint64:
+		pos = v
+	case []uint8:
+		if pos, err = strconv.ParseUint(string(v), 10, 32); err != nil {
+			return "", 0, fmt.Errorf("failed to parse binlog position: %s", string(v))
+		}
+	default:
+		return "", 0, fmt.Errorf("unexpected type for binlog position: %T", v)
+	}
+	return file, uint32(pos), nil
+}
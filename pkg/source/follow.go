@@ -0,0 +1,88 @@
+package source
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// followPollInterval 跟踪模式下检查文件增长和新文件的间隔
+var followPollInterval = 500 * time.Millisecond
+
+// tailReader 跟踪模式下的文件读取器，读到文件末尾时等待文件继续增长（类似 tail -f）
+// done 返回 true 表示当前文件不会再增长（已轮转或出现了后续文件），此时读到末尾即返回 io.EOF
+type tailReader struct {
+	ctx  context.Context
+	file *os.File
+	done func() bool
+}
+
+// Read 实现 io.Reader
+func (r *tailReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.file.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		if r.done() {
+			// 判断完成后再读一次，避免遗漏轮转前最后写入的数据
+			n, err = r.file.Read(p)
+			if n > 0 || err != io.EOF {
+				return n, err
+			}
+			return 0, io.EOF
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(followPollInterval):
+		}
+	}
+}
+
+// nextFile 查找 current 之后的下一个 binlog 文件（同前缀、序号更大的第一个文件）
+// 每次调用都会重新扫描数据源，以便发现新生成的文件或索引文件中新增的条目
+func (fs *FileSource) nextFile(current string) (string, bool) {
+	var candidates []string
+	var err error
+	if fs.singleFile {
+		// 数据源是单个文件时，在其所在目录中查找后续文件
+		candidates, err = listBinlogDir(filepath.Dir(current))
+	} else {
+		candidates, err = resolveBinlogFiles(fs.filePath)
+	}
+	if err != nil {
+		return "", false
+	}
+
+	prefix, seq, ok := binlogSequence(current)
+	if !ok {
+		return "", false
+	}
+	// candidates 已按序号排序，第一个满足条件的就是下一个文件
+	for _, c := range candidates {
+		p, s, ok := binlogSequence(c)
+		if ok && p == prefix && s > seq {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// waitNextFile 等待 current 之后的下一个 binlog 文件出现，ctx 被取消时返回 false
+func (fs *FileSource) waitNextFile(ctx context.Context, current string) (string, bool) {
+	for {
+		if next, ok := fs.nextFile(current); ok {
+			return next, true
+		}
+		select {
+		case <-ctx.Done():
+			return "", false
+		case <-time.After(followPollInterval):
+		}
+	}
+}
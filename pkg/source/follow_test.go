@@ -0,0 +1,123 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+func appendFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+// readQuery 读取下一个 QUERY 事件，超时则失败
+func readQuery(t *testing.T, fs *FileSource) *models.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		event, err := fs.Read()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if event != nil && event.Action == "QUERY" {
+			return event
+		}
+	}
+	t.Fatal("timed out waiting for query event")
+	return nil
+}
+
+func TestFileSourceFollow(t *testing.T) {
+	followPollInterval = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "mysql-bin.000001")
+	second := filepath.Join(dir, "mysql-bin.000002")
+	// 较旧的文件，跟踪模式默认从最新的文件开始
+	writeTestFiles(t, dir, "mysql-bin.000000")
+
+	b1 := newBinlogBuilder().query("db", "q1")
+	appendFile(t, first, b1.take())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fs := NewFileSource(dir)
+	fs.SetFollow(true)
+	if err := fs.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer fs.Close()
+
+	if e := readQuery(t, fs); e.SQL != "q1" || e.LogName != "mysql-bin.000001" {
+		t.Fatalf("unexpected event %s@%s", e.SQL, e.LogName)
+	}
+
+	// 文件增长
+	appendFile(t, first, b1.query("db", "q2").take())
+	if e := readQuery(t, fs); e.SQL != "q2" {
+		t.Fatalf("expected q2, got %s", e.SQL)
+	}
+
+	// 轮转到下一个文件
+	appendFile(t, first, b1.rotate("mysql-bin.000002").take())
+	appendFile(t, second, newBinlogBuilder().query("db", "q3").take())
+	if e := readQuery(t, fs); e.SQL != "q3" || e.LogName != "mysql-bin.000002" {
+		t.Fatalf("unexpected event %s@%s", e.SQL, e.LogName)
+	}
+	if !fs.HasMore() {
+		t.Fatal("follow mode should not reach EOF before cancel")
+	}
+
+	// 取消后正常结束
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := fs.Read(); err != nil {
+			if err.Error() != "EOF" {
+				t.Fatalf("expected EOF after cancel, got %v", err)
+			}
+			return
+		}
+	}
+	t.Fatal("timed out waiting for EOF after cancel")
+}
+
+func TestFileSourceTimeRangeSkipsEvents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mysql-bin.000001")
+	b := newBinlogBuilder().query("db", "q1").query("db", "q2").query("db", "q3").query("db", "q4")
+	appendFile(t, path, b.take())
+
+	// FDE 时间戳为 1700000000，q1..q4 依次为 +1..+4
+	fs := NewFileSource(path)
+	fs.SetTimeRange(time.Unix(1700000002, 0), time.Unix(1700000003, 0))
+	if err := fs.Open(context.Background()); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer fs.Close()
+
+	var got []string
+	for {
+		event, err := fs.Read()
+		if err != nil {
+			break
+		}
+		if event != nil && event.Action == "QUERY" {
+			got = append(got, event.SQL)
+		}
+	}
+	assertNames(t, got, "q2", "q3")
+}
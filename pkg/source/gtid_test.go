@@ -0,0 +1,120 @@
+package source
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+const testUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+var testSID = []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+
+func TestGTIDFilterMatch(t *testing.T) {
+	filter, err := newGTIDFilter(mysql.MySQLFlavor, testUUID+":1-10", testUUID+":5")
+	if err != nil {
+		t.Fatalf("newGTIDFilter: %v", err)
+	}
+
+	tests := []struct {
+		gtid string
+		want bool
+	}{
+		{testUUID + ":1", true},
+		{testUUID + ":5", false},
+		{testUUID + ":11", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := filter.match(test.gtid); got != test.want {
+			t.Errorf("match(%q) = %v, expected %v", test.gtid, got, test.want)
+		}
+	}
+
+	// 未设置过滤时全部保留
+	var none *gtidFilter
+	if !none.match("") || !none.match(testUUID+":1") {
+		t.Error("nil filter should match everything")
+	}
+}
+
+func TestNewGTIDFilterInvalid(t *testing.T) {
+	if _, err := newGTIDFilter(mysql.MySQLFlavor, "not-a-gtid", ""); err == nil {
+		t.Error("expected error for invalid GTID set")
+	}
+}
+
+func TestFileSourceGTID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	b := newBinlogBuilder().
+		gtid(testSID, 1).query("db", "q1").
+		gtid(testSID, 2).query("db", "q2").
+		gtid(nil, 0).query("db", "q3").
+		gtid(testSID, 3).query("db", "q4")
+	appendFile(t, path, b.take())
+
+	fs := NewFileSource(path)
+	fs.SetGTIDFilter("", testUUID+":2")
+	if err := fs.Open(context.Background()); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer fs.Close()
+
+	var queries, gtids []string
+	for {
+		event, err := fs.Read()
+		if err != nil {
+			break
+		}
+		if event != nil && event.Action == "QUERY" {
+			queries = append(queries, event.SQL)
+			gtids = append(gtids, event.GTID)
+		}
+	}
+	assertNames(t, queries, "q1", "q3", "q4")
+	assertNames(t, gtids, testUUID+":1", "", testUUID+":3")
+}
+
+func TestFileSourceMariaDBGTID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mariadb-bin.000001")
+	b := newBinlogBuilderVersion("10.6.12-MariaDB-log").
+		mariadbGTID(0, 100).annotate("UPDATE t SET a = 1").query("db", "q1").
+		mariadbGTID(0, 101).query("db", "q2")
+	appendFile(t, path, b.take())
+
+	fs := NewFileSource(path)
+	// MariaDB 的 GTID 集合表示各 domain 的位置，排除 0-1-100 即跳过该位置及之前的事务
+	fs.SetGTIDFilter("", "0-1-100")
+	if err := fs.Open(context.Background()); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer fs.Close()
+
+	var queries, gtids []string
+	for {
+		event, err := fs.Read()
+		if err != nil {
+			break
+		}
+		if event != nil && event.Action == "QUERY" {
+			queries = append(queries, event.SQL)
+			gtids = append(gtids, event.GTID)
+		}
+	}
+	assertNames(t, queries, "q2")
+	assertNames(t, gtids, "0-1-101")
+}
+
+func TestGTIDSetFlavor(t *testing.T) {
+	if got := gtidSetFlavor("", testUUID+":1-5"); got != mysql.MySQLFlavor {
+		t.Errorf("expected mysql flavor, got %s", got)
+	}
+	if got := gtidSetFlavor("", "0-1-100,1-2-5"); got != mysql.MariaDBFlavor {
+		t.Errorf("expected mariadb flavor, got %s", got)
+	}
+	if got := gtidSetFlavor(mysql.MySQLFlavor, "0-1-100"); got != mysql.MySQLFlavor {
+		t.Errorf("explicit flavor should win, got %s", got)
+	}
+}
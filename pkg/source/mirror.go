@@ -0,0 +1,230 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// BinlogMirror 把复制协议收到的原始事件逐字节写入本地 binlog 文件（类似 mysqlbinlog --raw）
+// 写出的文件可以直接作为 --source 读取
+type BinlogMirror struct {
+	dir    string
+	verify bool // 文件写完（轮转）后校验每个事件的校验和
+
+	file   *os.File // 事件逐个直接写入文件，不做缓冲，保证本地文件随时与已收到的事件一致
+	name   string   // 当前文件名
+	offset int64    // 当前文件已写入的字节数
+
+	// OnFileComplete 在一个文件写完（收到真实的 ROTATE 事件）后调用
+	OnFileComplete func(name string, size int64)
+}
+
+// NewBinlogMirror 创建镜像写入器，文件写入 dir 目录
+func NewBinlogMirror(dir string, verify bool) *BinlogMirror {
+	return &BinlogMirror{dir: dir, verify: verify}
+}
+
+// ResumePosition 查找本地最新的 binlog 文件，校验其中的事件并截掉末尾不完整的事件（下载中断），
+// 返回续传的文件名和位置，目录中没有 binlog 文件时返回空文件名
+func (m *BinlogMirror) ResumePosition() (string, uint32, error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	files, err := listBinlogDir(m.dir)
+	if errors.Is(err, errNoBinlogFiles) {
+		// 空目录
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	last := files[len(files)-1]
+
+	size, err := VerifyBinlogFile(last)
+	if err != nil && !errors.Is(err, ErrTruncatedBinlog) {
+		return "", 0, err
+	}
+	if size < int64(len(replication.BinLogFileHeader)) {
+		// 只有不完整的文件头，从头重新下载整个文件
+		return filepath.Base(last), uint32(len(replication.BinLogFileHeader)), nil
+	}
+	if err := os.Truncate(last, size); err != nil {
+		return "", 0, fmt.Errorf("failed to truncate %s: %w", last, err)
+	}
+	return filepath.Base(last), uint32(size), nil
+}
+
+// Write 写入一个事件
+// 假 ROTATE 事件切换到对应文件，心跳和其他人工构造的事件（log_pos 为 0）会被跳过，
+// 真实的 ROTATE 事件写入后关闭当前文件
+func (m *BinlogMirror) Write(ev *replication.BinlogEvent) error {
+	header := ev.Header
+	switch header.EventType {
+	case replication.HEARTBEAT_EVENT, replication.HEARTBEAT_LOG_EVENT_V2:
+		return nil
+	}
+
+	artificial := header.LogPos == 0 || header.Flags&replication.LOG_EVENT_ARTIFICIAL_F != 0
+	if e, ok := ev.Event.(*replication.RotateEvent); ok && artificial {
+		return m.open(string(e.NextLogName), e.Position)
+	}
+	if artificial {
+		// 从文件中间续传时服务端补发的 FORMAT_DESCRIPTION_EVENT 等
+		return nil
+	}
+	if m.file == nil {
+		return fmt.Errorf("received %s before ROTATE_EVENT", header.EventType)
+	}
+
+	if _, err := m.file.Write(ev.RawData); err != nil {
+		return fmt.Errorf("failed to write %s: %w", m.name, err)
+	}
+	m.offset += int64(len(ev.RawData))
+	if uint32(m.offset) != header.LogPos {
+		return fmt.Errorf("position mismatch in %s: written %d bytes, event log_pos is %d", m.name, m.offset, header.LogPos)
+	}
+
+	if header.EventType == replication.ROTATE_EVENT {
+		return m.closeFile(true)
+	}
+	return nil
+}
+
+// Close 关闭当前文件
+func (m *BinlogMirror) Close() error {
+	return m.closeFile(false)
+}
+
+// open 切换到 name 文件的 pos 位置，pos 不超过 4 时新建文件，否则追加到已有文件
+func (m *BinlogMirror) open(name string, pos uint64) error {
+	if m.file != nil && m.name == name {
+		if uint64(m.offset) != pos {
+			return fmt.Errorf("server resumed %s at %d, but %d bytes were written", name, pos, m.offset)
+		}
+		return nil
+	}
+	if err := m.closeFile(false); err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.dir, name)
+	headerSize := uint64(len(replication.BinLogFileHeader))
+	var file *os.File
+	var err error
+	if pos <= headerSize {
+		file, err = os.Create(path)
+		if err == nil {
+			_, err = file.Write(replication.BinLogFileHeader)
+		}
+		pos = headerSize
+	} else {
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			var info os.FileInfo
+			if info, err = file.Stat(); err == nil && uint64(info.Size()) != pos {
+				err = fmt.Errorf("local file is %d bytes, server resumed at %d", info.Size(), pos)
+			}
+		}
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	m.file = file
+	m.name = name
+	m.offset = int64(pos)
+	return nil
+}
+
+// closeFile 关闭当前文件，complete 表示文件已经完整写完
+func (m *BinlogMirror) closeFile(complete bool) error {
+	if m.file == nil {
+		return nil
+	}
+	path := m.file.Name()
+	err := m.file.Close()
+	name, size := m.name, m.offset
+	m.file, m.name, m.offset = nil, "", 0
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+
+	if complete {
+		if m.verify {
+			if _, err := VerifyBinlogFile(path); err != nil {
+				return err
+			}
+		}
+		if m.OnFileComplete != nil {
+			m.OnFileComplete(name, size)
+		}
+	}
+	return nil
+}
+
+// ErrTruncatedBinlog binlog 文件末尾有不完整的事件
+var ErrTruncatedBinlog = errors.New("binlog file is truncated")
+
+// VerifyBinlogFile 校验 binlog 文件中每个事件的长度、位置和 CRC32 校验和，返回最后一个完整事件结束的位置
+// 文件末尾有不完整的事件时返回 ErrTruncatedBinlog
+func VerifyBinlogFile(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 64*1024)
+
+	magic := make([]byte, len(replication.BinLogFileHeader))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, ErrTruncatedBinlog)
+	}
+	if !bytes.Equal(magic, replication.BinLogFileHeader) {
+		return 0, fmt.Errorf("%s is not a binlog file", path)
+	}
+
+	parser := replication.NewBinlogParser()
+	parser.SetRawMode(true)
+	parser.SetVerifyChecksum(true)
+
+	offset := int64(len(magic))
+	header := make([]byte, replication.EventHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return offset, nil
+		} else if err != nil {
+			return offset, fmt.Errorf("%s: %w", path, ErrTruncatedBinlog)
+		}
+
+		size := binary.LittleEndian.Uint32(header[9:13])
+		if size < replication.EventHeaderSize {
+			return offset, fmt.Errorf("%s: invalid event size %d at %d", path, size, offset)
+		}
+		data := make([]byte, size)
+		copy(data, header)
+		if _, err := io.ReadFull(r, data[replication.EventHeaderSize:]); err != nil {
+			return offset, fmt.Errorf("%s: %w", path, ErrTruncatedBinlog)
+		}
+
+		ev, err := parser.Parse(data)
+		if err != nil {
+			return offset, fmt.Errorf("%s: invalid event at %d: %w", path, offset, err)
+		}
+		offset += int64(size)
+		if ev.Header.LogPos != 0 && ev.Header.LogPos != uint32(offset) {
+			return offset, fmt.Errorf("%s: event at %d has log_pos %d", path, offset-int64(size), ev.Header.LogPos)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// mirrorTestFiles 构造两个 binlog 文件，第一个以真实的 ROTATE 事件结束
+func mirrorTestFiles() ([]byte, []byte) {
+	first := newBinlogBuilder().
+		query("test", "BEGIN").tableMap(100, "test", "t1").writeRows(100, testRow{1, "a"}).xid(1).
+		query("test", "CREATE TABLE t2 (id INT)").
+		rotate("mysql-bin.000002")
+	second := newBinlogBuilder().
+		query("test", "BEGIN").tableMap(100, "test", "t1").writeRows(100, testRow{2, "b"}).xid(2)
+	return first.data, second.data
+}
+
+// fetchUntil 以原始模式从 fake 复制端点下载，直到 name 文件达到 size 字节
+func fetchUntil(t *testing.T, f *fakeReplicationServer, mirror *BinlogMirror, file string, pos uint32, name string, size int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ms := NewMySQLSource(f.dsn())
+	ms.SetFlavor(mysql.MySQLFlavor)
+	ms.SetRawMode(true)
+	ms.SetStartPosition(file, pos)
+	ms.SetReconnect(3, 10*time.Millisecond)
+	if err := ms.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer ms.Close()
+
+	path := filepath.Join(mirror.dir, name)
+	for {
+		ev, err := ms.ReadRawEvent()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if err := mirror.Write(ev); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if info, err := os.Stat(path); err == nil && info.Size() == int64(size) {
+			return
+		}
+	}
+}
+
+func assertFileContent(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s differs from server binlog: got %d bytes, want %d bytes", filepath.Base(path), len(got), len(want))
+	}
+}
+
+func TestBinlogMirrorFetchAndResume(t *testing.T) {
+	first, second := mirrorTestFiles()
+	dir := t.TempDir()
+
+	// 第一次连接在第一个文件中间断开，重连后继续写入同一个文件
+	f := newFakeReplicationServer(t, 3, first, second)
+	mirror := NewBinlogMirror(dir, true)
+	var completed []string
+	mirror.OnFileComplete = func(name string, size int64) { completed = append(completed, name) }
+
+	file, pos, err := mirror.ResumePosition()
+	if err != nil || file != "" {
+		t.Fatalf("expected empty directory, got %s:%d, %v", file, pos, err)
+	}
+	fetchUntil(t, f, mirror, "mysql-bin.000001", 4, "mysql-bin.000002", len(second))
+	if err := mirror.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(dir, "mysql-bin.000001"), first)
+	assertFileContent(t, filepath.Join(dir, "mysql-bin.000002"), second)
+	assertNames(t, completed, "mysql-bin.000001")
+
+	// 模拟下载中断：最后一个事件只写了一半
+	path := filepath.Join(dir, "mysql-bin.000002")
+	if err := os.Truncate(path, int64(len(second)-10)); err != nil {
+		t.Fatal(err)
+	}
+	mirror = NewBinlogMirror(dir, true)
+	file, pos, err = mirror.ResumePosition()
+	if err != nil {
+		t.Fatalf("resume position: %v", err)
+	}
+	xidSize := 19 + 8 + 4
+	if file != "mysql-bin.000002" || int(pos) != len(second)-xidSize {
+		t.Fatalf("expected resume at mysql-bin.000002:%d, got %s:%d", len(second)-xidSize, file, pos)
+	}
+
+	f = newFakeReplicationServer(t, 0, first, second)
+	fetchUntil(t, f, mirror, file, pos, "mysql-bin.000002", len(second))
+	mirror.Close()
+	assertFileContent(t, path, second)
+}
+
+func TestVerifyBinlogFile(t *testing.T) {
+	first, _ := mirrorTestFiles()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mysql-bin.000001")
+
+	if err := os.WriteFile(path, first, 0644); err != nil {
+		t.Fatal(err)
+	}
+	size, err := VerifyBinlogFile(path)
+	if err != nil || size != int64(len(first)) {
+		t.Fatalf("expected valid file of %d bytes, got %d, %v", len(first), size, err)
+	}
+
+	// 末尾不完整
+	if err := os.WriteFile(path, first[:len(first)-5], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyBinlogFile(path); !errors.Is(err, ErrTruncatedBinlog) {
+		t.Fatalf("expected ErrTruncatedBinlog, got %v", err)
+	}
+
+	// 事件内容损坏
+	corrupted := append([]byte(nil), first...)
+	corrupted[len(corrupted)-12] ^= 0xff
+	if err := os.WriteFile(path, corrupted, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyBinlogFile(path); err == nil || errors.Is(err, ErrTruncatedBinlog) {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+}
+
+func TestBinlogMirrorResumeUnreadableDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+	if _, err := os.ReadDir(dir); err == nil {
+		t.Skip("directory permissions are not enforced for this user")
+	}
+
+	// 目录无法读取时不能当作空目录，否则会从头重新下载并覆盖已有的文件
+	file, pos, err := NewBinlogMirror(dir, true).ResumePosition()
+	if err == nil {
+		t.Fatalf("expected error, got %s:%d", file, pos)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,7 +12,7 @@ import (
 	"github.com/aitoooooo/binlogx/pkg/models"
 	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
-	_ "github.com/go-sql-driver/mysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
 )
 
 // MySQLSource 在线 MySQL 数据源
@@ -23,14 +22,29 @@ type MySQLSource struct {
 	db       *sql.DB
 	syncer   *replication.BinlogSyncer
 	streamer *replication.BinlogStreamer
-	eof      bool
+	// syncer 的配置，重连时用于重新创建 syncer
+	syncerCfg replication.BinlogSyncerConfig
+	ctx       context.Context // Open 时传入的 context，取消后停止读取
+	eof       bool
+	flavor    string // mysql / mariadb，为空时根据服务端版本自动识别
+	rawMode   bool   // 原始模式：不解析事件，用于 fetch 镜像 binlog 文件
 
-	// 保存的表映射（用于事件转换）
-	tableMap map[uint64]*replication.TableMapEvent
+	// 当前语句的原始 SQL（ROWS_QUERY / ANNOTATE_ROWS 事件）
+	rowsQuery string
+
+	// 保存的表映射（用于事件转换）和 TABLE_MAP 事件的原始字节（用于生成 flashback binlog）
+	tableMap     map[uint64]*replication.TableMapEvent
+	tableMapData map[uint64][]byte
 
 	// 指定的起始位置（可选）
-	startFile string
-	startPos  uint32
+	startFile    string
+	startPos     uint32
+	startGTIDSet string // 指定后使用 GTID 方式开始同步，优先于 startFile/startPos
+
+	// GTID 过滤
+	includeGTIDs string
+	excludeGTIDs string
+	gtids        *gtidTracker
 
 	// 当前正在读取的 binlog 文件名
 	currentLogName string
@@ -39,13 +53,22 @@ type MySQLSource struct {
 	startTime time.Time
 	endTime   time.Time
 	mu        sync.RWMutex
+
+	// 多行 RowsEvent 拆分后尚未返回的行事件
+	pending []*models.Event
+
+	// 断线重连
+	reconnectAttempts int           // 最大重连次数，0 表示不重连，负数表示无限重连
+	reconnectInterval time.Duration // 首次重连等待时间，之后每次翻倍
+	resume            resumeState   // 续传位置
 }
 
 // NewMySQLSource 创建 MySQL 数据源
 func NewMySQLSource(dsn string) *MySQLSource {
 	return &MySQLSource{
-		dsn:      dsn,
-		tableMap: make(map[uint64]*replication.TableMapEvent),
+		dsn:          dsn,
+		tableMap:     make(map[uint64]*replication.TableMapEvent),
+		tableMapData: make(map[uint64][]byte),
 	}
 }
 
@@ -55,6 +78,36 @@ func (ms *MySQLSource) SetStartPosition(file string, pos uint32) {
 	ms.startPos = pos
 }
 
+// SetReconnect 设置断线重连策略
+// attempts 为最大重连次数（0 表示不重连，负数表示无限重连），interval 为首次重连等待时间，之后每次翻倍
+func (ms *MySQLSource) SetReconnect(attempts int, interval time.Duration) {
+	ms.reconnectAttempts = attempts
+	ms.reconnectInterval = interval
+}
+
+// SetRawMode 设置原始模式
+// 原始模式下通过 ReadRawEvent 读取未解析的事件，并校验每个事件的校验和；
+// 未指定起始位置时从当前 binlog 文件的开头开始，以便完整镜像该文件
+func (ms *MySQLSource) SetRawMode(raw bool) {
+	ms.rawMode = raw
+}
+
+// SetFlavor 设置服务端类型（mysql / mariadb），为空时根据服务端版本自动识别
+func (ms *MySQLSource) SetFlavor(flavor string) {
+	ms.flavor = flavor
+}
+
+// SetStartGTIDSet 设置起始 GTID 集合，从该集合之后的第一个事务开始同步
+func (ms *MySQLSource) SetStartGTIDSet(gtidSet string) {
+	ms.startGTIDSet = gtidSet
+}
+
+// SetGTIDFilter 设置 GTID 过滤，include/exclude 为 GTID 集合字符串，为空表示不限制
+func (ms *MySQLSource) SetGTIDFilter(include, exclude string) {
+	ms.includeGTIDs = include
+	ms.excludeGTIDs = exclude
+}
+
 // SetTimeRange 设置时间范围过滤
 func (ms *MySQLSource) SetTimeRange(start, end time.Time) {
 	ms.mu.Lock()
@@ -65,44 +118,75 @@ func (ms *MySQLSource) SetTimeRange(start, end time.Time) {
 
 // Open 连接到 MySQL
 func (ms *MySQLSource) Open(ctx context.Context) error {
-	// 1. 测试标准 SQL 连接（用于列名缓存）
-	db, err := sql.Open("mysql", ms.dsn)
+	ms.ctx = ctx
+
+	// 1. 解析 DSN 并测试标准 SQL 连接（用于列名缓存）
+	dsnCfg, err := parseDSN(ms.dsn)
+	if err != nil {
+		return fmt.Errorf("failed to parse DSN: %w", err)
+	}
+	connector, err := mysqldriver.NewConnector(dsnCfg.driver)
 	if err != nil {
 		return fmt.Errorf("failed to open MySQL connection: %w", err)
 	}
+	db := sql.OpenDB(connector)
 
 	if err := db.PingContext(ctx); err != nil {
 		return fmt.Errorf("failed to ping MySQL: %w", err)
 	}
 	ms.db = db
 
-	// 2. 解析 DSN 以获取连接参数
-	cfg, err := parseMySQLDSN(ms.dsn)
-	if err != nil {
-		return fmt.Errorf("failed to parse DSN: %w", err)
+	// 识别服务端类型（MySQL / MariaDB），GTID 格式和部分状态语句与之相关
+	if ms.flavor == "" {
+		flavor, err := detectFlavor(ctx, db)
+		if err != nil {
+			return err
+		}
+		ms.flavor = flavor
 	}
 
-	// 3. 创建 Binlog Syncer（用于读取二进制日志）
-	syncerCfg := replication.BinlogSyncerConfig{
-		ServerID: 1000, // 需要唯一的 server ID
-		Flavor:   "mysql",
-		Host:     cfg["host"],
-		Port:     parsePort(cfg["port"]),
-		User:     cfg["user"],
-		Password: cfg["password"],
+	filter, err := newGTIDFilter(ms.flavor, ms.includeGTIDs, ms.excludeGTIDs)
+	if err != nil {
+		return err
 	}
+	ms.gtids = newGTIDTracker(filter)
+
+	// 2. 创建 Binlog Syncer（用于读取二进制日志）
+	// server_id、TLS、心跳、读超时和字符集均来自 DSN 参数
+	syncerCfg := dsnCfg.syncerConfig(ms.flavor)
+	syncerCfg.RawModeEnabled = ms.rawMode
+	syncerCfg.VerifyChecksum = ms.rawMode
+	ms.syncerCfg = syncerCfg
 
 	syncer := replication.NewBinlogSyncer(syncerCfg)
 	ms.syncer = syncer
 
-	// 4. 检查 binlog 是否启用
+	// 3. 检查 binlog 是否启用
 	var logBin string
 	err = db.QueryRowContext(ctx, "SHOW VARIABLES LIKE 'log_bin'").Scan(new(string), &logBin)
 	if err != nil || logBin != "ON" {
 		return fmt.Errorf("binary logging is not enabled on this MySQL server. Please enable binlog in my.cnf with:\n  log-bin=mysql-bin\n  server-id=1")
 	}
 
-	// 5. 获取当前 binlog 位置并开始同步
+	// 4. 指定了起始 GTID 集合时使用 GTID 方式同步，由服务端定位起始位置
+	if ms.startGTIDSet != "" {
+		gset, err := mysql.ParseGTIDSet(ms.flavor, ms.startGTIDSet)
+		if err != nil {
+			return fmt.Errorf("invalid start-gtid-set %q: %w", ms.startGTIDSet, err)
+		}
+		// syncer 会在后台向传入的集合追加已接收的 GTID，断点集合需要单独保存一份
+		ms.resume = resumeState{gtidSet: gset.Clone()}
+		streamer, err := syncer.StartSyncGTID(gset)
+		if err != nil {
+			return fmt.Errorf("failed to start binlog sync from GTID set: %w", err)
+		}
+		ms.streamer = streamer
+		ms.eof = false
+		// 当前 binlog 文件名由服务端发送的 ROTATE 事件填充
+		return nil
+	}
+
+	// 5. 获取当前 binlog 位置
 	var binlogFile string
 	var binlogPos uint32
 
@@ -112,11 +196,10 @@ func (ms *MySQLSource) Open(ctx context.Context) error {
 		binlogPos = ms.startPos
 	} else {
 		// 自动检测 binlog 位置
-		// 尝试 SHOW MASTER STATUS（主库或独立实例）
-		row := db.QueryRowContext(ctx, "SHOW MASTER STATUS")
-		err = row.Scan(&binlogFile, &binlogPos)
+		// 尝试 SHOW BINLOG STATUS / SHOW BINARY LOG STATUS / SHOW MASTER STATUS（主库或独立实例）
+		binlogFile, binlogPos, err = queryBinlogStatus(ctx, db, ms.flavor)
 		if err == sql.ErrNoRows || binlogFile == "" {
-			// SHOW MASTER STATUS 返回空，可能是：
+			// 主库状态返回空，可能是：
 			// 1. 从库 - 尝试 SHOW REPLICA/SLAVE STATUS
 			// 2. 主库但没有写入过数据 - 尝试 SHOW BINARY LOGS
 
@@ -251,7 +334,11 @@ func (ms *MySQLSource) Open(ctx context.Context) error {
 				}
 			}
 		} else if err != nil {
-			return fmt.Errorf("failed to execute SHOW MASTER STATUS: %w", err)
+			return fmt.Errorf("failed to query binlog status: %w", err)
+		}
+		// 原始模式镜像整个文件，从文件开头开始
+		if ms.rawMode {
+			binlogPos = 4
 		}
 	}
 
@@ -263,6 +350,7 @@ func (ms *MySQLSource) Open(ctx context.Context) error {
 	ms.streamer = streamer
 	ms.eof = false
 	ms.currentLogName = binlogFile // 设置当前 binlog 文件名
+	ms.resume = resumeState{pos: mysql.Position{Name: binlogFile, Pos: binlogPos}}
 
 	return nil
 }
@@ -289,31 +377,74 @@ func (ms *MySQLSource) Close() error {
 }
 
 // Read 读取下一个事件
+// 连接中断时按配置自动重连，从最后一个完整事务之后继续读取，不会重复返回事件
 func (ms *MySQLSource) Read() (*models.Event, error) {
-	if ms.eof || ms.streamer == nil {
-		return nil, fmt.Errorf("EOF")
-	}
+	for {
+		// 优先返回多行事件中尚未取走的行
+		if len(ms.pending) > 0 {
+			event := ms.pending[0]
+			ms.pending = ms.pending[1:]
+			return event, nil
+		}
 
-	// 从 binlog streamer 读取事件
-	ctx := context.Background()
-	ev, err := ms.streamer.GetEvent(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read binlog event: %w", err)
-	}
+		if ms.eof || ms.streamer == nil {
+			return nil, fmt.Errorf("EOF")
+		}
+
+		// 从 binlog streamer 读取事件
+		ev, err := ms.streamer.GetEvent(ms.ctx)
+		if err == nil {
+			// 转换 go-mysql 的事件为项目中的 Event 模型，跳过重连后重复读取的事件
+			events, err := ms.convertEvent(ev)
+			if err != nil {
+				return nil, err
+			}
+			events = ms.resume.filter(events)
+			ms.resume.advance(ev, ms.currentLogName, ms.gtids.current)
+			if len(events) == 0 {
+				// 某些事件类型我们不关心（如 TABLE_MAP），继续读下一个
+				continue
+			}
+
+			ms.pending = events[1:]
+			return events[0], nil
+		}
 
-	// 转换 go-mysql 的事件为项目中的 Event 模型
-	event := ms.convertEvent(ev)
-	if event == nil {
-		// 某些事件类型我们不关心（如 TABLE_MAP），继续读下一个
-		return ms.Read()
+		if err := ms.recoverStream(err); err != nil {
+			return nil, err
+		}
 	}
+}
+
+// ReadRawEvent 读取下一个未经转换的原始事件，用于原始模式（SetRawMode）
+// 事件中的 RawData 与服务端 binlog 文件中的字节完全一致；连接中断时从最后一个事件之后重连
+func (ms *MySQLSource) ReadRawEvent() (*replication.BinlogEvent, error) {
+	for {
+		if ms.eof || ms.streamer == nil {
+			return nil, fmt.Errorf("EOF")
+		}
+
+		ev, err := ms.streamer.GetEvent(ms.ctx)
+		if err == nil {
+			if e, ok := ev.Event.(*replication.RotateEvent); ok {
+				ms.currentLogName = string(e.NextLogName)
+				ms.resume.pos = mysql.Position{Name: ms.currentLogName, Pos: uint32(e.Position)}
+			} else if ev.Header.LogPos > 0 && ev.Header.Flags&replication.LOG_EVENT_ARTIFICIAL_F == 0 {
+				// 原始模式逐个事件写入，续传位置跟随每个真实事件推进
+				ms.resume.pos = mysql.Position{Name: ms.currentLogName, Pos: ev.Header.LogPos}
+			}
+			return ev, nil
+		}
 
-	return event, nil
+		if err := ms.recoverStream(err); err != nil {
+			return nil, err
+		}
+	}
 }
 
 // HasMore 是否还有更多数据
 func (ms *MySQLSource) HasMore() bool {
-	return !ms.eof
+	return !ms.eof || len(ms.pending) > 0
 }
 
 // GetDB 获取数据库连接（用于列名缓存）
@@ -322,9 +453,29 @@ func (ms *MySQLSource) GetDB() *sql.DB {
 }
 
 // convertEvent 将 go-mysql Event 转换为项目中的 Event 模型
-func (ms *MySQLSource) convertEvent(ev *replication.BinlogEvent) *models.Event {
+// 多行 RowsEvent 会按行拆分为多个事件，不关心的事件返回 nil；压缩事务无法展开时返回错误
+func (ms *MySQLSource) convertEvent(ev *replication.BinlogEvent) ([]*models.Event, error) {
 	if ev == nil {
-		return nil
+		return nil, nil
+	}
+
+	// 文件名和 TABLE_MAP 的记录不受 GTID 和时间范围过滤影响，过滤只决定事件是否输出，
+	// 否则被过滤掉的 ROTATE 会使之后事件的 LogName 和续传位置停留在旧文件
+	switch e := ev.Event.(type) {
+	case *replication.TableMapEvent:
+		// TABLE_MAP_EVENT: 保存表信息，用于后续的 ROWS_EVENT
+		ms.tableMap[e.TableID] = e
+		ms.tableMapData[e.TableID] = ev.RawData
+		return nil, nil // 不返回这种事件
+
+	case *replication.RotateEvent:
+		// ROTATE_EVENT: binlog 轮转，更新当前文件名
+		ms.currentLogName = string(e.NextLogName)
+		return nil, nil
+
+	case *replication.FormatDescriptionEvent:
+		// FORMAT_DESCRIPTION_EVENT: binlog 格式描述
+		return nil, nil
 	}
 
 	// 转换时间戳（Unix 时间戳 -> time.Time）
@@ -336,8 +487,16 @@ func (ms *MySQLSource) convertEvent(ev *replication.BinlogEvent) *models.Event {
 		ServerID:  ev.Header.ServerID,
 		LogName:   ms.currentLogName, // 填充当前 binlog 文件名
 		LogPos:    ev.Header.LogPos,
+		EventSize: ev.Header.EventSize,
 	}
 
+	// 记录所属事务的 GTID，跳过被 GTID 过滤掉的事务
+	gtid, matched := ms.gtids.observe(ev)
+	if !matched {
+		return nil, nil
+	}
+	event.GTID = gtid
+
 	// 检查时间范围
 	ms.mu.RLock()
 	startTime := ms.startTime
@@ -346,80 +505,71 @@ func (ms *MySQLSource) convertEvent(ev *replication.BinlogEvent) *models.Event {
 
 	// 如果事件时间早于开始时间，跳过
 	if !startTime.IsZero() && timestamp.Before(startTime) {
-		return nil
+		return nil, nil
 	}
 
 	// 如果事件时间晚于结束时间，标记为 EOF 并返回 nil
 	if !endTime.IsZero() && timestamp.After(endTime) {
 		ms.eof = true
-		return nil
+		return nil, nil
 	}
 
 	// 根据事件类型提取具体信息
 	switch e := ev.Event.(type) {
 	case *replication.QueryEvent:
 		// QUERY_EVENT: CREATE/DROP/ALTER 等 DDL 操作
+		ms.rowsQuery = ""
+		event.SQL = string(e.Query)
 		event.Database = string(e.Schema)
-		event.Action = extractAction(string(e.Query))
+		event.Action = extractAction(event.SQL)
+		event.ThreadID = e.SlaveProxyID
 
 	case *replication.RowsEvent:
 		// ROWS_EVENT: INSERT/UPDATE/DELETE 等 DML 操作
 		// 需要从之前保存的 TABLE_MAP 中获取表信息
 		tableMap, ok := ms.tableMap[e.TableID]
 		if !ok {
-			return event // 没有对应的 TABLE_MAP，无法处理
+			return []*models.Event{event}, nil // 没有对应的 TABLE_MAP，无法处理
 		}
 
 		event.Database = string(tableMap.Schema)
 		event.Table = string(tableMap.Table)
 
-		// 根据事件类型判断操作
-		switch ev.Header.EventType {
-		case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
-			event.Action = "INSERT"
-			// 对于 INSERT，Rows[0] 是插入的数据
-			if len(e.Rows) > 0 {
-				event.AfterValues = ms.rowToMap(e.Rows[0], e)
-			}
-		case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
-			event.Action = "UPDATE"
-			// 对于 UPDATE，Rows 成对出现：[before, after, before, after, ...]
-			if len(e.Rows) >= 2 {
-				event.BeforeValues = ms.rowToMap(e.Rows[0], e)
-				event.AfterValues = ms.rowToMap(e.Rows[1], e)
-			}
-		case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
-			event.Action = "DELETE"
-			// 对于 DELETE，Rows[0] 是被删除的数据
-			if len(e.Rows) > 0 {
-				event.BeforeValues = ms.rowToMap(e.Rows[0], e)
-			}
-		}
+		// 根据事件类型判断操作，并按行拆分
+		// INSERT/DELETE 每行一个事件；UPDATE 的 Rows 成对出现：[before, after, before, after, ...]
+		event.Action = rowsEventAction(ev.Header.EventType)
+		event.OriginalSQL = ms.rowsQuery
+		event.RawRows = newRawRowsEvent(ev.RawData, ms.tableMapData[e.TableID], e)
+		return splitRowsEvent(event, e), nil
 
-	case *replication.TableMapEvent:
-		// TABLE_MAP_EVENT: 保存表信息，用于后续的 ROWS_EVENT
-		ms.tableMap[e.TableID] = e
-		return nil // 不返回这种事件
+	case *replication.RowsQueryEvent:
+		// ROWS_QUERY_EVENT（binlog_rows_query_log_events=ON）: 记录原始 SQL，附加到随后的行事件
+		ms.rowsQuery = string(e.Query)
+		return nil, nil
 
-	case *replication.RotateEvent:
-		// ROTATE_EVENT: binlog 轮转，更新当前文件名
-		ms.currentLogName = string(e.NextLogName)
-		return nil
+	case *replication.MariadbAnnotateRowsEvent:
+		// ANNOTATE_ROWS_EVENT（MariaDB binlog_annotate_row_events=ON）: 同 ROWS_QUERY_EVENT
+		ms.rowsQuery = string(e.Query)
+		return nil, nil
 
-	case *replication.FormatDescriptionEvent:
-		// FORMAT_DESCRIPTION_EVENT: binlog 格式描述
-		return nil
+	case *replication.TransactionPayloadEvent:
+		// TRANSACTION_PAYLOAD_EVENT: 压缩的事务，展开内部事件后按正常流程转换
+		return expandTransactionPayload(event, e, ms.convertEvent)
 
 	case *replication.XIDEvent:
 		// XID_EVENT: 事务提交
-		return nil
+		ms.rowsQuery = ""
+		event.Action = "COMMIT"
 
 	default:
-		// 其他事件类型暂不处理
-		return nil
+		// GTID 事件标记事务的开始，其他事件类型暂不处理
+		if _, ok := eventGTID(ev); !ok {
+			return nil, nil
+		}
+		event.Action = "GTID"
 	}
 
-	return event
+	return []*models.Event{event}, nil
 }
 
 // extractAction 从 SQL 语句中提取操作类型
@@ -447,76 +597,3 @@ func extractAction(query string) string {
 
 	return "QUERY"
 }
-
-// parseMySQLDSN 解析 MySQL DSN
-// 支持格式: user:password@tcp(host:port)/database?charset=utf8mb4
-func parseMySQLDSN(dsn string) (map[string]string, error) {
-	cfg := map[string]string{
-		"user":     "root",
-		"password": "",
-		"host":     "127.0.0.1",
-		"port":     "3306",
-	}
-
-	// 移除数据库和参数部分
-	dsn = strings.Split(dsn, "?")[0]
-	dsn = strings.Split(dsn, "/")[0]
-
-	// 提取用户和密码
-	if idx := strings.Index(dsn, "@"); idx != -1 {
-		userPass := dsn[:idx]
-		if pidx := strings.Index(userPass, ":"); pidx != -1 {
-			cfg["user"] = userPass[:pidx]
-			cfg["password"] = userPass[pidx+1:]
-		} else {
-			cfg["user"] = userPass
-		}
-		dsn = dsn[idx+1:]
-	}
-
-	// 提取 host 和 port
-	// 格式: tcp(host:port) 或 host:port
-	re := regexp.MustCompile(`(?:tcp\()?([^:)]+)(?::(\d+))?\)?`)
-	matches := re.FindStringSubmatch(dsn)
-	if len(matches) >= 2 {
-		cfg["host"] = matches[1]
-		if len(matches) >= 3 && matches[2] != "" {
-			cfg["port"] = matches[2]
-		}
-	}
-
-	return cfg, nil
-}
-
-// parsePort 解析端口号
-func parsePort(portStr string) uint16 {
-	port := 3306
-	if p, err := strconv.Atoi(portStr); err == nil {
-		port = p
-	}
-	return uint16(port)
-}
-
-// rowToMap 将行数据转换为 map
-// 根据 ColumnBitmap1 确定哪些列被包含在行数据中
-func (ms *MySQLSource) rowToMap(row []interface{}, rowsEvent *replication.RowsEvent) map[string]interface{} {
-	if row == nil || rowsEvent == nil || rowsEvent.Table == nil {
-		return make(map[string]interface{})
-	}
-
-	result := make(map[string]interface{})
-
-	// 根据 ColumnBitmap1 确定哪些列被包含
-	// ColumnBitmap1 是一个字节数组，每一位表示对应列是否被包含
-	includedCols := getIncludedColumnIndices(int(rowsEvent.Table.ColumnCount), rowsEvent.ColumnBitmap1)
-
-	// 将 row 中的数据按照 includedCols 映射到正确的列号
-	for i, col := range row {
-		if i < len(includedCols) {
-			colName := fmt.Sprintf("col_%d", includedCols[i])
-			result[colName] = col
-		}
-	}
-
-	return result
-}
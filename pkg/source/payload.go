@@ -0,0 +1,28 @@
+package source
+
+import (
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// expandTransactionPayload 展开 TRANSACTION_PAYLOAD_EVENT（binlog_transaction_compression=ON）
+// 返回载荷事件本身（记录压缩前后大小）以及按正常流程转换后的内部事件。
+// 内部事件没有独立的 binlog 位置，统一使用载荷事件的结束位置，断点续看时整个事务作为一个单元
+func expandTransactionPayload(event *models.Event, e *replication.TransactionPayloadEvent,
+	convert func(*replication.BinlogEvent) ([]*models.Event, error)) ([]*models.Event, error) {
+	event.CompressedSize = e.Size
+	event.UncompressedSize = e.UncompressedSize
+
+	events := []*models.Event{event}
+	for _, inner := range e.Events {
+		innerEvents, err := convert(inner)
+		for _, innerEvent := range innerEvents {
+			innerEvent.LogPos = event.LogPos
+		}
+		events = append(events, innerEvents...)
+		if err != nil {
+			return events, err
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,103 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+func TestFileSourceTransactionPayload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql-bin.000001")
+	b := newBinlogBuilder().payload(
+		encodeEvent(1700000001, replication.QUERY_EVENT, 0, queryBody("db", "BEGIN"), false),
+		encodeEvent(1700000001, replication.QUERY_EVENT, 0, queryBody("db", "q1"), false),
+	)
+	appendFile(t, path, b.take())
+
+	fs := NewFileSource(path)
+	if err := fs.Open(context.Background()); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer fs.Close()
+
+	var payloadSeen bool
+	var queries []string
+	for {
+		event, err := fs.Read()
+		if err != nil {
+			if err.Error() != "EOF" {
+				t.Fatalf("read: %v", err)
+			}
+			break
+		}
+		if event == nil {
+			continue
+		}
+		if event.CompressedSize > 0 {
+			payloadSeen = true
+			if event.UncompressedSize == 0 {
+				t.Errorf("expected uncompressed size on payload event")
+			}
+		}
+		if event.Action == "QUERY" || event.Action == "BEGIN" {
+			queries = append(queries, event.SQL)
+			if event.LogPos != b.pos {
+				t.Errorf("inner event should use payload end position %d, got %d", b.pos, event.LogPos)
+			}
+		}
+	}
+
+	if !payloadSeen {
+		t.Error("expected payload event")
+	}
+	assertNames(t, queries, "BEGIN", "q1")
+}
+
+func TestExpandTransactionPayloadReturnsConvertError(t *testing.T) {
+	payload := &replication.TransactionPayloadEvent{
+		Size:             10,
+		UncompressedSize: 20,
+		Events:           []*replication.BinlogEvent{{}, {}, {}},
+	}
+	calls := 0
+	events, err := expandTransactionPayload(&models.Event{LogPos: 500}, payload, func(inner *replication.BinlogEvent) ([]*models.Event, error) {
+		calls++
+		if calls == 2 {
+			return nil, fmt.Errorf("corrupt rows")
+		}
+		return []*models.Event{{Action: "INSERT"}}, nil
+	})
+
+	// 出错后不再展开之后的事件，已经转换的事件仍然带有 payload 的位置
+	if err == nil || err.Error() != "corrupt rows" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if calls != 2 || len(events) != 2 || events[1].LogPos != 500 {
+		t.Errorf("calls %d, events %+v", calls, events)
+	}
+}
+
+func TestMySQLSourceTransactionPayload(t *testing.T) {
+	query := &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: replication.QUERY_EVENT, Timestamp: 1700000001},
+		Event:  &replication.QueryEvent{Schema: []byte("db"), Query: []byte("BEGIN")},
+	}
+	ev := &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: replication.TRANSACTION_PAYLOAD_EVENT, Timestamp: 1700000001, LogPos: 900},
+		Event:  &replication.TransactionPayloadEvent{Size: 10, UncompressedSize: 20, Events: []*replication.BinlogEvent{query}},
+	}
+
+	ms := NewMySQLSource("")
+	ms.gtids = newGTIDTracker(nil)
+	events, err := ms.convertEvent(ev)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if len(events) != 2 || events[1].Action != "BEGIN" || events[1].LogPos != 900 {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,153 @@
+package source
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// maxReconnectInterval 重连等待时间的上限
+const maxReconnectInterval = time.Minute
+
+// resumeState 记录断线重连时的续传位置
+// 位置只在事务结束（XID / DDL / COMMIT）后推进，重连后从当前事务开头重新读取，
+// 并跳过当前事务中已经返回过的事件，保证不重复
+type resumeState struct {
+	pos      mysql.Position // 最后一个完整事务之后的位置
+	gtidSet  mysql.GTIDSet  // 已完整读取的 GTID 集合，为 nil 时按位置续传
+	seen     int            // 本次连接中当前事务已转换出的事件数
+	returned int            // 当前事务中已经返回给调用方的事件数（跨重连累计）
+}
+
+// filter 过滤掉当前事务中已经返回过的事件（仅重连后重新读取时生效）
+func (r *resumeState) filter(events []*models.Event) []*models.Event {
+	var kept []*models.Event
+	for _, event := range events {
+		if r.seen >= r.returned {
+			kept = append(kept, event)
+			r.returned++
+		}
+		r.seen++
+	}
+	return kept
+}
+
+// advance 在事务结束或 binlog 轮转后推进续传位置
+// gtid 为 ev 所属事务的 GTID，logName 为 ev 所在的 binlog 文件
+func (r *resumeState) advance(ev *replication.BinlogEvent, logName, gtid string) {
+	if e, ok := ev.Event.(*replication.RotateEvent); ok {
+		r.pos = mysql.Position{Name: string(e.NextLogName), Pos: uint32(e.Position)}
+		return
+	}
+	if !isTransactionEnd(ev) {
+		return
+	}
+
+	r.pos = mysql.Position{Name: logName, Pos: ev.Header.LogPos}
+	if r.gtidSet != nil && gtid != "" {
+		if err := r.gtidSet.Update(gtid); err != nil {
+			log.Printf("更新续传 GTID 集合失败: %v", err)
+		}
+	}
+	r.seen = 0
+	r.returned = 0
+}
+
+// rewind 重连成功后调用，从当前事务开头重新计数
+func (r *resumeState) rewind() {
+	r.seen = 0
+}
+
+// String 返回续传位置的描述
+func (r *resumeState) String() string {
+	if r.gtidSet != nil {
+		return fmt.Sprintf("GTID %s", r.gtidSet.String())
+	}
+	return fmt.Sprintf("%s:%d", r.pos.Name, r.pos.Pos)
+}
+
+// isTransactionEnd 判断事件是否是一个事务的结束
+// XID（InnoDB 提交）、非 BEGIN 的 QUERY（DDL 或非事务表的 COMMIT）以及压缩事务都会结束一个事务
+func isTransactionEnd(ev *replication.BinlogEvent) bool {
+	switch e := ev.Event.(type) {
+	case *replication.XIDEvent, *replication.TransactionPayloadEvent:
+		return true
+	case *replication.QueryEvent:
+		query := strings.ToUpper(strings.TrimSpace(string(e.Query)))
+		return query != "BEGIN" && !strings.HasPrefix(query, "XA START") && !strings.HasPrefix(query, "XA END")
+	}
+	return false
+}
+
+// recoverStream 处理读取错误：被取消（Ctrl+C）时返回 EOF，否则按配置重连，重连成功返回 nil
+func (ms *MySQLSource) recoverStream(cause error) error {
+	if ms.ctx.Err() == nil {
+		err := ms.reconnect(cause)
+		if err == nil {
+			return nil
+		}
+		if ms.ctx.Err() == nil {
+			return fmt.Errorf("failed to read binlog event: %w", err)
+		}
+	}
+	ms.eof = true
+	return fmt.Errorf("EOF")
+}
+
+// reconnect 断线后按指数退避重连，并从最后一个完整事务之后继续同步
+func (ms *MySQLSource) reconnect(cause error) error {
+	if ms.reconnectAttempts == 0 {
+		return cause
+	}
+
+	interval := ms.reconnectInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for attempt := 1; ms.reconnectAttempts < 0 || attempt <= ms.reconnectAttempts; attempt++ {
+		log.Printf("binlog 连接中断: %v，%s 后进行第 %d 次重连", cause, interval, attempt)
+		select {
+		case <-ms.ctx.Done():
+			return ms.ctx.Err()
+		case <-time.After(interval):
+		}
+
+		if err := ms.restartSync(); err != nil {
+			cause = err
+			interval = min(interval*2, maxReconnectInterval)
+			continue
+		}
+		log.Printf("binlog 重连成功，从 %s 继续读取", ms.resume.String())
+		return nil
+	}
+	return fmt.Errorf("reconnect failed after %d attempts: %w", ms.reconnectAttempts, cause)
+}
+
+// restartSync 重新建立复制连接，从续传位置开始同步
+func (ms *MySQLSource) restartSync() error {
+	if ms.syncer != nil {
+		ms.syncer.Close()
+	}
+	ms.syncer = replication.NewBinlogSyncer(ms.syncerCfg)
+
+	var streamer *replication.BinlogStreamer
+	var err error
+	if ms.resume.gtidSet != nil {
+		streamer, err = ms.syncer.StartSyncGTID(ms.resume.gtidSet.Clone())
+	} else {
+		streamer, err = ms.syncer.StartSync(ms.resume.pos)
+	}
+	if err != nil {
+		return err
+	}
+
+	ms.streamer = streamer
+	ms.currentLogName = ms.resume.pos.Name
+	ms.resume.rewind()
+	return nil
+}
@@ -0,0 +1,340 @@
+package source
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-mysql-org/go-mysql/server"
+)
+
+// fakeReplicationServer 本地模拟的复制端点：回答 MySQLSource / BinlogSyncer 需要的查询，
+// 并按请求的位置或 GTID 集合推送事件，第一次连接在推送 dropAfter 个事件后断开
+type fakeReplicationServer struct {
+	server.EmptyReplicationHandler
+
+	listener net.Listener
+	files    [][][]byte // 每个文件（mysql-bin.000001 起）按事件切分的内容，[0] 为 FORMAT_DESCRIPTION_EVENT
+	gtidOf   []string   // 第一个文件中每个事件所属事务的 GTID（GTID 模式使用）
+
+	mu        sync.Mutex
+	dropAfter int
+	dumps     []string // 每次 dump 请求的起点
+}
+
+func newFakeReplicationServer(t *testing.T, dropAfter int, files ...[]byte) *fakeReplicationServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeReplicationServer{
+		listener:  listener,
+		dropAfter: dropAfter,
+	}
+	for _, data := range files {
+		f.files = append(f.files, splitEvents(data[len(replication.BinLogFileHeader):]))
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	srv := server.NewServer("8.0.36", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				conn, err := srv.NewConn(c, "root", "pass", f)
+				if err != nil {
+					return
+				}
+				for !conn.Closed() {
+					if err := conn.HandleCommand(); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return f
+}
+
+func (f *fakeReplicationServer) dsn() string {
+	return fmt.Sprintf("root:pass@tcp(%s)/", f.listener.Addr().String())
+}
+
+func (f *fakeReplicationServer) HandleQuery(query string) (*mysql.Result, error) {
+	switch {
+	case strings.EqualFold(query, "SHOW VARIABLES LIKE 'log_bin'"):
+		rs, err := mysql.BuildSimpleResultset([]string{"Variable_name", "Value"}, [][]interface{}{{"log_bin", "ON"}}, false)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.NewResult(rs), nil
+	case strings.HasPrefix(query, "SHOW GLOBAL VARIABLES LIKE 'BINLOG_CHECKSUM'"):
+		rs, err := mysql.BuildSimpleResultset([]string{"Variable_name", "Value"}, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.NewResult(rs), nil
+	case strings.HasPrefix(query, "SET "):
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+func (f *fakeReplicationServer) HandleRegisterSlave(data []byte) error {
+	return nil
+}
+
+func (f *fakeReplicationServer) HandleBinlogDump(pos mysql.Position) (*replication.BinlogStreamer, error) {
+	f.mu.Lock()
+	f.dumps = append(f.dumps, fmt.Sprintf("%s:%d", pos.Name, pos.Pos))
+	f.mu.Unlock()
+
+	var index int
+	if _, err := fmt.Sscanf(pos.Name, "mysql-bin.%06d", &index); err != nil || index < 1 || index > len(f.files) {
+		return nil, fmt.Errorf("could not find first log file name in binary log index file")
+	}
+	return f.stream(index-1, pos.Pos, func(i int, logPos uint32) bool { return logPos > pos.Pos })
+}
+
+func (f *fakeReplicationServer) HandleBinlogDumpGTID(gset *mysql.MysqlGTIDSet) (*replication.BinlogStreamer, error) {
+	f.mu.Lock()
+	f.dumps = append(f.dumps, gset.String())
+	f.mu.Unlock()
+
+	return f.stream(0, 4, func(i int, logPos uint32) bool {
+		return f.gtidOf[i] == "" || !gset.Contain(mustGTIDSet(f.gtidOf[i]))
+	})
+}
+
+// stream 从第 first 个文件的 pos 位置开始推送：每个文件先推送假 ROTATE 和 FORMAT_DESCRIPTION_EVENT，
+// 第一个文件只推送 include 返回 true 的事件
+func (f *fakeReplicationServer) stream(first int, pos uint32, include func(i int, logPos uint32) bool) (*replication.BinlogStreamer, error) {
+	f.mu.Lock()
+	dropAfter := f.dropAfter
+	f.dropAfter = 0
+	f.mu.Unlock()
+
+	s := replication.NewBinlogStreamer()
+	sent := 0
+send:
+	for index := first; index < len(f.files); index++ {
+		events := f.files[index]
+		rotate := binary.LittleEndian.AppendUint64(nil, uint64(pos))
+		rotate = append(rotate, fmt.Sprintf("mysql-bin.%06d", index+1)...)
+		// 第一个假 ROTATE 在任何 FORMAT_DESCRIPTION_EVENT 之前，没有校验和；之后的按上一个文件的格式带校验和
+		s.AddEventToStreamer(&replication.BinlogEvent{RawData: encodeEvent(0, replication.ROTATE_EVENT, 0, rotate, index > first)})
+		fde := events[0]
+		if pos > 4 {
+			// 从文件中间开始时 FORMAT_DESCRIPTION_EVENT 是人工构造的，log_pos 为 0
+			fde = append([]byte(nil), fde...)
+			binary.LittleEndian.PutUint32(fde[13:17], 0)
+		}
+		s.AddEventToStreamer(&replication.BinlogEvent{RawData: fde})
+
+		for i, raw := range events[1:] {
+			if index == first && !include(i+1, binary.LittleEndian.Uint32(raw[13:17])) {
+				continue
+			}
+			if dropAfter > 0 && sent == dropAfter {
+				break send
+			}
+			s.AddEventToStreamer(&replication.BinlogEvent{RawData: raw})
+			sent++
+		}
+		pos = 4
+	}
+	// 事件发送完后关闭连接，模拟断线；GetEvent 随机选择就绪的 channel，
+	// 等事件写完再推送错误，避免错误先于事件被取出
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		s.AddErrorToStreamer(errors.New("connection dropped"))
+	}()
+	return s, nil
+}
+
+func (f *fakeReplicationServer) dumpRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.dumps...)
+}
+
+// otherUUID 测试中另一个服务器的 UUID
+const otherUUID = "5b1f4c2a-0d0e-11ef-8a4b-0242ac120002"
+
+func mustGTIDSet(s string) mysql.GTIDSet {
+	gset, err := mysql.ParseGTIDSet(mysql.MySQLFlavor, s)
+	if err != nil {
+		panic(err)
+	}
+	return gset
+}
+
+// reconnectTestBinlog 构造两个事务，返回 binlog 内容、每个事件的 GTID 以及第一个事务结束的位置
+func reconnectTestBinlog() ([]byte, []string, uint32) {
+	b := newBinlogBuilder()
+	gtidOf := []string{""}
+
+	txn := func(gno int64, rows ...testRow) {
+		gtid := fmt.Sprintf("%s:%d", testUUID, gno)
+		b.gtid(testSID, gno).query("test", "BEGIN")
+		gtidOf = append(gtidOf, gtid, gtid)
+		for _, row := range rows {
+			b.tableMap(100, "test", "t1").writeRows(100, row)
+			gtidOf = append(gtidOf, gtid, gtid)
+		}
+		b.xid(uint64(gno))
+		gtidOf = append(gtidOf, gtid)
+	}
+
+	txn(1, testRow{1, "a"}, testRow{2, "b"})
+	firstEnd := b.pos
+	txn(2, testRow{3, "c"}, testRow{4, "d"})
+	return b.data, gtidOf, firstEnd
+}
+
+// readReconnectEvents 读取 n 个事件，返回 "动作 id" 形式的描述
+func readReconnectEvents(t *testing.T, ms *MySQLSource, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		event, err := ms.Read()
+		if err != nil {
+			t.Fatalf("read after %v: %v", got, err)
+		}
+		desc := event.Action
+		if id, ok := event.AfterValues["col_0"]; ok {
+			desc = fmt.Sprintf("%s %v", desc, id)
+		}
+		got = append(got, desc)
+	}
+	return got
+}
+
+func TestMySQLSourceReconnectResumesAtTransactionBoundary(t *testing.T) {
+	data, _, firstEnd := reconnectTestBinlog()
+	// 第一次连接在第二个事务的第一行之后断开：GTID, BEGIN, TABLE_MAP, ROWS, TABLE_MAP, ROWS, XID, GTID, BEGIN, TABLE_MAP, ROWS
+	f := newFakeReplicationServer(t, 11, data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ms := NewMySQLSource(f.dsn())
+	ms.SetFlavor(mysql.MySQLFlavor)
+	ms.SetStartPosition("mysql-bin.000001", 4)
+	ms.SetReconnect(3, 10*time.Millisecond)
+	if err := ms.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer ms.Close()
+
+	got := readReconnectEvents(t, ms, 10)
+	assertNames(t, got, "GTID", "BEGIN", "INSERT 1", "INSERT 2", "COMMIT", "GTID", "BEGIN", "INSERT 3", "INSERT 4", "COMMIT")
+
+	dumps := f.dumpRequests()
+	assertNames(t, dumps, "mysql-bin.000001:4", fmt.Sprintf("mysql-bin.000001:%d", firstEnd))
+}
+
+func TestMySQLSourceReconnectResumesFromGTIDSet(t *testing.T) {
+	data, gtidOf, _ := reconnectTestBinlog()
+	f := newFakeReplicationServer(t, 11, data)
+	f.gtidOf = gtidOf
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ms := NewMySQLSource(f.dsn())
+	ms.SetFlavor(mysql.MySQLFlavor)
+	ms.SetStartGTIDSet(otherUUID + ":1-5")
+	ms.SetReconnect(3, 10*time.Millisecond)
+	if err := ms.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer ms.Close()
+
+	got := readReconnectEvents(t, ms, 10)
+	assertNames(t, got, "GTID", "BEGIN", "INSERT 1", "INSERT 2", "COMMIT", "GTID", "BEGIN", "INSERT 3", "INSERT 4", "COMMIT")
+
+	dumps := f.dumpRequests()
+	if len(dumps) != 2 {
+		t.Fatalf("expected 2 dump requests, got %v", dumps)
+	}
+	resumed := mustGTIDSet(dumps[1])
+	if !resumed.Contain(mustGTIDSet(otherUUID+":1-5,"+testUUID+":1")) || resumed.Contain(mustGTIDSet(testUUID+":2")) {
+		t.Fatalf("expected resume after %s:1, got %s", testUUID, dumps[1])
+	}
+}
+
+func TestMySQLSourceReconnectDisabled(t *testing.T) {
+	data, _, _ := reconnectTestBinlog()
+	f := newFakeReplicationServer(t, 11, data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ms := NewMySQLSource(f.dsn())
+	ms.SetFlavor(mysql.MySQLFlavor)
+	ms.SetStartPosition("mysql-bin.000001", 4)
+	ms.SetReconnect(0, 10*time.Millisecond)
+	if err := ms.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer ms.Close()
+
+	readReconnectEvents(t, ms, 8)
+	if _, err := ms.Read(); err == nil || err.Error() == "EOF" {
+		t.Fatalf("expected read error without reconnect, got %v", err)
+	}
+}
+
+func TestMySQLSourceGTIDFilterAcrossRotate(t *testing.T) {
+	// 第一个文件的事务被 GTID 过滤掉，其后的 ROTATE 仍然要更新文件名
+	first := newBinlogBuilder().
+		gtid(testSID, 1).query("test", "BEGIN").tableMap(100, "test", "t1").writeRows(100, testRow{1, "a"}).xid(1).
+		data
+	second := newBinlogBuilder().
+		gtid(testSID, 2).query("test", "BEGIN").tableMap(100, "test", "t1").writeRows(100, testRow{2, "b"}).xid(2).
+		data
+	f := newFakeReplicationServer(t, 0, first, second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ms := NewMySQLSource(f.dsn())
+	ms.SetFlavor(mysql.MySQLFlavor)
+	ms.SetStartPosition("mysql-bin.000001", 4)
+	ms.SetGTIDFilter("", testUUID+":1")
+	ms.SetReconnect(0, 10*time.Millisecond)
+	if err := ms.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer ms.Close()
+
+	var got, logNames []string
+	for len(got) < 4 {
+		event, err := ms.Read()
+		if err != nil {
+			t.Fatalf("read after %v: %v", got, err)
+		}
+		desc := event.Action
+		if id, ok := event.AfterValues["col_0"]; ok {
+			desc = fmt.Sprintf("%s %v", desc, id)
+		}
+		got = append(got, desc)
+		logNames = append(logNames, event.LogName)
+	}
+	assertNames(t, got, "GTID", "BEGIN", "INSERT 2", "COMMIT")
+	assertNames(t, logNames, "mysql-bin.000002", "mysql-bin.000002", "mysql-bin.000002", "mysql-bin.000002")
+}
@@ -0,0 +1,301 @@
+package source
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/aitoooooo/binlogx/pkg/util"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+// rowsEventAction 根据事件类型确定行事件的操作类型（包括 MariaDB 压缩行事件）
+func rowsEventAction(eventType replication.EventType) string {
+	switch eventType {
+	case replication.WRITE_ROWS_EVENTv0, replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2,
+		replication.MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1:
+		return "INSERT"
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2,
+		replication.PARTIAL_UPDATE_ROWS_EVENT, replication.MARIADB_UPDATE_ROWS_COMPRESSED_EVENT_V1:
+		return "UPDATE"
+	case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2,
+		replication.MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1:
+		return "DELETE"
+	}
+	return ""
+}
+
+// splitRowsEvent 将一个 RowsEvent 按行拆分为多个内部事件
+// INSERT/DELETE 每行生成一个事件；UPDATE 的 Rows 成对出现 [before, after, before, after, ...]，
+// 每对生成一个事件。base 中已填充的公共字段（时间、位置、库表等）会复制到每个事件上
+func splitRowsEvent(base *models.Event, e *replication.RowsEvent) []*models.Event {
+	var events []*models.Event
+
+	meta := newTableMetadata(e.Table)
+	if base.PrimaryKey == nil {
+		base.PrimaryKey = meta.primaryKey
+	}
+	if e.Table != nil {
+		base.Columns = meta.columnNames(int(e.Table.ColumnCount))
+	}
+	base.DecodedColumns = meta.decodedColumns()
+
+	newRow := func(index int) *models.Event {
+		event := *base
+		event.RowIndex = index
+		return &event
+	}
+
+	switch base.Action {
+	case "UPDATE":
+		// 后镜像的列位图为 ColumnBitmap2（binlog_row_image=MINIMAL 时通常只包含修改过的列）
+		afterBitmap := e.ColumnBitmap2
+		if afterBitmap == nil {
+			afterBitmap = e.ColumnBitmap1
+		}
+		for i := 0; i+1 < len(e.Rows); i += 2 {
+			event := newRow(i / 2)
+			event.BeforeValues, event.BeforeMissing = rowToMap(e, i, e.ColumnBitmap1, meta)
+			event.AfterValues, event.AfterMissing = rowToMap(e, i+1, afterBitmap, meta)
+			events = append(events, event)
+		}
+	case "DELETE":
+		for i := range e.Rows {
+			event := newRow(i)
+			event.BeforeValues, event.BeforeMissing = rowToMap(e, i, e.ColumnBitmap1, meta)
+			events = append(events, event)
+		}
+	default:
+		for i := range e.Rows {
+			event := newRow(i)
+			event.AfterValues, event.AfterMissing = rowToMap(e, i, e.ColumnBitmap1, meta)
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+// newRawRowsEvent 保存行事件和对应 TABLE_MAP 事件的原始字节，没有 TABLE_MAP 的原始字节时返回 nil
+func newRawRowsEvent(rows, tableMap []byte, e *replication.RowsEvent) *models.RawRowsEvent {
+	if rows == nil || tableMap == nil || e.Table == nil {
+		return nil
+	}
+	count := len(e.Rows)
+	if e.ColumnBitmap2 != nil {
+		count /= 2
+	}
+	return &models.RawRowsEvent{
+		TableMap:    tableMap,
+		Rows:        rows,
+		RowCount:    count,
+		ColumnTypes: e.Table.ColumnType,
+		ColumnMeta:  e.Table.ColumnMeta,
+	}
+}
+
+// tableMetadata TABLE_MAP_EVENT 携带的可选元数据（MySQL 8.0 binlog_row_metadata=FULL）
+// 服务端未写入的部分为 nil，此时列名退回 col_N 占位符，由命令层通过 MetaCache 映射
+type tableMetadata struct {
+	types      []byte           // 列类型
+	names      []string         // 列名
+	primaryKey []string         // 主键列名
+	unsigned   map[int]bool     // 数值列是否 unsigned
+	enums      map[int][]string // ENUM 列的取值列表
+	sets       map[int][]string // SET 列的取值列表
+	charsets   map[int]string   // 字符列（CHAR/VARCHAR/TEXT）的字符集，二进制列不在其中
+}
+
+// newTableMetadata 从 TABLE_MAP_EVENT 中提取可选元数据
+func newTableMetadata(table *replication.TableMapEvent) *tableMetadata {
+	meta := &tableMetadata{}
+	if table == nil {
+		return meta
+	}
+	meta.types = table.ColumnType
+
+	if names := table.ColumnNameString(); len(names) == int(table.ColumnCount) {
+		meta.names = names
+		for _, idx := range table.PrimaryKey {
+			if int(idx) < len(names) {
+				meta.primaryKey = append(meta.primaryKey, names[idx])
+			}
+		}
+	}
+	if len(table.ColumnType) == int(table.ColumnCount) && len(table.ColumnMeta) == int(table.ColumnCount) {
+		meta.unsigned = table.UnsignedMap()
+		meta.enums = table.EnumStrValueMap()
+		meta.sets = table.SetStrValueMap()
+		for idx, collation := range table.CollationMap() {
+			if name := util.CharsetOfCollation(collation); name != "" && name != "binary" {
+				if meta.charsets == nil {
+					meta.charsets = make(map[int]string)
+				}
+				meta.charsets[idx] = name
+			}
+		}
+	}
+	return meta
+}
+
+// columnName 返回第 idx 列的列名，没有列名元数据时返回 col_N 占位符
+func (m *tableMetadata) columnName(idx int) string {
+	if idx < len(m.names) && m.names[idx] != "" {
+		return m.names[idx]
+	}
+	return fmt.Sprintf("col_%d", idx)
+}
+
+// columnNames 返回按列序排列的全部列名
+func (m *tableMetadata) columnNames(count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = m.columnName(i)
+	}
+	return names
+}
+
+// decodedColumns 返回按字符集转码的字符列号（升序），没有字符集元数据时返回 nil
+func (m *tableMetadata) decodedColumns() []int {
+	if len(m.charsets) == 0 {
+		return nil
+	}
+	columns := make([]int, 0, len(m.charsets))
+	for idx := range m.charsets {
+		columns = append(columns, idx)
+	}
+	sort.Ints(columns)
+	return columns
+}
+
+// convertValue 根据元数据修正解码后的值（JSON 部分更新转换为 models.JSONDiff）：
+// go-mysql 总是按有符号整数解码，unsigned 列需要还原为无符号值；ENUM/SET 解码为序号和位图，转换为字符串；
+// JSON 解码为 JSON 文本，转换为 MySQL 的规范形式；GEOMETRY 为 SRID 加 WKB，转换为 WKT；
+// 字符列按列的字符集转码为 UTF-8 字符串（TEXT 列解码为 []byte，也转换为字符串）
+func (m *tableMetadata) convertValue(idx int, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	// PARTIAL_JSON 的后镜像为对原值的修改
+	if diff, ok := value.(*replication.JsonDiff); ok {
+		return models.JSONDiff{Op: strings.ToUpper(diff.Op.String()), Path: diff.Path, Value: diff.Value}
+	}
+
+	if data, ok := value.([]byte); ok && idx < len(m.types) {
+		switch m.types[idx] {
+		case mysql.MYSQL_TYPE_JSON:
+			if text, err := util.CanonicalJSON(data); err == nil {
+				return models.JSONText(text)
+			}
+			return value
+		case mysql.MYSQL_TYPE_GEOMETRY:
+			if geometry, err := util.DecodeGeometry(data); err == nil {
+				return geometry
+			}
+			return value
+		}
+	}
+
+	if name, ok := m.charsets[idx]; ok {
+		switch v := value.(type) {
+		case string:
+			s, _ := util.DecodeString(name, []byte(v))
+			return s
+		case []byte:
+			s, _ := util.DecodeString(name, v)
+			return s
+		}
+	}
+
+	if m.unsigned[idx] {
+		switch v := value.(type) {
+		case int8:
+			return uint8(v)
+		case int16:
+			return uint16(v)
+		case int32:
+			// MEDIUMINT 也解码为 int32，按 24 位截断
+			if idx < len(m.types) && m.types[idx] == mysql.MYSQL_TYPE_INT24 {
+				return uint32(v) & 0xFFFFFF
+			}
+			return uint32(v)
+		case int64:
+			return uint64(v)
+		}
+	}
+
+	if values, ok := m.enums[idx]; ok {
+		if index, ok := value.(int64); ok {
+			// 序号从 1 开始，0 表示非法值写入的空字符串
+			if index >= 1 && int(index) <= len(values) {
+				return values[index-1]
+			}
+			return ""
+		}
+	}
+
+	if values, ok := m.sets[idx]; ok {
+		if bits, ok := value.(int64); ok {
+			var members []string
+			for i, member := range values {
+				if bits&(1<<uint(i)) != 0 {
+					members = append(members, member)
+				}
+			}
+			return strings.Join(members, ",")
+		}
+	}
+
+	return value
+}
+
+// rowToMap 将第 rowIdx 行的数据转换为 map，第二个返回值为镜像中缺失的列号
+// go-mysql 解码后的行总是包含全部列（按列号排列），不在列位图中的列值也是 nil；
+// binlog_row_image=MINIMAL/NOBLOB 时这些列不在 map 中，而是记录为缺失，不能当作 NULL
+// bitmap 为该镜像对应的列位图：前镜像和 INSERT/DELETE 为 ColumnBitmap1，UPDATE 的后镜像为 ColumnBitmap2
+func rowToMap(e *replication.RowsEvent, rowIdx int, bitmap []byte, meta *tableMetadata) (map[string]interface{}, []int) {
+	result := make(map[string]interface{})
+	if e == nil || e.Table == nil || rowIdx >= len(e.Rows) {
+		return result, nil
+	}
+	row := e.Rows[rowIdx]
+
+	var missing []int
+	if rowIdx < len(e.SkippedColumns) {
+		missing = e.SkippedColumns[rowIdx]
+	} else {
+		missing = missingColumnIndices(len(row), bitmap)
+	}
+	skipped := make(map[int]bool, len(missing))
+	for _, idx := range missing {
+		skipped[idx] = true
+	}
+
+	// 有列名元数据时直接使用列名
+	for idx, col := range row {
+		if !skipped[idx] {
+			result[meta.columnName(idx)] = meta.convertValue(idx, col)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+	return result, append([]int(nil), missing...)
+}
+
+// missingColumnIndices 根据列位图获取不在镜像中的列号列表
+// 列位图中的每一位对应一列，1 表示包含，0 表示不包含
+func missingColumnIndices(totalColumns int, columnBitmap []byte) []int {
+	var missing []int
+	for colIdx := 0; colIdx < totalColumns; colIdx++ {
+		byteIdx := colIdx / 8
+		bitIdx := colIdx % 8
+		if byteIdx >= len(columnBitmap) || columnBitmap[byteIdx]&(1<<uint(bitIdx)) == 0 {
+			missing = append(missing, colIdx)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,249 @@
+package source
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+)
+
+func newTestRowsEvent(rows ...[]interface{}) *replication.RowsEvent {
+	return &replication.RowsEvent{
+		Table:         &replication.TableMapEvent{Schema: []byte("testdb"), Table: []byte("users"), ColumnCount: 2},
+		ColumnCount:   2,
+		ColumnBitmap1: []byte{0x03},
+		Rows:          rows,
+	}
+}
+
+func TestSplitRowsEventInsert(t *testing.T) {
+	base := &models.Event{Database: "testdb", Table: "users", Action: "INSERT", LogPos: 100}
+	e := newTestRowsEvent(
+		[]interface{}{int32(1), "a"},
+		[]interface{}{int32(2), "b"},
+		[]interface{}{int32(3), "c"},
+	)
+
+	events := splitRowsEvent(base, e)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+
+	for i, event := range events {
+		if event.RowIndex != i {
+			t.Errorf("event %d: expected RowIndex %d, got %d", i, i, event.RowIndex)
+		}
+		if event.LogPos != 100 || event.Table != "users" {
+			t.Errorf("event %d: base fields not copied: %+v", i, event)
+		}
+		if event.AfterValues["col_0"] != int32(i+1) {
+			t.Errorf("event %d: unexpected col_0 %v", i, event.AfterValues["col_0"])
+		}
+		if event.BeforeValues != nil {
+			t.Errorf("event %d: INSERT should not have BeforeValues", i)
+		}
+		if !reflect.DeepEqual(event.Columns, []string{"col_0", "col_1"}) {
+			t.Errorf("event %d: unexpected columns %v", i, event.Columns)
+		}
+	}
+}
+
+func TestSplitRowsEventUpdatePairs(t *testing.T) {
+	base := &models.Event{Action: "UPDATE"}
+	e := newTestRowsEvent(
+		[]interface{}{int32(1), "old1"},
+		[]interface{}{int32(1), "new1"},
+		[]interface{}{int32(2), "old2"},
+		[]interface{}{int32(2), "new2"},
+	)
+
+	events := splitRowsEvent(base, e)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	if events[1].RowIndex != 1 {
+		t.Errorf("expected RowIndex 1, got %d", events[1].RowIndex)
+	}
+	if events[1].BeforeValues["col_1"] != "old2" || events[1].AfterValues["col_1"] != "new2" {
+		t.Errorf("unexpected before/after pair: %v -> %v", events[1].BeforeValues, events[1].AfterValues)
+	}
+}
+
+func TestSplitRowsEventDelete(t *testing.T) {
+	base := &models.Event{Action: "DELETE"}
+	e := newTestRowsEvent(
+		[]interface{}{int32(1), "a"},
+		[]interface{}{int32(2), "b"},
+	)
+
+	events := splitRowsEvent(base, e)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	for _, event := range events {
+		if event.BeforeValues == nil || event.AfterValues != nil {
+			t.Errorf("DELETE should only have BeforeValues: %+v", event)
+		}
+	}
+}
+
+// newFullMetadataRowsEvent 构造 binlog_row_metadata=FULL 时的行事件：
+// (id INT UNSIGNED, score MEDIUMINT UNSIGNED, delta INT, status ENUM('new','done'), tags SET('a','b','c'))
+func newFullMetadataRowsEvent(rows ...[]interface{}) *replication.RowsEvent {
+	table := &replication.TableMapEvent{
+		Schema:      []byte("testdb"),
+		Table:       []byte("orders"),
+		ColumnCount: 5,
+		ColumnType: []byte{
+			mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_INT24, mysql.MYSQL_TYPE_LONG,
+			mysql.MYSQL_TYPE_STRING, mysql.MYSQL_TYPE_STRING,
+		},
+		ColumnMeta:       []uint16{0, 0, 0, uint16(mysql.MYSQL_TYPE_ENUM)<<8 | 1, uint16(mysql.MYSQL_TYPE_SET)<<8 | 1},
+		SignednessBitmap: []byte{0xC0}, // 按数值列依次排列：id、score 为 unsigned
+		ColumnName:       [][]byte{[]byte("id"), []byte("score"), []byte("delta"), []byte("status"), []byte("tags")},
+		PrimaryKey:       []uint64{0},
+		EnumStrValue:     [][][]byte{{[]byte("new"), []byte("done")}},
+		SetStrValue:      [][][]byte{{[]byte("a"), []byte("b"), []byte("c")}},
+	}
+	return &replication.RowsEvent{
+		Table:         table,
+		ColumnCount:   5,
+		ColumnBitmap1: []byte{0x1F},
+		Rows:          rows,
+	}
+}
+
+func TestSplitRowsEventFullMetadata(t *testing.T) {
+	base := &models.Event{Action: "INSERT"}
+	e := newFullMetadataRowsEvent([]interface{}{int32(-1), int32(-1), int32(-1), int64(2), int64(5)})
+
+	events := splitRowsEvent(base, e)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	event := events[0]
+
+	want := map[string]interface{}{
+		"id":     uint32(4294967295),
+		"score":  uint32(16777215),
+		"delta":  int32(-1),
+		"status": "done",
+		"tags":   "a,c",
+	}
+	for name, value := range want {
+		if event.AfterValues[name] != value {
+			t.Errorf("column %s: expected %v (%T), got %v (%T)", name, value, value, event.AfterValues[name], event.AfterValues[name])
+		}
+	}
+	if len(event.PrimaryKey) != 1 || event.PrimaryKey[0] != "id" {
+		t.Errorf("expected primary key [id], got %v", event.PrimaryKey)
+	}
+	if !reflect.DeepEqual(event.Columns, []string{"id", "score", "delta", "status", "tags"}) {
+		t.Errorf("unexpected column order %v", event.Columns)
+	}
+}
+
+func TestSplitRowsEventFullMetadataPartialImage(t *testing.T) {
+	// binlog_row_image=MINIMAL 时只包含部分列，go-mysql 解码后的行仍按列号排列，缺失的列为 nil
+	base := &models.Event{Action: "DELETE"}
+	e := newFullMetadataRowsEvent([]interface{}{int32(7), nil, nil, int64(0), nil})
+	e.ColumnBitmap1 = []byte{0x19} // id, status, tags
+
+	events := splitRowsEvent(base, e)
+	values := events[0].BeforeValues
+	if len(values) != 3 || values["id"] != uint32(7) || values["status"] != "" || values["tags"] != nil {
+		t.Errorf("unexpected partial row %v", values)
+	}
+	if !reflect.DeepEqual(events[0].BeforeMissing, []int{1, 2}) {
+		t.Errorf("expected missing columns [1 2], got %v", events[0].BeforeMissing)
+	}
+	if got := events[0].MissingColumns(events[0].BeforeMissing); !reflect.DeepEqual(got, []string{"score", "delta"}) {
+		t.Errorf("unexpected missing column names %v", got)
+	}
+}
+
+func TestSplitRowsEventMinimalUpdate(t *testing.T) {
+	// 前镜像只有主键（ColumnBitmap1），后镜像只有修改过的列（ColumnBitmap2）
+	base := &models.Event{Action: "UPDATE"}
+	e := newFullMetadataRowsEvent(
+		[]interface{}{int32(7), nil, nil, nil, nil},
+		[]interface{}{nil, nil, int32(-3), nil, nil},
+	)
+	e.ColumnBitmap1 = []byte{0x01}
+	e.ColumnBitmap2 = []byte{0x04}
+	e.SkippedColumns = [][]int{{1, 2, 3, 4}, {0, 1, 3, 4}}
+
+	event := splitRowsEvent(base, e)[0]
+	if !reflect.DeepEqual(event.BeforeValues, map[string]interface{}{"id": uint32(7)}) {
+		t.Errorf("unexpected before image %v", event.BeforeValues)
+	}
+	if !reflect.DeepEqual(event.AfterValues, map[string]interface{}{"delta": int32(-3)}) {
+		t.Errorf("unexpected after image %v", event.AfterValues)
+	}
+	if !reflect.DeepEqual(event.BeforeMissing, []int{1, 2, 3, 4}) || !reflect.DeepEqual(event.AfterMissing, []int{0, 1, 3, 4}) {
+		t.Errorf("unexpected missing columns %v / %v", event.BeforeMissing, event.AfterMissing)
+	}
+}
+
+func TestConvertPartialJSON(t *testing.T) {
+	meta := &tableMetadata{}
+	diff := &replication.JsonDiff{Op: replication.JsonDiffOperationReplace, Path: "$.a", Value: "1"}
+	if got := meta.convertValue(0, diff); got != (models.JSONDiff{Op: "REPLACE", Path: "$.a", Value: "1"}) {
+		t.Errorf("unexpected json diff %#v", got)
+	}
+	if action := rowsEventAction(replication.PARTIAL_UPDATE_ROWS_EVENT); action != "UPDATE" {
+		t.Errorf("expected PARTIAL_UPDATE_ROWS_EVENT to be UPDATE, got %q", action)
+	}
+}
+
+func TestSplitRowsEventDecodesValues(t *testing.T) {
+	// (name VARCHAR CHARSET latin1, note TEXT CHARSET gbk, data BLOB, attrs JSON, location POINT)，表的默认字符集为 utf8mb4
+	table := &replication.TableMapEvent{
+		Schema:      []byte("testdb"),
+		Table:       []byte("places"),
+		ColumnCount: 5,
+		ColumnType: []byte{
+			mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_BLOB,
+			mysql.MYSQL_TYPE_JSON, mysql.MYSQL_TYPE_GEOMETRY,
+		},
+		ColumnMeta: []uint16{30, 2, 2, 4, 4},
+		// 默认排序规则 255（utf8mb4_0900_ai_ci），第 0/1/2 个字符列分别为 latin1、gbk、binary
+		DefaultCharset: []uint64{255, 0, 8, 1, 28, 2, 63},
+	}
+	point := []byte{0xE6, 0x10, 0, 0, 1, 1, 0, 0, 0}
+	point = binary.LittleEndian.AppendUint64(point, math.Float64bits(116.5))
+	point = binary.LittleEndian.AppendUint64(point, math.Float64bits(40))
+	e := &replication.RowsEvent{
+		Table:         table,
+		ColumnCount:   5,
+		ColumnBitmap1: []byte{0x1F},
+		Rows: [][]interface{}{{
+			string([]byte{'c', 'a', 'f', 0xe9}),
+			[]byte{0xd6, 0xd0, 0xce, 0xc4},
+			[]byte{0xd6, 0xd0},
+			[]byte(`{"bb":1,"a":2}`),
+			point,
+		}},
+	}
+
+	event := splitRowsEvent(&models.Event{Action: "INSERT"}, e)[0]
+	values := event.AfterValues
+	if !reflect.DeepEqual(event.DecodedColumns, []int{0, 1}) {
+		t.Errorf("unexpected decoded columns %v", event.DecodedColumns)
+	}
+	want := map[string]interface{}{
+		"col_0": "café",
+		"col_1": "中文",
+		"col_2": []byte{0xd6, 0xd0},
+		"col_3": models.JSONText(`{"a": 2, "bb": 1}`),
+		"col_4": models.Geometry{SRID: 4326, WKT: "POINT(116.5 40)"},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("unexpected decoded values:\n got  %#v\n want %#v", values, want)
+	}
+}
@@ -1,40 +1,45 @@
 package util
 
+import (
+	"strconv"
+	"strings"
+)
+
 // DataType 代表 MySQL 数据类型的分类
 type DataType string
 
 const (
 	// 数值类型
-	TypeTinyInt    DataType = "TINYINT"
-	TypeSmallInt   DataType = "SMALLINT"
-	TypeMediumInt  DataType = "MEDIUMINT"
-	TypeInt        DataType = "INT"
-	TypeBigInt     DataType = "BIGINT"
-	TypeDecimal    DataType = "DECIMAL"
-	TypeNumeric    DataType = "NUMERIC"
-	TypeFloat      DataType = "FLOAT"
-	TypeDouble     DataType = "DOUBLE"
-	TypeBit        DataType = "BIT"
+	TypeTinyInt   DataType = "TINYINT"
+	TypeSmallInt  DataType = "SMALLINT"
+	TypeMediumInt DataType = "MEDIUMINT"
+	TypeInt       DataType = "INT"
+	TypeBigInt    DataType = "BIGINT"
+	TypeDecimal   DataType = "DECIMAL"
+	TypeNumeric   DataType = "NUMERIC"
+	TypeFloat     DataType = "FLOAT"
+	TypeDouble    DataType = "DOUBLE"
+	TypeBit       DataType = "BIT"
 
 	// 字符串类型
-	TypeChar       DataType = "CHAR"
-	TypeVarchar    DataType = "VARCHAR"
-	TypeBinary     DataType = "BINARY"
-	TypeVarbinary  DataType = "VARBINARY"
-	TypeText       DataType = "TEXT"
-	TypeBlob       DataType = "BLOB"
-	TypeEnum       DataType = "ENUM"
-	TypeSet        DataType = "SET"
+	TypeChar      DataType = "CHAR"
+	TypeVarchar   DataType = "VARCHAR"
+	TypeBinary    DataType = "BINARY"
+	TypeVarbinary DataType = "VARBINARY"
+	TypeText      DataType = "TEXT"
+	TypeBlob      DataType = "BLOB"
+	TypeEnum      DataType = "ENUM"
+	TypeSet       DataType = "SET"
 
 	// 时间类型
-	TypeDate       DataType = "DATE"
-	TypeTime       DataType = "TIME"
-	TypeDatetime   DataType = "DATETIME"
-	TypeTimestamp  DataType = "TIMESTAMP"
-	TypeYear       DataType = "YEAR"
+	TypeDate      DataType = "DATE"
+	TypeTime      DataType = "TIME"
+	TypeDatetime  DataType = "DATETIME"
+	TypeTimestamp DataType = "TIMESTAMP"
+	TypeYear      DataType = "YEAR"
 
 	// JSON 类型
-	TypeJSON       DataType = "JSON"
+	TypeJSON DataType = "JSON"
 
 	// 几何类型
 	TypeGeometry   DataType = "GEOMETRY"
@@ -136,3 +141,121 @@ func GetDefaultValue(dt DataType, nullable bool) string {
 		return "NULL"
 	}
 }
+
+// ParseColumnType 根据 INFORMATION_SCHEMA.COLUMNS.COLUMN_TYPE（如 "bigint(20) unsigned"、"datetime(3)"）
+// 返回数据类型分类，无法识别时返回空
+func ParseColumnType(columnType string) DataType {
+	name := strings.ToLower(strings.TrimSpace(columnType))
+	if end := strings.IndexAny(name, "( "); end >= 0 {
+		name = name[:end]
+	}
+
+	switch name {
+	case "tinyint", "bool", "boolean":
+		return TypeTinyInt
+	case "smallint":
+		return TypeSmallInt
+	case "mediumint":
+		return TypeMediumInt
+	case "int", "integer":
+		return TypeInt
+	case "bigint":
+		return TypeBigInt
+	case "decimal", "dec", "fixed":
+		return TypeDecimal
+	case "numeric":
+		return TypeNumeric
+	case "float":
+		return TypeFloat
+	case "double", "real":
+		return TypeDouble
+	case "bit":
+		return TypeBit
+	case "char":
+		return TypeChar
+	case "varchar":
+		return TypeVarchar
+	case "binary":
+		return TypeBinary
+	case "varbinary":
+		return TypeVarbinary
+	case "tinytext", "text", "mediumtext", "longtext":
+		return TypeText
+	case "tinyblob", "blob", "mediumblob", "longblob":
+		return TypeBlob
+	case "enum":
+		return TypeEnum
+	case "set":
+		return TypeSet
+	case "date":
+		return TypeDate
+	case "time":
+		return TypeTime
+	case "datetime":
+		return TypeDatetime
+	case "timestamp":
+		return TypeTimestamp
+	case "year":
+		return TypeYear
+	case "json":
+		return TypeJSON
+	case "point":
+		return TypePoint
+	case "linestring":
+		return TypeLinestring
+	case "polygon":
+		return TypePolygon
+	case "geometry", "multipoint", "multilinestring", "multipolygon", "geometrycollection", "geomcollection":
+		return TypeGeometry
+	}
+	return ""
+}
+
+// ParseElements 从 enum('a','b') / set('a','b') 类型定义中解析取值列表，其他类型返回 nil
+// 取值中的单引号在 COLUMN_TYPE 中写作两个单引号
+func ParseElements(columnType string) []string {
+	if dt := ParseColumnType(columnType); dt != TypeEnum && dt != TypeSet {
+		return nil
+	}
+	start := strings.IndexByte(columnType, '(')
+	end := strings.LastIndexByte(columnType, ')')
+	if start < 0 || end <= start {
+		return nil
+	}
+
+	elements := []string{}
+	body := columnType[start+1 : end]
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\'' {
+			continue
+		}
+		var sb strings.Builder
+		for i++; i < len(body); i++ {
+			if body[i] == '\'' {
+				if i+1 < len(body) && body[i+1] == '\'' {
+					sb.WriteByte('\'')
+					i++
+					continue
+				}
+				break
+			}
+			sb.WriteByte(body[i])
+		}
+		elements = append(elements, sb.String())
+	}
+	return elements
+}
+
+// ParseFsp 返回 DATETIME/TIMESTAMP/TIME 类型定义中的小数秒位数，如 "datetime(3)" 返回 3
+func ParseFsp(columnType string) int {
+	start := strings.IndexByte(columnType, '(')
+	end := strings.IndexByte(columnType, ')')
+	if start < 0 || end <= start {
+		return 0
+	}
+	fsp, err := strconv.Atoi(strings.TrimSpace(columnType[start+1 : end]))
+	if err != nil || fsp < 0 || fsp > 6 {
+		return 0
+	}
+	return fsp
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -117,3 +118,47 @@ func TestGetDefaultValue(t *testing.T) {
 		}
 	}
 }
+
+func TestParseColumnType(t *testing.T) {
+	tests := []struct {
+		columnType string
+		expected   DataType
+	}{
+		{"bigint(20) unsigned", TypeBigInt},
+		{"int", TypeInt},
+		{"tinyint(1)", TypeTinyInt},
+		{"decimal(10,2)", TypeDecimal},
+		{"varbinary(16)", TypeVarbinary},
+		{"mediumblob", TypeBlob},
+		{"longtext", TypeText},
+		{"enum('a','b')", TypeEnum},
+		{"datetime(6)", TypeDatetime},
+		{"multipolygon", TypeGeometry},
+		{"unknown_type", ""},
+	}
+
+	for _, test := range tests {
+		if got := ParseColumnType(test.columnType); got != test.expected {
+			t.Errorf("ParseColumnType(%q): expected %q, got %q", test.columnType, test.expected, got)
+		}
+	}
+}
+
+func TestParseElementsAndFsp(t *testing.T) {
+	if got := ParseElements("enum('new','it''s paid','a,b')"); !reflect.DeepEqual(got, []string{"new", "it's paid", "a,b"}) {
+		t.Errorf("unexpected enum elements %v", got)
+	}
+	if got := ParseElements("set('x','y')"); !reflect.DeepEqual(got, []string{"x", "y"}) {
+		t.Errorf("unexpected set elements %v", got)
+	}
+	if got := ParseElements("varchar(10)"); got != nil {
+		t.Errorf("expected nil for varchar, got %v", got)
+	}
+
+	if fsp := ParseFsp("datetime(3)"); fsp != 3 {
+		t.Errorf("expected fsp 3, got %d", fsp)
+	}
+	if fsp := ParseFsp("timestamp"); fsp != 0 {
+		t.Errorf("expected fsp 0, got %d", fsp)
+	}
+}
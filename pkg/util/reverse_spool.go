@@ -0,0 +1,143 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ReverseSpool 按追加顺序的倒序输出数据块（例如按事务倒序输出回滚 SQL）
+// 数据块总大小不超过内存上限时保存在内存中，超过后全部写入临时文件，只在内存中保留每块的起始位置
+type ReverseSpool struct {
+	memLimit int
+	blocks   [][]byte // 写入临时文件之前的数据块
+	size     int      // blocks 的总字节数
+
+	file    *os.File
+	writer  *bufio.Writer
+	offsets []int64 // 写入临时文件后每个数据块的起始位置
+	end     int64   // 临时文件的当前长度
+}
+
+// NewReverseSpool 创建倒序输出缓冲，memLimit 为内存中最多保存的字节数
+func NewReverseSpool(memLimit int) *ReverseSpool {
+	return &ReverseSpool{memLimit: memLimit}
+}
+
+// Append 追加一个数据块，调用之后不能再修改 block
+func (s *ReverseSpool) Append(block []byte) error {
+	if s.file == nil {
+		s.blocks = append(s.blocks, block)
+		s.size += len(block)
+		if s.size <= s.memLimit {
+			return nil
+		}
+		return s.spill()
+	}
+	return s.write(block)
+}
+
+// Len 返回数据块的数量
+func (s *ReverseSpool) Len() int {
+	if s.file == nil {
+		return len(s.blocks)
+	}
+	return len(s.offsets)
+}
+
+// Truncate 丢弃第 n 个之后追加的数据块（n 为之前 Len 的返回值），用于撤销没有完成的一组数据块
+func (s *ReverseSpool) Truncate(n int) error {
+	if n >= s.Len() {
+		return nil
+	}
+	if s.file == nil {
+		for _, block := range s.blocks[n:] {
+			s.size -= len(block)
+		}
+		s.blocks = s.blocks[:n]
+		return nil
+	}
+
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	s.end = s.offsets[n]
+	s.offsets = s.offsets[:n]
+	if err := s.file.Truncate(s.end); err != nil {
+		return fmt.Errorf("failed to truncate spool file: %w", err)
+	}
+	if _, err := s.file.Seek(s.end, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to truncate spool file: %w", err)
+	}
+	return nil
+}
+
+// spill 将内存中的数据块写入临时文件
+func (s *ReverseSpool) spill() error {
+	file, err := os.CreateTemp("", "binlogx-spool-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	s.file = file
+	s.writer = bufio.NewWriterSize(file, 1<<20)
+	for _, block := range s.blocks {
+		if err := s.write(block); err != nil {
+			return err
+		}
+	}
+	s.blocks = nil
+	s.size = 0
+	return nil
+}
+
+// write 将数据块追加到临时文件
+func (s *ReverseSpool) write(block []byte) error {
+	if _, err := s.writer.Write(block); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	s.offsets = append(s.offsets, s.end)
+	s.end += int64(len(block))
+	return nil
+}
+
+// WriteTo 按追加顺序的倒序将所有数据块写入 w
+func (s *ReverseSpool) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	if s.file == nil {
+		for i := len(s.blocks) - 1; i >= 0; i-- {
+			n, err := w.Write(s.blocks[i])
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+		}
+		return written, nil
+	}
+
+	if err := s.writer.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write spool file: %w", err)
+	}
+	end := s.end
+	for i := len(s.offsets) - 1; i >= 0; i-- {
+		n, err := io.Copy(w, io.NewSectionReader(s.file, s.offsets[i], end-s.offsets[i]))
+		written += n
+		if err != nil {
+			return written, err
+		}
+		end = s.offsets[i]
+	}
+	return written, nil
+}
+
+// Close 删除临时文件
+func (s *ReverseSpool) Close() error {
+	s.blocks = nil
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	s.file.Close()
+	s.file = nil
+	return os.Remove(name)
+}
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestReverseSpool(t *testing.T) {
+	for _, memLimit := range []int{1 << 20, 10} {
+		spool := NewReverseSpool(memLimit)
+		var expected []byte
+		for i := 0; i < 5; i++ {
+			block := []byte(fmt.Sprintf("block %d\n", i))
+			if err := spool.Append(block); err != nil {
+				t.Fatalf("Append failed: %v", err)
+			}
+			expected = append(block, expected...)
+		}
+		if spool.Len() != 5 {
+			t.Errorf("expected 5 blocks, got %d", spool.Len())
+		}
+
+		// 超过内存上限后写入临时文件
+		spilled := spool.file != nil
+		if spilled != (memLimit == 10) {
+			t.Errorf("memLimit %d: unexpected spill state %v", memLimit, spilled)
+		}
+
+		var out bytes.Buffer
+		n, err := spool.WriteTo(&out)
+		if err != nil {
+			t.Fatalf("WriteTo failed: %v", err)
+		}
+		if !bytes.Equal(out.Bytes(), expected) || n != int64(len(expected)) {
+			t.Errorf("memLimit %d: expected %q, got %q (%d bytes)", memLimit, expected, out.String(), n)
+		}
+
+		var name string
+		if spilled {
+			name = spool.file.Name()
+		}
+		if err := spool.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if spilled {
+			if _, err := os.Stat(name); !os.IsNotExist(err) {
+				t.Errorf("expected spool file %s to be removed", name)
+			}
+		}
+	}
+}
+
+func TestReverseSpoolTruncate(t *testing.T) {
+	for _, memLimit := range []int{1 << 20, 3} {
+		spool := NewReverseSpool(memLimit)
+		appendBlocks := func(blocks ...string) {
+			for _, block := range blocks {
+				if err := spool.Append([]byte(block)); err != nil {
+					t.Fatalf("Append failed: %v", err)
+				}
+			}
+		}
+
+		appendBlocks("a\n", "b\n")
+		mark := spool.Len()
+		appendBlocks("c\n", "d\n")
+		if err := spool.Truncate(mark); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+		// 截断后继续追加的数据块紧接在保留的数据块之后
+		appendBlocks("e\n")
+
+		if spilled := spool.file != nil; spilled != (memLimit == 3) {
+			t.Errorf("memLimit %d: unexpected spill state %v", memLimit, spilled)
+		}
+
+		var out bytes.Buffer
+		if _, err := spool.WriteTo(&out); err != nil {
+			t.Fatalf("WriteTo failed: %v", err)
+		}
+		if want := "e\nb\na\n"; out.String() != want {
+			t.Errorf("memLimit %d: expected %q, got %q", memLimit, want, out.String())
+		}
+		spool.Close()
+	}
+}
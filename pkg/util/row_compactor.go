@@ -0,0 +1,497 @@
+package util
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func init() {
+	// 行数据中可能出现的非基本类型，写入临时文件时需要注册
+	gob.Register(time.Time{})
+	gob.Register(decimal.Decimal{})
+	gob.Register(models.JSONText(""))
+	gob.Register(models.Geometry{})
+	gob.Register(models.JSONDiff{})
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// compactRow 一行在读取范围内的净变化
+type compactRow struct {
+	Key     string // 库、表和主键值，为空表示不能与其他修改合并
+	Seq     uint64 // 最后一次修改的序号
+	Changes int    // 合并的行事件数
+	Sealed  bool   // 不能与前后的修改合并（前后镜像不完整、JSON 部分更新），Event 为原始事件
+	Existed bool   // 范围开始时行是否存在（第一次修改不是 INSERT）
+	Exists  bool   // 最后一次修改后行是否存在
+	// Event 最后一次修改的事件信息，BeforeValues 为范围开始时的行，AfterValues 为最后的行
+	Event models.Event
+
+	size int // 估计的内存占用
+}
+
+// RowCompactor 按表和主键合并同一行的多次修改，只保留读取范围内的净变化：
+// INSERT 加 UPDATE 合并为一个 INSERT，连续的 UPDATE 合并为一个 UPDATE，INSERT 后 DELETE 相互抵消，DELETE 后 INSERT 合并为 UPDATE
+// 修改主键的 UPDATE 拆分为旧主键的 DELETE 和新主键的 INSERT。没有主键、镜像不完整的事件原样保留，
+// 同一行在这类事件前后的修改分别合并。净变化按每行最后一次修改的顺序输出
+// 行数据的估计大小超过内存上限后按键排序写入临时文件，输出时流式归并这些文件
+type RowCompactor struct {
+	memLimit int
+	keyOf    func(event *models.Event) []string
+	seq      uint64
+	rows     map[string]*compactRow // 可以继续合并的行
+	done     []*compactRow          // 不再合并的行
+	size     int                    // rows 和 done 的估计字节数
+
+	dir   string     // 溢出后创建的临时目录
+	runs  []*os.File // 溢出时写入的临时文件，每个文件中的行按键排序（Each 的第二遍中按序号排序）
+	files int        // 已经创建的临时文件数，用于文件命名
+}
+
+// NewRowCompactor 创建净变化合并器，memLimit 为内存中最多保存的行数据字节数（估计值），
+// keyOf 返回事件所在表的主键列，没有主键时返回 nil
+func NewRowCompactor(memLimit int, keyOf func(event *models.Event) []string) *RowCompactor {
+	return &RowCompactor{
+		memLimit: memLimit,
+		keyOf:    keyOf,
+		rows:     make(map[string]*compactRow),
+	}
+}
+
+// Add 按 binlog 顺序加入一个行事件（INSERT/UPDATE/DELETE）
+func (c *RowCompactor) Add(event *models.Event) error {
+	primaryKey := c.keyOf(event)
+	switch {
+	case !compactable(event):
+		// 仍然按主键记录，同一行在这个事件前后的修改不会合并到一起
+		values := event.BeforeValues
+		if event.Action == "INSERT" {
+			values = event.AfterValues
+		}
+		c.add(c.sealedRow(event, rowKey(event, values, primaryKey)))
+	case event.Action == "UPDATE":
+		before := rowKey(event, event.BeforeValues, primaryKey)
+		after := rowKey(event, event.AfterValues, primaryKey)
+		if before == "" || after == "" {
+			c.add(c.sealedRow(event, ""))
+		} else if before != after {
+			// 修改了主键：旧主键的行被删除，新主键的行被插入
+			c.add(c.newRow(before, event, true, false))
+			c.add(c.newRow(after, event, false, true))
+		} else {
+			c.add(c.newRow(before, event, true, true))
+		}
+	case event.Action == "INSERT":
+		if key := rowKey(event, event.AfterValues, primaryKey); key != "" {
+			c.add(c.newRow(key, event, false, true))
+		} else {
+			c.add(c.sealedRow(event, ""))
+		}
+	case event.Action == "DELETE":
+		if key := rowKey(event, event.BeforeValues, primaryKey); key != "" {
+			c.add(c.newRow(key, event, true, false))
+		} else {
+			c.add(c.sealedRow(event, ""))
+		}
+	default:
+		return nil
+	}
+
+	if c.size > c.memLimit {
+		return c.spill()
+	}
+	return nil
+}
+
+// compactable 判断事件的镜像是否完整，可以与同一行的其他修改合并
+func compactable(event *models.Event) bool {
+	if len(event.BeforeMissing) > 0 || len(event.AfterMissing) > 0 {
+		return false
+	}
+	for _, v := range event.AfterValues {
+		if _, ok := v.(models.JSONDiff); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// rowKey 由库名、表名和主键值生成行的键，没有主键或主键列不在镜像中时返回空字符串
+func rowKey(event *models.Event, values map[string]interface{}, primaryKey []string) string {
+	if len(primaryKey) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%q.%q", event.Database, event.Table)
+	for _, column := range primaryKey {
+		v, ok := values[column]
+		if !ok || v == nil {
+			return ""
+		}
+		switch val := v.(type) {
+		case string, []byte:
+			fmt.Fprintf(&b, ",%q", val)
+		default:
+			fmt.Fprintf(&b, ",%v", val)
+		}
+	}
+	return b.String()
+}
+
+// newRow 由一次修改创建净变化，existed/exists 为修改前后行是否存在
+func (c *RowCompactor) newRow(key string, event *models.Event, existed, exists bool) *compactRow {
+	c.seq++
+	row := &compactRow{Key: key, Seq: c.seq, Changes: 1, Existed: existed, Exists: exists, Event: eventInfo(event)}
+	if existed {
+		row.Event.BeforeValues = event.BeforeValues
+	}
+	if exists {
+		row.Event.AfterValues = event.AfterValues
+	}
+	row.size = row.estimateSize()
+	return row
+}
+
+// sealedRow 创建不能与其他修改合并的行，保留原始事件
+func (c *RowCompactor) sealedRow(event *models.Event, key string) *compactRow {
+	c.seq++
+	row := &compactRow{Key: key, Seq: c.seq, Changes: 1, Sealed: true, Event: eventInfo(event)}
+	row.Event.Action = event.Action
+	row.Event.BeforeValues = event.BeforeValues
+	row.Event.AfterValues = event.AfterValues
+	row.Event.BeforeMissing = event.BeforeMissing
+	row.Event.AfterMissing = event.AfterMissing
+	row.size = row.estimateSize()
+	return row
+}
+
+// eventInfo 复制生成 SQL 需要的事件信息，不包含行数据和原始字节
+func eventInfo(event *models.Event) models.Event {
+	return models.Event{
+		Timestamp:  event.Timestamp,
+		EventType:  event.EventType,
+		ServerID:   event.ServerID,
+		LogName:    event.LogName,
+		LogPos:     event.LogPos,
+		GTID:       event.GTID,
+		Database:   event.Database,
+		Table:      event.Table,
+		PrimaryKey: event.PrimaryKey,
+		Columns:    event.Columns,
+	}
+}
+
+// add 将新的修改合并到同一行的净变化中
+func (c *RowCompactor) add(row *compactRow) {
+	c.size += row.size
+	if row.Key == "" {
+		c.done = append(c.done, row)
+		return
+	}
+	if cur, ok := c.rows[row.Key]; ok {
+		if merged := mergeRows(cur, row); merged != nil {
+			c.size += merged.size - cur.size - row.size
+			c.rows[row.Key] = merged
+			return
+		}
+		c.done = append(c.done, cur)
+	}
+	c.rows[row.Key] = row
+}
+
+// mergeRows 合并同一行先后两段的净变化，任何一段不能合并时返回 nil
+func mergeRows(first, next *compactRow) *compactRow {
+	if first.Sealed || next.Sealed {
+		return nil
+	}
+	next.Existed = first.Existed
+	next.Event.BeforeValues = first.Event.BeforeValues
+	next.Changes += first.Changes
+	next.size = next.estimateSize()
+	return next
+}
+
+// estimateSize 估计行的内存占用
+func (r *compactRow) estimateSize() int {
+	return 256 + len(r.Key) + valuesSize(r.Event.BeforeValues) + valuesSize(r.Event.AfterValues)
+}
+
+// valuesSize 估计行镜像的内存占用
+func valuesSize(values map[string]interface{}) int {
+	size := 0
+	for k, v := range values {
+		size += len(k) + 32
+		switch val := v.(type) {
+		case string:
+			size += len(val)
+		case []byte:
+			size += len(val)
+		case models.JSONText:
+			size += len(val)
+		case models.Geometry:
+			size += len(val.WKT)
+		}
+	}
+	return size
+}
+
+// netEvent 返回行的净变化，没有净变化（INSERT 后又被 DELETE）时返回 nil
+func (r *compactRow) netEvent() *models.Event {
+	event := r.Event
+	if r.Sealed {
+		return &event
+	}
+	switch {
+	case !r.Existed && !r.Exists:
+		return nil
+	case !r.Existed:
+		event.Action = "INSERT"
+	case !r.Exists:
+		event.Action = "DELETE"
+	default:
+		event.Action = "UPDATE"
+	}
+	return &event
+}
+
+// spill 将内存中的行按键排序后写入一个新的临时文件，同一行的各段按修改顺序排列
+func (c *RowCompactor) spill() error {
+	rows := c.takeRows()
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	file, err := c.writeRun(rows)
+	if err != nil {
+		return err
+	}
+	c.runs = append(c.runs, file)
+	return nil
+}
+
+// writeRun 将排好序的行写入新的临时文件
+func (c *RowCompactor) writeRun(rows []*compactRow) (*os.File, error) {
+	if c.dir == "" {
+		dir, err := os.MkdirTemp("", "binlogx-compact-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create compact directory: %w", err)
+		}
+		c.dir = dir
+	}
+	c.files++
+	file, err := os.Create(filepath.Join(c.dir, fmt.Sprintf("run-%04d", c.files)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compact file: %w", err)
+	}
+	writer := bufio.NewWriterSize(file, 1<<20)
+	encoder := gob.NewEncoder(writer)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write compact file: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write compact file: %w", err)
+	}
+	return file, nil
+}
+
+// takeRows 取出内存中的所有行，按最后一次修改的顺序排列
+func (c *RowCompactor) takeRows() []*compactRow {
+	rows := c.done
+	for _, row := range c.rows {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Seq < rows[j].Seq })
+	c.rows = make(map[string]*compactRow)
+	c.done = nil
+	c.size = 0
+	return rows
+}
+
+// Each 按每行最后一次修改的顺序遍历净变化，changes 为合并的行事件数
+// 返回的事件 Action 为净变化的类型：INSERT 只有 AfterValues，DELETE 只有 BeforeValues，UPDATE 为范围开始和结束时的行
+// 溢出后分两遍归并临时文件，内存中只保存每个文件的当前行和不超过上限的行：
+// 第一遍按键归并，合并同一行在不同文件中的各段；第二遍将合并结果按序号排序后归并输出
+func (c *RowCompactor) Each(fn func(event *models.Event, changes int) error) error {
+	emit := func(row *compactRow) error {
+		if event := row.netEvent(); event != nil {
+			return fn(event, row.Changes)
+		}
+		return nil
+	}
+	if c.dir == "" {
+		for _, row := range c.takeRows() {
+			if err := emit(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := c.spill(); err != nil {
+		return err
+	}
+	keyRuns := c.runs
+	c.runs = nil
+
+	// 第一遍：同一行的各段按修改顺序相邻出现，与 add 相同地依次合并，结果按序号排序后写入新的文件
+	var cur *compactRow
+	err := mergeRuns(keyRuns, keyOrder, func(row *compactRow) error {
+		if cur != nil && row.Key != "" && row.Key == cur.Key {
+			if merged := mergeRows(cur, row); merged != nil {
+				cur = merged
+				return nil
+			}
+		}
+		if cur != nil {
+			if err := c.collect(cur); err != nil {
+				return err
+			}
+		}
+		cur = row
+		return nil
+	})
+	if err == nil && cur != nil {
+		err = c.collect(cur)
+	}
+	removeRuns(keyRuns)
+	if err != nil {
+		return err
+	}
+
+	// 第二遍：合并结果都在内存中时直接输出，否则按序号归并
+	if len(c.runs) == 0 {
+		for _, row := range c.takeRows() {
+			if err := emit(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	file, err := c.writeRun(c.takeRows())
+	if err != nil {
+		return err
+	}
+	c.runs = append(c.runs, file)
+	return mergeRuns(c.runs, seqOrder, emit)
+}
+
+// collect 保存第一遍合并后的行，超过内存上限时按序号排序写入临时文件
+func (c *RowCompactor) collect(row *compactRow) error {
+	c.done = append(c.done, row)
+	c.size += row.estimateSize()
+	if c.size <= c.memLimit {
+		return nil
+	}
+	file, err := c.writeRun(c.takeRows())
+	if err != nil {
+		return err
+	}
+	c.runs = append(c.runs, file)
+	return nil
+}
+
+// keyOrder 第一遍归并的顺序：按键排列，同一行的各段按修改顺序排列
+func keyOrder(a, b *compactRow) bool {
+	if a.Key != b.Key {
+		return a.Key < b.Key
+	}
+	return a.Seq < b.Seq
+}
+
+// seqOrder 第二遍归并的顺序：按最后一次修改的顺序排列
+func seqOrder(a, b *compactRow) bool {
+	return a.Seq < b.Seq
+}
+
+// mergeRuns 多路归并已按 less 排好序的临时文件，按顺序对每一行调用 fn
+func mergeRuns(runs []*os.File, less func(a, b *compactRow) bool, fn func(row *compactRow) error) error {
+	h := &compactHeap{less: less}
+	for _, file := range runs {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to read compact file: %w", err)
+		}
+		if err := h.pushNext(gob.NewDecoder(bufio.NewReader(file))); err != nil {
+			return err
+		}
+	}
+	for h.Len() > 0 {
+		item := heap.Pop(h).(compactHeapItem)
+		if err := fn(item.row); err != nil {
+			return err
+		}
+		if err := h.pushNext(item.run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeRuns 关闭并删除已经归并完的临时文件
+func removeRuns(runs []*os.File) {
+	for _, file := range runs {
+		file.Close()
+		os.Remove(file.Name())
+	}
+}
+
+// compactHeapItem 多路归并中一个文件的当前行
+type compactHeapItem struct {
+	row *compactRow
+	run *gob.Decoder
+}
+
+// compactHeap 按 less 排列的最小堆
+type compactHeap struct {
+	items []compactHeapItem
+	less  func(a, b *compactRow) bool
+}
+
+func (h *compactHeap) Len() int           { return len(h.items) }
+func (h *compactHeap) Less(i, j int) bool { return h.less(h.items[i].row, h.items[j].row) }
+func (h *compactHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *compactHeap) Push(x any)         { h.items = append(h.items, x.(compactHeapItem)) }
+func (h *compactHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// pushNext 读取文件的下一行放入堆中，文件读完时不做任何事
+func (h *compactHeap) pushNext(run *gob.Decoder) error {
+	row := &compactRow{}
+	if err := run.Decode(row); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("failed to read compact file: %w", err)
+	}
+	heap.Push(h, compactHeapItem{row: row, run: run})
+	return nil
+}
+
+// Close 删除临时文件
+func (c *RowCompactor) Close() error {
+	c.rows = nil
+	c.done = nil
+	if c.dir == "" {
+		return nil
+	}
+	removeRuns(c.runs)
+	c.runs = nil
+	dir := c.dir
+	c.dir = ""
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,194 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+// compactEvent 构造 test.t (id, v) 的行事件，before/after 为 nil 表示没有该镜像
+func compactEvent(action string, before, after map[string]interface{}) *models.Event {
+	return &models.Event{
+		Database:     "test",
+		Table:        "t",
+		Action:       action,
+		BeforeValues: before,
+		AfterValues:  after,
+		Columns:      []string{"id", "v"},
+	}
+}
+
+func compactValues(id int64, v interface{}) map[string]interface{} {
+	return map[string]interface{}{"id": id, "v": v}
+}
+
+// compactResult 净变化的简化形式
+type compactResult struct {
+	action  string
+	before  map[string]interface{}
+	after   map[string]interface{}
+	changes int
+}
+
+func runCompactor(t *testing.T, memLimit int, events []*models.Event) ([]compactResult, bool) {
+	t.Helper()
+	c := NewRowCompactor(memLimit, func(event *models.Event) []string {
+		if event.Table == "nokey" {
+			return nil
+		}
+		return []string{"id"}
+	})
+	defer c.Close()
+	for _, event := range events {
+		if err := c.Add(event); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	spilled := c.dir != ""
+
+	var results []compactResult
+	err := c.Each(func(event *models.Event, changes int) error {
+		results = append(results, compactResult{event.Action, event.BeforeValues, event.AfterValues, changes})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Each failed: %v", err)
+	}
+	return results, spilled
+}
+
+func TestRowCompactor(t *testing.T) {
+	nokey := compactEvent("INSERT", nil, compactValues(9, "x"))
+	nokey.Table = "nokey"
+	events := []*models.Event{
+		// id=1：INSERT、多次 UPDATE 后 DELETE，相互抵消
+		compactEvent("INSERT", nil, compactValues(1, "a")),
+		compactEvent("UPDATE", compactValues(1, "a"), compactValues(1, "b")),
+		// id=2：INSERT 加 UPDATE 合并为 INSERT
+		compactEvent("INSERT", nil, compactValues(2, "a")),
+		compactEvent("UPDATE", compactValues(1, "b"), compactValues(1, "c")),
+		compactEvent("UPDATE", compactValues(2, "a"), compactValues(2, nil)),
+		compactEvent("DELETE", compactValues(1, "c"), nil),
+		// id=3：连续的 UPDATE 合并为一个 UPDATE
+		compactEvent("UPDATE", compactValues(3, "a"), compactValues(3, "b")),
+		compactEvent("UPDATE", compactValues(3, "b"), compactValues(3, "c")),
+		// id=4：DELETE 后 INSERT 合并为 UPDATE
+		compactEvent("DELETE", compactValues(4, "a"), nil),
+		compactEvent("INSERT", nil, compactValues(4, "b")),
+		// 没有主键的表原样保留
+		nokey,
+		// 修改主键：id=5 删除，id=6 插入
+		compactEvent("UPDATE", compactValues(5, "a"), compactValues(6, "a")),
+	}
+	expected := []compactResult{
+		{"INSERT", nil, compactValues(2, nil), 2},
+		{"UPDATE", compactValues(3, "a"), compactValues(3, "c"), 2},
+		{"UPDATE", compactValues(4, "a"), compactValues(4, "b"), 2},
+		{"INSERT", nil, compactValues(9, "x"), 1},
+		{"DELETE", compactValues(5, "a"), nil, 1},
+		{"INSERT", nil, compactValues(6, "a"), 1},
+	}
+
+	for _, memLimit := range []int{1 << 20, 1} {
+		results, spilled := runCompactor(t, memLimit, events)
+		if spilled != (memLimit == 1) {
+			t.Errorf("memLimit %d: unexpected spill state %v", memLimit, spilled)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("memLimit %d:\nexpected %v\ngot      %v", memLimit, expected, results)
+		}
+	}
+}
+
+func TestRowCompactorIncompleteImage(t *testing.T) {
+	// binlog_row_image=MINIMAL 的 UPDATE 不能合并，同一行在它前后的修改分别合并
+	minimal := compactEvent("UPDATE", map[string]interface{}{"id": int64(1)}, map[string]interface{}{"v": "c"})
+	minimal.BeforeMissing = []int{1}
+	minimal.AfterMissing = []int{0}
+	events := []*models.Event{
+		compactEvent("UPDATE", compactValues(1, "a"), compactValues(1, "b")),
+		compactEvent("UPDATE", compactValues(1, "b"), compactValues(1, "c")),
+		minimal,
+		compactEvent("UPDATE", compactValues(1, "c"), compactValues(1, "d")),
+		compactEvent("UPDATE", compactValues(1, "d"), compactValues(1, "e")),
+	}
+	expected := []compactResult{
+		{"UPDATE", compactValues(1, "a"), compactValues(1, "c"), 2},
+		{"UPDATE", minimal.BeforeValues, minimal.AfterValues, 1},
+		{"UPDATE", compactValues(1, "c"), compactValues(1, "e"), 2},
+	}
+
+	for _, memLimit := range []int{1 << 20, 1} {
+		results, _ := runCompactor(t, memLimit, events)
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("memLimit %d:\nexpected %v\ngot      %v", memLimit, expected, results)
+		}
+	}
+}
+
+func TestRowCompactorSpillOrder(t *testing.T) {
+	// 大量行分布在多个临时文件中，溢出后仍按最后一次修改的顺序输出
+	var events []*models.Event
+	for i := int64(0); i < 200; i++ {
+		events = append(events, compactEvent("INSERT", nil, compactValues(i, fmt.Sprint(i))))
+	}
+	for i := int64(199); i >= 0; i -= 2 {
+		events = append(events, compactEvent("UPDATE", compactValues(i, fmt.Sprint(i)), compactValues(i, "u")))
+	}
+
+	inMemory, _ := runCompactor(t, 1<<30, events)
+	spilled, ok := runCompactor(t, 4096, events)
+	if !ok {
+		t.Fatalf("expected compactor to spill")
+	}
+	if len(inMemory) != 200 || !reflect.DeepEqual(inMemory, spilled) {
+		t.Errorf("spilled results differ from in-memory results (%d vs %d rows)", len(spilled), len(inMemory))
+	}
+	if inMemory[99].after["id"] != int64(198) || inMemory[100].after["id"] != int64(199) {
+		t.Errorf("unexpected order around updated rows: %v, %v", inMemory[99], inMemory[100])
+	}
+}
+
+func TestRowCompactorMergesAcrossRuns(t *testing.T) {
+	// 每一行在每次溢出之间都被修改，各段分布在所有临时文件中，归并时按键合并为一个 UPDATE
+	var events []*models.Event
+	for round := 0; round < 20; round++ {
+		for i := int64(0); i < 50; i++ {
+			events = append(events, compactEvent("UPDATE", compactValues(i, fmt.Sprint(round)), compactValues(i, fmt.Sprint(round+1))))
+		}
+	}
+
+	inMemory, _ := runCompactor(t, 1<<30, events)
+	spilled, ok := runCompactor(t, 4096, events)
+	if !ok {
+		t.Fatalf("expected compactor to spill")
+	}
+	if len(spilled) != 50 || !reflect.DeepEqual(inMemory, spilled) {
+		t.Fatalf("spilled results differ from in-memory results (%d vs %d rows)", len(spilled), len(inMemory))
+	}
+	for _, result := range spilled {
+		if result.changes != 20 || result.before["v"] != "0" || result.after["v"] != "20" {
+			t.Errorf("unexpected net change %v", result)
+		}
+	}
+}
+
+func TestRowCompactorClose(t *testing.T) {
+	c := NewRowCompactor(1, func(event *models.Event) []string { return []string{"id"} })
+	if err := c.Add(compactEvent("INSERT", nil, compactValues(1, []byte{0, 1}))); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	dir := c.dir
+	if dir == "" {
+		t.Fatalf("expected compactor to spill")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("temp directory %s was not removed", dir)
+	}
+}
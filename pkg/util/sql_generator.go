@@ -5,19 +5,33 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aitoooooo/binlogx/pkg/models"
 	"github.com/aitoooooo/binlogx/pkg/monitor"
+	"github.com/shopspring/decimal"
 )
 
 // SQLGenerator SQL 生成器
 type SQLGenerator struct {
+	mu sync.RWMutex
 	// columnTypes 用于存储列类型信息（可选）
 	// 键为 "schema.table.columnName"
 	columnTypes map[string]DataType
-	monitor     *monitor.Monitor // 用于性能监控
+	// columnMeta 列的完整元数据（无符号、ENUM/SET 取值、小数秒位数等），键同 columnTypes
+	columnMeta map[string]models.ColumnMeta
+	// tableKeys 表的主键和唯一索引，键为 "schema.table"，第一个元素为主键（可能为空）
+	tableKeys map[string][][]string
+	// metaLoader 按需加载表元数据，loadedTables 记录已经加载过的表（schema.table）
+	metaLoader   func(schema, table string) (*models.TableMeta, error)
+	loadedTables map[string]bool
+	// changedColumnsOnly UPDATE 的 SET 只包含前后镜像中值不同的列
+	changedColumnsOnly bool
+	monitor            *monitor.Monitor // 用于性能监控
 }
 
 // GenerateInsertSQL 生成 INSERT SQL
@@ -33,27 +47,35 @@ func (sg *SQLGenerator) GenerateInsertSQL(event *models.Event) string {
 		return ""
 	}
 
+	head, row := sg.insertParts(event)
+	if head == "" {
+		return ""
+	}
+	return head + " VALUES " + row
+}
+
+// insertParts 将 INSERT 拆分为语句头（库表和列列表）和一行 VALUES，没有列时返回空字符串
+func (sg *SQLGenerator) insertParts(event *models.Event) (head, row string) {
 	// 构建列列表和值列表
 	columns := make([]string, 0)
 	values := make([]string, 0)
 
-	for k, v := range event.AfterValues {
+	for _, k := range event.OrderedColumns(event.AfterValues) {
 		columns = append(columns, fmt.Sprintf("`%s`", escapeBacktick(k)))
-		values = append(values, sg.formatValue(v))
+		values = append(values, sg.formatColumnValue(event.Database, event.Table, k, event.AfterValues[k]))
 	}
 
 	if len(columns) == 0 {
-		return ""
+		return "", ""
 	}
 
-	sql := fmt.Sprintf(
-		"INSERT INTO `%s`.`%s` (%s) VALUES (%s)",
+	head = fmt.Sprintf(
+		"INSERT INTO `%s`.`%s` (%s)",
 		escapeBacktick(event.Database),
 		escapeBacktick(event.Table),
 		strings.Join(columns, ", "),
-		strings.Join(values, ", "),
 	)
-	return sql
+	return head, "(" + strings.Join(values, ", ") + ")"
 }
 
 // GenerateUpdateSQL 生成 UPDATE SQL
@@ -69,10 +91,17 @@ func (sg *SQLGenerator) GenerateUpdateSQL(event *models.Event) string {
 		return ""
 	}
 
-	// 构建 SET 子句
+	// 构建 SET 子句，changedColumnsOnly 时跳过值没有变化的列，所有列都没有变化时不生成 SQL
 	setParts := make([]string, 0)
-	for k, v := range event.AfterValues {
-		setParts = append(setParts, fmt.Sprintf("`%s`=%s", escapeBacktick(k), sg.formatValue(v)))
+	for _, k := range event.OrderedColumns(event.AfterValues) {
+		if sg.changedColumnsOnly && !columnChanged(event, k) {
+			continue
+		}
+		if diff, ok := event.AfterValues[k].(models.JSONDiff); ok {
+			setParts = append(setParts, fmt.Sprintf("`%s`=%s", escapeBacktick(k), formatJSONDiff(k, diff)))
+			continue
+		}
+		setParts = append(setParts, fmt.Sprintf("`%s`=%s", escapeBacktick(k), sg.formatColumnValue(event.Database, event.Table, k, event.AfterValues[k])))
 	}
 
 	if len(setParts) == 0 {
@@ -80,17 +109,17 @@ func (sg *SQLGenerator) GenerateUpdateSQL(event *models.Event) string {
 	}
 
 	// WHERE 子句
-	whereParts := make([]string, 0)
-	for k, v := range event.BeforeValues {
-		whereParts = append(whereParts, fmt.Sprintf("`%s`=%s", escapeBacktick(k), sg.formatValue(v)))
+	where := sg.whereClause(event, event.BeforeValues)
+	if where == "" {
+		return ""
 	}
 
 	sql := fmt.Sprintf(
-		"UPDATE `%s`.`%s` SET %s WHERE %s",
+		"UPDATE `%s`.`%s` SET %s WHERE %s LIMIT 1",
 		escapeBacktick(event.Database),
 		escapeBacktick(event.Table),
 		strings.Join(setParts, ", "),
-		strings.Join(whereParts, " AND "),
+		where,
 	)
 	return sql
 }
@@ -109,26 +138,200 @@ func (sg *SQLGenerator) GenerateDeleteSQL(event *models.Event) string {
 	}
 
 	// WHERE 子句
-	whereParts := make([]string, 0)
-	for k, v := range event.BeforeValues {
-		whereParts = append(whereParts, fmt.Sprintf("`%s`=%s", escapeBacktick(k), sg.formatValue(v)))
-	}
-
-	if len(whereParts) == 0 {
+	where := sg.whereClause(event, event.BeforeValues)
+	if where == "" {
 		return ""
 	}
 
 	sql := fmt.Sprintf(
-		"DELETE FROM `%s`.`%s` WHERE %s",
+		"DELETE FROM `%s`.`%s` WHERE %s LIMIT 1",
 		escapeBacktick(event.Database),
 		escapeBacktick(event.Table),
-		strings.Join(whereParts, " AND "),
+		where,
 	)
 	return sql
 }
 
+// SetChangedColumnsOnly 设置 UPDATE 的 SET 是否只包含值发生变化的列
+// binlog_row_image=FULL 时前后镜像包含所有列，只写入变化的列可以缩短 SQL，也减少覆盖并发修改的可能
+func (sg *SQLGenerator) SetChangedColumnsOnly(enabled bool) {
+	sg.changedColumnsOnly = enabled
+}
+
+// IsNoopUpdate 判断 UPDATE 事件是否没有修改任何列（前后镜像的值完全相同）
+func (sg *SQLGenerator) IsNoopUpdate(event *models.Event) bool {
+	if event.Action != "UPDATE" || len(event.BeforeValues) == 0 || len(event.AfterValues) == 0 {
+		return false
+	}
+	for k := range event.AfterValues {
+		if columnChanged(event, k) {
+			return false
+		}
+	}
+	return true
+}
+
+// columnChanged 判断列的值在前后镜像中是否不同，前镜像中没有该列时视为变化
+func columnChanged(event *models.Event, column string) bool {
+	before, ok := event.BeforeValues[column]
+	if !ok {
+		return true
+	}
+	return !reflect.DeepEqual(before, event.AfterValues[column])
+}
+
+// whereClause 生成定位一行的 WHERE 条件（调用方追加 LIMIT 1）
+// 有主键或唯一索引（索引列都在前镜像中且不为 NULL）时只比较索引列；
+// 否则比较整行：NULL 使用 IS NULL，FLOAT/DOUBLE 使用误差范围（见 floatCondition），其他值使用 NULL 安全的 <=>。
+// 整行都相同的多行可以互相替代，LIMIT 1 是安全的
+func (sg *SQLGenerator) whereClause(event *models.Event, values map[string]interface{}) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	if key := sg.rowKey(event, values); key != nil {
+		parts := make([]string, 0, len(key))
+		for _, column := range key {
+			parts = append(parts, fmt.Sprintf("`%s`=%s", escapeBacktick(column), sg.formatColumnValue(event.Database, event.Table, column, values[column])))
+		}
+		return strings.Join(parts, " AND ")
+	}
+
+	var parts []string
+	for _, k := range event.OrderedColumns(values) {
+		v := values[k]
+		if _, ok := v.(models.JSONDiff); ok {
+			// JSON 部分更新只有修改内容，没有完整的值可以比较
+			continue
+		}
+		if v == nil {
+			parts = append(parts, fmt.Sprintf("`%s` IS NULL", escapeBacktick(k)))
+			continue
+		}
+		if sg.isFloatColumn(event.Database, event.Table, k, v) {
+			if part, ok := floatCondition(k, v); ok {
+				parts = append(parts, part)
+				continue
+			}
+		}
+		parts = append(parts, fmt.Sprintf("`%s`<=>%s", escapeBacktick(k), sg.formatColumnValue(event.Database, event.Table, k, v)))
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// floatCondition 生成 FLOAT/DOUBLE 列的比较条件
+// binlog 中的值就是列的存储值，但十进制文本在服务端转换回浮点数时可能有舍入（FLOAT 按 DOUBLE 比较时尤其明显），
+// 因此按完整精度输出值，并允许半个 ULP（按值自身的精度计算）的误差：只有存储值完全相同的行满足条件
+func floatCondition(column string, v interface{}) (string, bool) {
+	var value, ulp float64
+	switch f := v.(type) {
+	case float32:
+		value = float64(f)
+		ulp = float64(math.Nextafter32(f, float32(math.Inf(1))) - f)
+	case float64:
+		value = f
+		ulp = math.Nextafter(f, math.Inf(1)) - f
+	default:
+		return "", false
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) || math.IsInf(ulp, 0) {
+		return "", false
+	}
+	return fmt.Sprintf("ABS(`%s`-%s)<=%s", escapeBacktick(column),
+		strconv.FormatFloat(value, 'g', -1, 64), strconv.FormatFloat(ulp/2, 'g', -1, 64)), true
+}
+
+// rowKey 返回可以定位行的索引列：优先使用事件或表元数据中的主键，其次是唯一索引
+// 索引列必须都在前镜像中（binlog_row_image=MINIMAL 时可能缺失），唯一索引的列还不能为 NULL（唯一索引允许多个 NULL）
+func (sg *SQLGenerator) rowKey(event *models.Event, values map[string]interface{}) []string {
+	primaryKey, uniqueKeys := sg.lookupKeys(event.Database, event.Table)
+	if event.PrimaryKey != nil {
+		primaryKey = event.PrimaryKey
+	}
+
+	candidates := append([][]string{primaryKey}, uniqueKeys...)
+	for _, key := range candidates {
+		if len(key) == 0 {
+			continue
+		}
+		usable := true
+		for _, column := range key {
+			if v, ok := values[column]; !ok || v == nil {
+				usable = false
+				break
+			}
+		}
+		if usable {
+			return key
+		}
+	}
+	return nil
+}
+
+// PrimaryKey 返回事件所在表的主键列：优先使用事件中的主键，其次是表元数据，都没有时返回 nil
+func (sg *SQLGenerator) PrimaryKey(event *models.Event) []string {
+	if len(event.PrimaryKey) > 0 {
+		return event.PrimaryKey
+	}
+	primaryKey, _ := sg.lookupKeys(event.Database, event.Table)
+	return primaryKey
+}
+
+// isFloatColumn 判断列是否为 FLOAT/DOUBLE，列类型未知时按值的 Go 类型判断
+func (sg *SQLGenerator) isFloatColumn(schemaName, tableName, columnName string, v interface{}) bool {
+	dataType, _ := sg.lookupColumn(schemaName, tableName, columnName)
+	switch dataType {
+	case TypeFloat, TypeDouble:
+		return true
+	case "":
+		switch v.(type) {
+		case float32, float64:
+			return true
+		}
+	}
+	return false
+}
+
+// formatJSONDiff 将 JSON 部分更新转换为基于原值的表达式，例如 JSON_REPLACE(`doc`, '$.a', CAST('1' AS JSON))
+func formatJSONDiff(column string, diff models.JSONDiff) string {
+	path := fmt.Sprintf("'%s'", escapeSingleQuote(diff.Path))
+	value := fmt.Sprintf("CAST('%s' AS JSON)", escapeSingleQuote(diff.Value))
+	switch diff.Op {
+	case "INSERT":
+		return fmt.Sprintf("JSON_INSERT(`%s`, %s, %s)", escapeBacktick(column), path, value)
+	case "REMOVE":
+		return fmt.Sprintf("JSON_REMOVE(`%s`, %s)", escapeBacktick(column), path)
+	}
+	return fmt.Sprintf("JSON_REPLACE(`%s`, %s, %s)", escapeBacktick(column), path, value)
+}
+
+// MissingRollbackColumns 返回生成回滚 SQL 需要、但前镜像中没有的列（binlog_row_image=MINIMAL/NOBLOB）
+// DELETE 的回滚需要完整的前镜像；UPDATE 的回滚需要被修改的列在前镜像中的值
+func (sg *SQLGenerator) MissingRollbackColumns(event *models.Event) []string {
+	switch event.Action {
+	case "DELETE":
+		if len(event.BeforeMissing) > 0 {
+			return event.MissingColumns(event.BeforeMissing)
+		}
+	case "UPDATE":
+		var missing []string
+		for _, k := range event.OrderedColumns(event.AfterValues) {
+			if _, ok := event.BeforeValues[k]; !ok {
+				missing = append(missing, k)
+			}
+		}
+		return missing
+	}
+	return nil
+}
+
 // GenerateRollbackSQL 生成回滚 SQL
+// 前镜像不完整（见 MissingRollbackColumns）时无法还原数据，不生成 SQL
 func (sg *SQLGenerator) GenerateRollbackSQL(event *models.Event) string {
+	if len(sg.MissingRollbackColumns(event)) > 0 {
+		return ""
+	}
+
 	switch event.Action {
 	case "INSERT":
 		// INSERT 的回滚是 DELETE
@@ -137,6 +340,8 @@ func (sg *SQLGenerator) GenerateRollbackSQL(event *models.Event) string {
 			Table:        event.Table,
 			Action:       "DELETE",
 			BeforeValues: event.AfterValues,
+			PrimaryKey:   event.PrimaryKey,
+			Columns:      event.Columns,
 		})
 	case "UPDATE":
 		// UPDATE 的回滚是反向 UPDATE
@@ -146,19 +351,36 @@ func (sg *SQLGenerator) GenerateRollbackSQL(event *models.Event) string {
 			Action:       "UPDATE",
 			BeforeValues: event.AfterValues,
 			AfterValues:  event.BeforeValues,
+			PrimaryKey:   event.PrimaryKey,
+			Columns:      event.Columns,
 		})
 	case "DELETE":
 		// DELETE 的回滚是 INSERT
-		return sg.GenerateInsertSQL(&models.Event{
-			Database:    event.Database,
-			Table:       event.Table,
-			Action:      "INSERT",
-			AfterValues: event.BeforeValues,
-		})
+		return sg.GenerateInsertSQL(rollbackInsert(event))
 	}
 	return ""
 }
 
+// GenerateRollbackInsertRow 将 DELETE 的回滚 INSERT 拆分为语句头（库表和列列表）和一行 VALUES，
+// 语句头相同的多行可以合并为一个多行 INSERT。不是 DELETE 或无法回滚时返回空字符串
+func (sg *SQLGenerator) GenerateRollbackInsertRow(event *models.Event) (head, row string) {
+	if event.Action != "DELETE" || len(sg.MissingRollbackColumns(event)) > 0 {
+		return "", ""
+	}
+	return sg.insertParts(rollbackInsert(event))
+}
+
+// rollbackInsert 由 DELETE 事件构造回滚用的 INSERT 事件
+func rollbackInsert(event *models.Event) *models.Event {
+	return &models.Event{
+		Database:    event.Database,
+		Table:       event.Table,
+		Action:      "INSERT",
+		AfterValues: event.BeforeValues,
+		Columns:     event.Columns,
+	}
+}
+
 // formatValue 格式化值，支持复杂数据类型
 func (sg *SQLGenerator) formatValue(v interface{}) string {
 	if v == nil {
@@ -203,6 +425,10 @@ func (sg *SQLGenerator) formatValue(v interface{}) string {
 		}
 		return val.String()
 
+	// 定点数（解析时开启 UseDecimal），保持原始精度
+	case decimal.Decimal:
+		return val.String()
+
 	// 字符串类型
 	case string:
 		return fmt.Sprintf("'%s'", escapeSingleQuote(val))
@@ -211,6 +437,12 @@ func (sg *SQLGenerator) formatValue(v interface{}) string {
 	case []byte:
 		return sg.formatBinary(val)
 
+	// JSON 列和几何列（数据源按列类型解码）
+	case models.JSONText:
+		return fmt.Sprintf("CAST('%s' AS JSON)", escapeSingleQuote(string(val)))
+	case models.Geometry:
+		return formatGeometry(val)
+
 	// 布尔类型
 	case bool:
 		if val {
@@ -235,6 +467,15 @@ func (sg *SQLGenerator) formatValue(v interface{}) string {
 	}
 }
 
+// formatGeometry 将几何值格式化为 ST_GeomFromText 调用
+// MySQL 内部存储的坐标顺序总是经度在前，指定 SRID 时显式声明坐标顺序，避免地理坐标系按纬度在前解析
+func formatGeometry(g models.Geometry) string {
+	if g.SRID == 0 {
+		return fmt.Sprintf("ST_GeomFromText('%s')", escapeSingleQuote(g.WKT))
+	}
+	return fmt.Sprintf("ST_GeomFromText('%s', %d, 'axis-order=long-lat')", escapeSingleQuote(g.WKT), g.SRID)
+}
+
 // formatDecimal 格式化浮点数，支持科学计数法检测和精度处理
 func (sg *SQLGenerator) formatDecimal(val float64) string {
 	// 检查是否是整数
@@ -399,23 +640,239 @@ func isPrintableString(s string) bool {
 // NewSQLGenerator 创建 SQL 生成器
 func NewSQLGenerator(m *monitor.Monitor) *SQLGenerator {
 	return &SQLGenerator{
-		columnTypes: make(map[string]DataType),
-		monitor:     m,
+		columnTypes:  make(map[string]DataType),
+		columnMeta:   make(map[string]models.ColumnMeta),
+		tableKeys:    make(map[string][][]string),
+		loadedTables: make(map[string]bool),
+		monitor:      m,
 	}
 }
 
 // SetColumnType 设置列的数据类型
 func (sg *SQLGenerator) SetColumnType(schemaName, tableName, columnName string, dataType DataType) {
 	key := fmt.Sprintf("%s.%s.%s", schemaName, tableName, columnName)
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
 	sg.columnTypes[key] = dataType
 }
 
 // GetColumnType 获取列的数据类型
 func (sg *SQLGenerator) GetColumnType(schemaName, tableName, columnName string) DataType {
 	key := fmt.Sprintf("%s.%s.%s", schemaName, tableName, columnName)
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
 	return sg.columnTypes[key]
 }
 
+// SetTableMeta 根据表元数据设置表中所有列的类型，同时记录无符号、ENUM/SET 取值、主键和唯一索引等信息
+func (sg *SQLGenerator) SetTableMeta(schemaName, tableName string, meta *models.TableMeta) {
+	sg.mu.Lock()
+	sg.tableKeys[schemaName+"."+tableName] = append([][]string{meta.PrimaryKey}, meta.UniqueKeys...)
+	sg.mu.Unlock()
+	for _, column := range meta.Columns {
+		sg.SetColumnType(schemaName, tableName, column.Name, ParseColumnType(column.Type))
+		key := fmt.Sprintf("%s.%s.%s", schemaName, tableName, column.Name)
+		sg.mu.Lock()
+		sg.columnMeta[key] = column
+		sg.mu.Unlock()
+	}
+}
+
+// SetMetaLoader 设置表元数据的加载方式（例如 MetaCache.GetTableMeta），
+// 生成 SQL 时第一次遇到的表按需加载列类型，加载失败的表按值的 Go 类型格式化
+func (sg *SQLGenerator) SetMetaLoader(loader func(schema, table string) (*models.TableMeta, error)) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	sg.metaLoader = loader
+}
+
+// loadTable 设置了 metaLoader 时按需加载表元数据，每张表只加载一次
+func (sg *SQLGenerator) loadTable(schemaName, tableName string) {
+	tableKey := schemaName + "." + tableName
+	sg.mu.RLock()
+	loader, loaded := sg.metaLoader, sg.loadedTables[tableKey]
+	sg.mu.RUnlock()
+	if loader == nil || loaded {
+		return
+	}
+
+	meta, err := loader(schemaName, tableName)
+	sg.mu.Lock()
+	sg.loadedTables[tableKey] = true
+	sg.mu.Unlock()
+	if err == nil && meta != nil {
+		sg.SetTableMeta(schemaName, tableName, meta)
+	}
+}
+
+// lookupKeys 返回表的主键和唯一索引
+func (sg *SQLGenerator) lookupKeys(schemaName, tableName string) ([]string, [][]string) {
+	sg.loadTable(schemaName, tableName)
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
+	keys := sg.tableKeys[schemaName+"."+tableName]
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return keys[0], keys[1:]
+}
+
+// lookupColumn 返回列的数据类型和元数据，没有设置过时按需加载表元数据
+func (sg *SQLGenerator) lookupColumn(schemaName, tableName, columnName string) (DataType, *models.ColumnMeta) {
+	sg.loadTable(schemaName, tableName)
+
+	key := schemaName + "." + tableName + "." + columnName
+	sg.mu.RLock()
+	defer sg.mu.RUnlock()
+	if column, ok := sg.columnMeta[key]; ok {
+		return sg.columnTypes[key], &column
+	}
+	return sg.columnTypes[key], nil
+}
+
+// formatColumnValue 按列类型格式化值，列类型未知时按值的 Go 类型格式化
+func (sg *SQLGenerator) formatColumnValue(schemaName, tableName, columnName string, v interface{}) string {
+	if v == nil {
+		return "NULL"
+	}
+	dataType, column := sg.lookupColumn(schemaName, tableName, columnName)
+	if dataType == "" {
+		return sg.formatValue(v)
+	}
+	if s, ok := sg.formatTypedValue(dataType, column, v); ok {
+		return s
+	}
+	return sg.formatValue(v)
+}
+
+// formatTypedValue 按列类型格式化值，第二个返回值为 false 表示该类型没有特殊处理
+func (sg *SQLGenerator) formatTypedValue(dataType DataType, column *models.ColumnMeta, v interface{}) (string, bool) {
+	switch dataType {
+	case TypeTinyInt, TypeSmallInt, TypeMediumInt, TypeInt, TypeBigInt:
+		// 没有 binlog_row_metadata=FULL 时，UNSIGNED 列的大值被解析为负数
+		if n, ok := toInt64(v); ok && n < 0 && column != nil && column.Unsigned {
+			return strconv.FormatUint(unsignedValue(dataType, n), 10), true
+		}
+
+	case TypeEnum:
+		// 解析结果为从 1 开始的序号，0 表示非法值写入的空字符串
+		if n, ok := toInt64(v); ok && column != nil && len(column.Elements) > 0 {
+			label := ""
+			if n > 0 && int(n) <= len(column.Elements) {
+				label = column.Elements[n-1]
+			}
+			return fmt.Sprintf("'%s'", escapeSingleQuote(label)), true
+		}
+
+	case TypeSet:
+		// 解析结果为位图，第 i 位对应第 i 个取值
+		if n, ok := toInt64(v); ok && column != nil && len(column.Elements) > 0 {
+			var labels []string
+			for i, element := range column.Elements {
+				if uint64(n)&(1<<uint(i)) != 0 {
+					labels = append(labels, element)
+				}
+			}
+			return fmt.Sprintf("'%s'", escapeSingleQuote(strings.Join(labels, ","))), true
+		}
+
+	case TypeBit:
+		if n, ok := toInt64(v); ok {
+			return fmt.Sprintf("b'%s'", strconv.FormatUint(uint64(n), 2)), true
+		}
+		if b, ok := v.([]byte); ok {
+			var bits strings.Builder
+			for _, c := range b {
+				bits.WriteString(fmt.Sprintf("%08b", c))
+			}
+			return fmt.Sprintf("b'%s'", bits.String()), true
+		}
+
+	case TypeDecimal, TypeNumeric:
+		switch val := v.(type) {
+		case string:
+			if _, err := decimal.NewFromString(val); err == nil {
+				return val, true
+			}
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), true
+		}
+
+	case TypeDatetime, TypeTimestamp, TypeTime:
+		// 字符串形式的时间已经带有列定义的小数秒位数，直接输出
+		if t, ok := v.(time.Time); ok {
+			fsp := 0
+			if column != nil {
+				fsp = ParseFsp(column.Type)
+			}
+			layout := "2006-01-02 15:04:05"
+			if dataType == TypeTime {
+				layout = "15:04:05"
+			}
+			if fsp > 0 {
+				layout += "." + strings.Repeat("0", fsp)
+			}
+			return fmt.Sprintf("'%s'", t.Format(layout)), true
+		}
+
+	case TypeBinary, TypeVarbinary, TypeBlob:
+		// 二进制列总是输出十六进制，不按内容猜测是否为字符串或 UUID
+		switch val := v.(type) {
+		case []byte:
+			return formatHex(val), true
+		case string:
+			return formatHex([]byte(val)), true
+		}
+
+	case TypeChar, TypeVarchar, TypeText:
+		// TEXT 列解析结果为 []byte，按字符串输出
+		if b, ok := v.([]byte); ok {
+			return fmt.Sprintf("'%s'", escapeSingleQuote(string(b))), true
+		}
+	}
+	return "", false
+}
+
+// formatHex 将二进制数据格式化为十六进制字面量，空值输出空字符串
+func formatHex(data []byte) string {
+	if len(data) == 0 {
+		return "''"
+	}
+	return fmt.Sprintf("0x%x", data)
+}
+
+// toInt64 将有符号整数类型的值转换为 int64
+func toInt64(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), true
+	case int8:
+		return int64(val), true
+	case int16:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	}
+	return 0, false
+}
+
+// unsignedValue 将按有符号解析的负数还原为对应宽度的无符号值
+func unsignedValue(dataType DataType, n int64) uint64 {
+	switch dataType {
+	case TypeTinyInt:
+		return uint64(uint8(n))
+	case TypeSmallInt:
+		return uint64(uint16(n))
+	case TypeMediumInt:
+		return uint64(n) & 0xFFFFFF
+	case TypeInt:
+		return uint64(uint32(n))
+	}
+	return uint64(n)
+}
+
 // FormatColumnValue 格式化单个列值（用于导出或其他场景）
 func (sg *SQLGenerator) FormatColumnValue(value interface{}) string {
 	return sg.formatValue(value)
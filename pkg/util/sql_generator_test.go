@@ -1,12 +1,17 @@
 package util
 
 import (
+	"fmt"
 	"math"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/shopspring/decimal"
 )
 
 func TestGenerateInsertSQL(t *testing.T) {
@@ -112,6 +117,35 @@ func TestGenerateRollbackSQL(t *testing.T) {
 	}
 }
 
+func TestGenerateRollbackInsertRow(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	del := &models.Event{
+		Database:     "app",
+		Table:        "users",
+		Action:       "DELETE",
+		Columns:      []string{"id", "name"},
+		BeforeValues: map[string]interface{}{"id": 1, "name": "a"},
+	}
+
+	// 语句头和 VALUES 拼接后与单行回滚 SQL 相同
+	head, row := gen.GenerateRollbackInsertRow(del)
+	if head != "INSERT INTO `app`.`users` (`id`, `name`)" || row != "(1, 'a')" {
+		t.Errorf("unexpected parts %q %q", head, row)
+	}
+	if sql := gen.GenerateRollbackSQL(del); sql != head+" VALUES "+row {
+		t.Errorf("parts do not match rollback SQL %s", sql)
+	}
+
+	// 不是 DELETE 或前镜像不完整时不能合并
+	if head, _ := gen.GenerateRollbackInsertRow(&models.Event{Database: "app", Table: "users", Action: "INSERT", AfterValues: del.BeforeValues}); head != "" {
+		t.Errorf("expected no parts for INSERT, got %q", head)
+	}
+	del.BeforeMissing = []int{1}
+	if head, _ := gen.GenerateRollbackInsertRow(del); head != "" {
+		t.Errorf("expected no parts for incomplete DELETE, got %q", head)
+	}
+}
+
 // 测试复杂数据类型支持
 func TestComplexDataTypes(t *testing.T) {
 	gen := NewSQLGenerator(nil)
@@ -364,3 +398,375 @@ func TestColumnTypeManagement(t *testing.T) {
 		t.Errorf("Expected empty string for non-existent column, got %v", notExist)
 	}
 }
+
+func TestTypeAwareFormatting(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	gen.SetTableMeta("shop", "orders", &models.TableMeta{Columns: []models.ColumnMeta{
+		{Name: "id", Type: "bigint unsigned", Unsigned: true},
+		{Name: "qty", Type: "mediumint(8) unsigned", Unsigned: true},
+		{Name: "delta", Type: "int"},
+		{Name: "status", Type: "enum('new','paid')", Elements: []string{"new", "paid"}},
+		{Name: "tags", Type: "set('a','b','c')", Elements: []string{"a", "b", "c"}},
+		{Name: "flags", Type: "bit(8)"},
+		{Name: "amount", Type: "decimal(30,10)"},
+		{Name: "created_at", Type: "datetime(3)"},
+		{Name: "uuid", Type: "binary(16)"},
+		{Name: "code", Type: "varbinary(8)"},
+		{Name: "note", Type: "text"},
+	}})
+
+	tests := []struct {
+		column   string
+		value    interface{}
+		expected string
+	}{
+		{"id", int64(-1), "18446744073709551615"},
+		{"qty", int32(-1), "16777215"},
+		{"delta", int32(-1), "-1"},
+		{"status", int64(2), "'paid'"},
+		{"status", int64(0), "''"},
+		{"tags", int64(5), "'a,c'"},
+		{"flags", int64(5), "b'101'"},
+		{"amount", decimal.RequireFromString("12345678901234567890.0123456789"), "12345678901234567890.0123456789"},
+		{"amount", "0.1000000000", "0.1000000000"},
+		{"created_at", time.Date(2024, 1, 2, 3, 4, 5, 120000000, time.UTC), "'2024-01-02 03:04:05.120'"},
+		{"created_at", "2024-01-02 03:04:05.120", "'2024-01-02 03:04:05.120'"},
+		{"uuid", []byte("0123456789abcdef"), "0x30313233343536373839616263646566"},
+		{"code", "abc", "0x616263"},
+		{"note", []byte("hello"), "'hello'"},
+		{"unknown", int64(-1), "-1"},
+	}
+
+	for _, test := range tests {
+		if got := gen.formatColumnValue("shop", "orders", test.column, test.value); got != test.expected {
+			t.Errorf("%s=%v: expected %s, got %s", test.column, test.value, test.expected, got)
+		}
+	}
+}
+
+func TestMetaLoader(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	loads := 0
+	gen.SetMetaLoader(func(schema, table string) (*models.TableMeta, error) {
+		loads++
+		if table != "users" {
+			return nil, fmt.Errorf("table %s.%s not found", schema, table)
+		}
+		return &models.TableMeta{Columns: []models.ColumnMeta{{Name: "id", Type: "int unsigned", Unsigned: true}}, PrimaryKey: []string{"id"}}, nil
+	})
+
+	sql := gen.GenerateDeleteSQL(&models.Event{
+		Database:     "app",
+		Table:        "users",
+		Action:       "DELETE",
+		BeforeValues: map[string]interface{}{"id": int32(-2)},
+	})
+	if sql != "DELETE FROM `app`.`users` WHERE `id`=4294967294 LIMIT 1" {
+		t.Errorf("unexpected SQL: %s", sql)
+	}
+	gen.GenerateDeleteSQL(&models.Event{Database: "app", Table: "users", Action: "DELETE", BeforeValues: map[string]interface{}{"id": 1}})
+	gen.GenerateDeleteSQL(&models.Event{Database: "app", Table: "missing", Action: "DELETE", BeforeValues: map[string]interface{}{"id": 1}})
+	gen.GenerateDeleteSQL(&models.Event{Database: "app", Table: "missing", Action: "DELETE", BeforeValues: map[string]interface{}{"id": 1}})
+	if loads != 2 {
+		t.Errorf("expected each table to be loaded once, got %d loads", loads)
+	}
+	if gen.GetColumnType("app", "users", "id") != TypeInt {
+		t.Errorf("expected loaded column type INT, got %v", gen.GetColumnType("app", "users", "id"))
+	}
+}
+
+func TestWhereClauseUsesKeys(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	gen.SetTableMeta("app", "users", &models.TableMeta{
+		Columns: []models.ColumnMeta{
+			{Name: "id", Type: "int"},
+			{Name: "email", Type: "varchar(64)"},
+			{Name: "score", Type: "double"},
+			{Name: "note", Type: "text"},
+		},
+		PrimaryKey: []string{"id"},
+		UniqueKeys: [][]string{{"email"}},
+	})
+
+	tests := []struct {
+		name     string
+		event    *models.Event
+		expected string
+	}{
+		{
+			name: "primary key",
+			event: &models.Event{Database: "app", Table: "users", Action: "DELETE",
+				BeforeValues: map[string]interface{}{"id": 7, "email": "a@b.c", "score": 1.5, "note": nil}},
+			expected: "DELETE FROM `app`.`users` WHERE `id`=7 LIMIT 1",
+		},
+		{
+			name: "unique key when primary key missing from before-image",
+			event: &models.Event{Database: "app", Table: "users", Action: "DELETE",
+				BeforeValues: map[string]interface{}{"email": "a@b.c", "score": 1.5}},
+			expected: "DELETE FROM `app`.`users` WHERE `email`='a@b.c' LIMIT 1",
+		},
+		{
+			name: "full row with NULL and float columns",
+			event: &models.Event{Database: "app", Table: "users", Action: "DELETE",
+				BeforeValues: map[string]interface{}{"email": nil, "score": 1.5}},
+			expected: "DELETE FROM `app`.`users` WHERE `email` IS NULL AND ABS(`score`-1.5)<=1.1102230246251565e-16 LIMIT 1",
+		},
+		{
+			name: "event primary key overrides metadata",
+			event: &models.Event{Database: "app", Table: "users", Action: "UPDATE", PrimaryKey: []string{"email"},
+				BeforeValues: map[string]interface{}{"id": 7, "email": "a@b.c"},
+				AfterValues:  map[string]interface{}{"note": "x"}},
+			expected: "UPDATE `app`.`users` SET `note`='x' WHERE `email`='a@b.c' LIMIT 1",
+		},
+	}
+
+	for _, test := range tests {
+		var sql string
+		if test.event.Action == "DELETE" {
+			sql = gen.GenerateDeleteSQL(test.event)
+		} else {
+			sql = gen.GenerateUpdateSQL(test.event)
+		}
+		if sql != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, sql)
+		}
+	}
+
+	// 没有表元数据时比较整行，NULL 使用 IS NULL，其他值使用 <=>
+	sql := gen.GenerateDeleteSQL(&models.Event{Database: "app", Table: "logs", Action: "DELETE",
+		BeforeValues: map[string]interface{}{"msg": nil}})
+	if sql != "DELETE FROM `app`.`logs` WHERE `msg` IS NULL LIMIT 1" {
+		t.Errorf("unexpected fallback SQL: %s", sql)
+	}
+	sql = gen.GenerateDeleteSQL(&models.Event{Database: "app", Table: "logs", Action: "DELETE",
+		BeforeValues: map[string]interface{}{"msg": "hi"}})
+	if sql != "DELETE FROM `app`.`logs` WHERE `msg`<=>'hi' LIMIT 1" {
+		t.Errorf("unexpected fallback SQL: %s", sql)
+	}
+}
+
+func TestWhereClauseFloatColumns(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	gen.SetTableMeta("app", "readings", &models.TableMeta{
+		Columns: []models.ColumnMeta{
+			{Name: "sensor", Type: "varchar(16)"},
+			{Name: "value", Type: "double"},
+			{Name: "ratio", Type: "float"},
+		},
+	})
+	condition := regexp.MustCompile("ABS\\(`(\\w+)`-([^)]+)\\)<=(\\S+)")
+
+	// 没有主键的表中两行只有浮点列不同，每条 SQL 只能匹配自己的那一行
+	rows := []map[string]interface{}{
+		{"sensor": "a", "value": math.Nextafter(0.3, 1), "ratio": float32(1.1)},
+		{"sensor": "a", "value": 0.3, "ratio": float32(1.1)},
+		{"sensor": "a", "value": 0.3, "ratio": math.Nextafter32(1.1, 2)},
+	}
+	for i, row := range rows {
+		sql := gen.GenerateDeleteSQL(&models.Event{Database: "app", Table: "readings", Action: "DELETE", BeforeValues: row})
+		matches := condition.FindAllStringSubmatch(sql, -1)
+		if len(matches) != 2 {
+			t.Fatalf("row %d: expected conditions on both float columns, got %s", i, sql)
+		}
+		for j, other := range rows {
+			matched := true
+			for _, m := range matches {
+				value, _ := strconv.ParseFloat(m[2], 64)
+				eps, _ := strconv.ParseFloat(m[3], 64)
+				var stored float64
+				switch v := other[m[1]].(type) {
+				case float32:
+					stored = float64(v)
+				case float64:
+					stored = v
+				}
+				if math.Abs(stored-value) > eps {
+					matched = false
+				}
+			}
+			if matched != (i == j) {
+				t.Errorf("row %d: %s matches row %d: %v", i, sql, j, matched)
+			}
+		}
+	}
+}
+
+func TestColumnOrder(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+
+	values := map[string]interface{}{"name": "a", "id": 1, "email": "x", "age": 2, "city": "y"}
+	event := &models.Event{
+		Database:    "app",
+		Table:       "users",
+		Action:      "INSERT",
+		AfterValues: values,
+		Columns:     []string{"id", "name", "email", "age", "city"},
+	}
+	expected := "INSERT INTO `app`.`users` (`id`, `name`, `email`, `age`, `city`) VALUES (1, 'a', 'x', 2, 'y')"
+	for i := 0; i < 20; i++ {
+		if sql := gen.GenerateInsertSQL(event); sql != expected {
+			t.Fatalf("expected %s, got %s", expected, sql)
+		}
+	}
+
+	// 没有列序信息时 col_N 按列号排序
+	event = &models.Event{
+		Database:    "app",
+		Table:       "users",
+		Action:      "INSERT",
+		AfterValues: map[string]interface{}{"col_10": 3, "col_2": 2, "col_0": 1},
+	}
+	if sql := gen.GenerateInsertSQL(event); sql != "INSERT INTO `app`.`users` (`col_0`, `col_2`, `col_10`) VALUES (1, 2, 3)" {
+		t.Errorf("unexpected placeholder order: %s", sql)
+	}
+
+	// 回滚 DELETE 生成的 INSERT 沿用列序
+	event = &models.Event{
+		Database:     "app",
+		Table:        "users",
+		Action:       "DELETE",
+		BeforeValues: map[string]interface{}{"b": 2, "a": 1, "c": 3},
+		Columns:      []string{"c", "b", "a"},
+	}
+	if sql := gen.GenerateRollbackSQL(event); sql != "INSERT INTO `app`.`users` (`c`, `b`, `a`) VALUES (3, 2, 1)" {
+		t.Errorf("unexpected rollback column order: %s", sql)
+	}
+}
+
+func TestChangedColumnsOnly(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	gen.SetChangedColumnsOnly(true)
+
+	event := &models.Event{
+		Database:     "app",
+		Table:        "users",
+		Action:       "UPDATE",
+		PrimaryKey:   []string{"id"},
+		Columns:      []string{"id", "name", "avatar", "score"},
+		BeforeValues: map[string]interface{}{"id": 1, "name": "a", "avatar": []byte{1, 2}, "score": 10},
+		AfterValues:  map[string]interface{}{"id": 1, "name": "b", "avatar": []byte{1, 2}, "score": 10},
+	}
+	if sql := gen.GenerateUpdateSQL(event); sql != "UPDATE `app`.`users` SET `name`='b' WHERE `id`=1 LIMIT 1" {
+		t.Errorf("unexpected UPDATE: %s", sql)
+	}
+	if sql := gen.GenerateRollbackSQL(event); sql != "UPDATE `app`.`users` SET `name`='a' WHERE `id`=1 LIMIT 1" {
+		t.Errorf("unexpected rollback UPDATE: %s", sql)
+	}
+	if gen.IsNoopUpdate(event) {
+		t.Error("expected update with changes not to be a no-op")
+	}
+
+	noop := &models.Event{
+		Database:     "app",
+		Table:        "users",
+		Action:       "UPDATE",
+		BeforeValues: map[string]interface{}{"id": 1, "name": "a"},
+		AfterValues:  map[string]interface{}{"id": 1, "name": "a"},
+	}
+	if !gen.IsNoopUpdate(noop) {
+		t.Error("expected no-op update")
+	}
+	if sql := gen.GenerateUpdateSQL(noop); sql != "" {
+		t.Errorf("expected no SQL for no-op update, got %s", sql)
+	}
+
+	// 关闭后 SET 包含所有列
+	gen.SetChangedColumnsOnly(false)
+	if sql := gen.GenerateUpdateSQL(event); !strings.Contains(sql, "SET `id`=1, `name`='b', `avatar`=0x0102, `score`=10 WHERE") {
+		t.Errorf("expected all columns in SET, got %s", sql)
+	}
+}
+
+func TestIncompleteImages(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	gen.SetChangedColumnsOnly(true)
+
+	// MINIMAL：前镜像只有主键，后镜像只有修改过的列
+	update := &models.Event{
+		Database:      "app",
+		Table:         "docs",
+		Action:        "UPDATE",
+		PrimaryKey:    []string{"id"},
+		Columns:       []string{"id", "title", "body"},
+		BeforeValues:  map[string]interface{}{"id": 1},
+		AfterValues:   map[string]interface{}{"title": "new"},
+		BeforeMissing: []int{1, 2},
+		AfterMissing:  []int{0, 2},
+	}
+	if sql := gen.GenerateUpdateSQL(update); sql != "UPDATE `app`.`docs` SET `title`='new' WHERE `id`=1 LIMIT 1" {
+		t.Errorf("unexpected forward UPDATE: %s", sql)
+	}
+	if missing := gen.MissingRollbackColumns(update); !reflect.DeepEqual(missing, []string{"title"}) {
+		t.Errorf("expected title to be missing for rollback, got %v", missing)
+	}
+	if sql := gen.GenerateRollbackSQL(update); sql != "" {
+		t.Errorf("expected no rollback SQL for incomplete before image, got %s", sql)
+	}
+
+	del := &models.Event{
+		Database:      "app",
+		Table:         "docs",
+		Action:        "DELETE",
+		Columns:       []string{"id", "title", "body"},
+		BeforeValues:  map[string]interface{}{"id": 1, "title": "t"},
+		BeforeMissing: []int{2},
+	}
+	if missing := gen.MissingRollbackColumns(del); !reflect.DeepEqual(missing, []string{"body"}) {
+		t.Errorf("expected body to be missing for rollback, got %v", missing)
+	}
+	if sql := gen.GenerateRollbackSQL(del); sql != "" {
+		t.Errorf("expected no rollback SQL for NOBLOB delete, got %s", sql)
+	}
+}
+
+func TestPartialJSONUpdate(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	gen.SetChangedColumnsOnly(true)
+
+	event := &models.Event{
+		Database:     "app",
+		Table:        "docs",
+		Action:       "UPDATE",
+		PrimaryKey:   []string{"id"},
+		Columns:      []string{"id", "doc"},
+		BeforeValues: map[string]interface{}{"id": 1, "doc": `{"a": 1, "b": "x"}`},
+		AfterValues:  map[string]interface{}{"id": 1, "doc": models.JSONDiff{Op: "REPLACE", Path: "$.b", Value: `"it's"`}},
+	}
+	expected := "UPDATE `app`.`docs` SET `doc`=JSON_REPLACE(`doc`, '$.b', CAST('\"it\\'s\"' AS JSON)) WHERE `id`=1 LIMIT 1"
+	if sql := gen.GenerateUpdateSQL(event); sql != expected {
+		t.Errorf("expected %s, got %s", expected, sql)
+	}
+
+	event.AfterValues["doc"] = models.JSONDiff{Op: "REMOVE", Path: "$.a"}
+	if sql := gen.GenerateUpdateSQL(event); !strings.Contains(sql, "SET `doc`=JSON_REMOVE(`doc`, '$.a') WHERE") {
+		t.Errorf("unexpected remove SQL: %s", sql)
+	}
+
+	// 回滚时前镜像中有完整的原值，直接写回
+	if sql := gen.GenerateRollbackSQL(event); sql != "UPDATE `app`.`docs` SET `doc`='{\"a\": 1, \"b\": \"x\"}' WHERE `id`=1 LIMIT 1" {
+		t.Errorf("unexpected rollback SQL: %s", sql)
+	}
+}
+
+func TestJSONAndGeometryValues(t *testing.T) {
+	gen := NewSQLGenerator(nil)
+	event := &models.Event{
+		Database: "app",
+		Table:    "places",
+		Action:   "INSERT",
+		Columns:  []string{"id", "attrs", "location", "area"},
+		AfterValues: map[string]interface{}{
+			"id":       1,
+			"attrs":    models.JSONText(`{"name": "it's"}`),
+			"location": models.Geometry{SRID: 4326, WKT: "POINT(116.397 39.908)"},
+			"area":     models.Geometry{WKT: "POLYGON((0 0,1 0,1 1,0 0))"},
+		},
+	}
+	expected := "INSERT INTO `app`.`places` (`id`, `attrs`, `location`, `area`) VALUES (1, " +
+		`CAST('{"name": "it\'s"}' AS JSON), ` +
+		"ST_GeomFromText('POINT(116.397 39.908)', 4326, 'axis-order=long-lat'), " +
+		"ST_GeomFromText('POLYGON((0 0,1 0,1 1,0 0))'))"
+	if sql := gen.GenerateInsertSQL(event); sql != expected {
+		t.Errorf("expected %s, got %s", expected, sql)
+	}
+}
@@ -0,0 +1,321 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+	"github.com/pingcap/tidb/pkg/parser/charset"
+)
+
+// CanonicalJSON 将 JSON 文本转换为与 MySQL 输出一致的规范形式：
+// 对象的键按长度、再按字节序排列（与 MySQL 二进制 JSON 的存储顺序相同），分隔符为 ", " 和 ": "
+// 数值保持原始文本，不经过 float64 转换
+func CanonicalJSON(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return "", fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+
+	var b strings.Builder
+	writeCanonicalJSON(&b, value)
+	return b.String(), nil
+}
+
+// writeCanonicalJSON 递归输出 JSON 值
+func writeCanonicalJSON(b *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		b.WriteString(v.String())
+	case string:
+		writeJSONString(b, v)
+	case []interface{}:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeCanonicalJSON(b, item)
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeJSONString(b, key)
+			b.WriteString(": ")
+			writeCanonicalJSON(b, v[key])
+		}
+		b.WriteByte('}')
+	}
+}
+
+// writeJSONString 输出 JSON 字符串，不转义 HTML 字符和非 ASCII 字符
+func writeJSONString(b *strings.Builder, s string) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	b.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// wkbTypeNames WKB 几何类型编码对应的 WKT 名称
+var wkbTypeNames = map[uint32]string{
+	1: "POINT",
+	2: "LINESTRING",
+	3: "POLYGON",
+	4: "MULTIPOINT",
+	5: "MULTILINESTRING",
+	6: "MULTIPOLYGON",
+	7: "GEOMETRYCOLLECTION",
+}
+
+// DecodeGeometry 解码 MySQL 的几何值：4 字节小端 SRID 加标准 WKB
+func DecodeGeometry(data []byte) (models.Geometry, error) {
+	if len(data) < 4 {
+		return models.Geometry{}, fmt.Errorf("invalid geometry: %d bytes", len(data))
+	}
+	r := &wkbReader{data: data[4:]}
+	var b strings.Builder
+	if err := r.geometry(&b); err != nil {
+		return models.Geometry{}, fmt.Errorf("invalid geometry: %w", err)
+	}
+	if r.pos != len(r.data) {
+		return models.Geometry{}, fmt.Errorf("invalid geometry: %d trailing bytes", len(r.data)-r.pos)
+	}
+	return models.Geometry{SRID: binary.LittleEndian.Uint32(data), WKT: b.String()}, nil
+}
+
+// wkbReader 按 WKB 格式读取几何对象，每个对象自带字节序
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+// geometry 读取一个带类型头的几何对象，输出为 WKT
+func (r *wkbReader) geometry(b *strings.Builder) error {
+	if r.pos >= len(r.data) {
+		return fmt.Errorf("unexpected end of data")
+	}
+	switch r.data[r.pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return fmt.Errorf("invalid byte order %d", r.data[r.pos])
+	}
+	r.pos++
+
+	typ, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	name, ok := wkbTypeNames[typ]
+	if !ok {
+		return fmt.Errorf("unsupported geometry type %d", typ)
+	}
+	b.WriteString(name)
+
+	switch typ {
+	case 1:
+		return r.point(b)
+	case 2:
+		return r.points(b)
+	case 3:
+		return r.polygon(b)
+	}
+
+	// 集合类型：每个成员都是带类型头的几何对象，MULTI* 成员省略类型名
+	n, err := r.count(5)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		b.WriteString(" EMPTY")
+		return nil
+	}
+	b.WriteByte('(')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if typ == 7 {
+			if err := r.geometry(b); err != nil {
+				return err
+			}
+			continue
+		}
+		var member strings.Builder
+		if err := r.geometry(&member); err != nil {
+			return err
+		}
+		b.WriteString(strings.TrimLeft(member.String(), "ABCDEFGHIJKLMNOPQRSTUVWXYZ"))
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// point 读取一个点，输出为 (x y)
+func (r *wkbReader) point(b *strings.Builder) error {
+	b.WriteByte('(')
+	if err := r.coordinates(b); err != nil {
+		return err
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// points 读取点序列（LINESTRING 或多边形的环），输出为 (x y,x y,...)
+func (r *wkbReader) points(b *strings.Builder) error {
+	n, err := r.count(16)
+	if err != nil {
+		return err
+	}
+	b.WriteByte('(')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := r.coordinates(b); err != nil {
+			return err
+		}
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// polygon 读取多边形的所有环，输出为 ((...),(...))
+func (r *wkbReader) polygon(b *strings.Builder) error {
+	n, err := r.count(4)
+	if err != nil {
+		return err
+	}
+	b.WriteByte('(')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := r.points(b); err != nil {
+			return err
+		}
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// coordinates 读取一对坐标，输出为 x y
+func (r *wkbReader) coordinates(b *strings.Builder) error {
+	if len(r.data)-r.pos < 16 {
+		return fmt.Errorf("unexpected end of data")
+	}
+	x := math.Float64frombits(r.order.Uint64(r.data[r.pos:]))
+	y := math.Float64frombits(r.order.Uint64(r.data[r.pos+8:]))
+	r.pos += 16
+	b.WriteString(strconv.FormatFloat(x, 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(y, 'f', -1, 64))
+	return nil
+}
+
+// count 读取元素个数，minSize 为每个元素的最小字节数，用于拒绝被截断或损坏的数据
+func (r *wkbReader) count(minSize int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if int64(n)*int64(minSize) > int64(len(r.data)-r.pos) {
+		return 0, fmt.Errorf("element count %d exceeds data size", n)
+	}
+	return int(n), nil
+}
+
+// uint32 按当前字节序读取 4 字节整数
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+	v := r.order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+// mysqlCharsetLabels MySQL 字符集名称与编码表中名称不一致的部分
+var mysqlCharsetLabels = map[string]string{
+	"koi8r":    "koi8-r",
+	"koi8u":    "koi8-u",
+	"latin7":   "iso-8859-13",
+	"ujis":     "euc-jp",
+	"eucjpms":  "euc-jp",
+	"euckr":    "euc-kr",
+	"cp932":    "windows-31j",
+	"tis620":   "tis-620",
+	"macroman": "macintosh",
+	"ucs2":     "utf-16be",
+	"utf16":    "utf-16be",
+	"utf16le":  "utf-16le",
+}
+
+// IsUTF8Charset 判断字符集的数据是否可以直接作为 UTF-8 字符串使用（未知字符集按 UTF-8 处理）
+func IsUTF8Charset(name string) bool {
+	name = strings.ToLower(name)
+	return name == "" || strings.HasPrefix(name, "utf8") || name == "ascii" || name == "binary"
+}
+
+// DecodeString 将 name 字符集编码的字符串转换为 UTF-8
+// 字符集为 UTF-8 或不支持时原样返回，第二个返回值表示是否做了转换
+func DecodeString(name string, data []byte) (string, bool) {
+	if IsUTF8Charset(name) {
+		return string(data), false
+	}
+	label := strings.ToLower(name)
+	if alias, ok := mysqlCharsetLabels[label]; ok {
+		label = alias
+	}
+	enc, _ := charset.Lookup(label)
+	if enc == nil {
+		return string(data), false
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data), false
+	}
+	return string(decoded), true
+}
+
+// CharsetOfCollation 返回排序规则 ID 对应的字符集名称，未知 ID 返回空字符串
+func CharsetOfCollation(id uint64) string {
+	collation, err := charset.GetCollationByID(int(id))
+	if err != nil {
+		return ""
+	}
+	return collation.CharsetName
+}
@@ -0,0 +1,133 @@
+package util
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/aitoooooo/binlogx/pkg/models"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// go-mysql 按字母序输出键，MySQL 按键长再按字节序
+		{`{"bb":1,"a":[1,2.50,"x<y"],"c":{"z":null,"yy":true}}`, `{"a": [1, 2.50, "x<y"], "c": {"z": null, "yy": true}, "bb": 1}`},
+		{`"中文"`, `"中文"`},
+		{`[]`, `[]`},
+		{`12345678901234567890`, `12345678901234567890`},
+	}
+	for _, test := range tests {
+		got, err := CanonicalJSON([]byte(test.input))
+		if err != nil {
+			t.Errorf("CanonicalJSON(%s) failed: %v", test.input, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("CanonicalJSON(%s) = %s, expected %s", test.input, got, test.expected)
+		}
+	}
+
+	for _, input := range []string{`{"a":`, `1 2`, ``} {
+		if _, err := CanonicalJSON([]byte(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+// wkbPoint 构造小端序的 WKB 点
+func wkbPoint(x, y float64) []byte {
+	data := []byte{1, 1, 0, 0, 0}
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(x))
+	return binary.LittleEndian.AppendUint64(data, math.Float64bits(y))
+}
+
+func TestDecodeGeometry(t *testing.T) {
+	withSRID := func(srid uint32, wkb []byte) []byte {
+		return append(binary.LittleEndian.AppendUint32(nil, srid), wkb...)
+	}
+
+	// MULTIPOINT 的成员是带类型头的点
+	multiPoint := []byte{1, 4, 0, 0, 0, 2, 0, 0, 0}
+	multiPoint = append(multiPoint, wkbPoint(0, 0)...)
+	multiPoint = append(multiPoint, wkbPoint(1.5, -2)...)
+
+	// 大端序的多边形：一个环、四个点
+	polygon, _ := hex.DecodeString("00" + "00000003" + "00000001" + "00000004" +
+		"0000000000000000" + "0000000000000000" +
+		"3ff0000000000000" + "0000000000000000" +
+		"3ff0000000000000" + "3ff0000000000000" +
+		"0000000000000000" + "0000000000000000")
+
+	collection := []byte{1, 7, 0, 0, 0, 2, 0, 0, 0}
+	collection = append(collection, wkbPoint(1, 2)...)
+	collection = append(collection, multiPoint...)
+
+	tests := []struct {
+		data     []byte
+		expected models.Geometry
+	}{
+		{withSRID(0, wkbPoint(1, 2)), models.Geometry{WKT: "POINT(1 2)"}},
+		{withSRID(4326, wkbPoint(116.397, 39.908)), models.Geometry{SRID: 4326, WKT: "POINT(116.397 39.908)"}},
+		{withSRID(0, multiPoint), models.Geometry{WKT: "MULTIPOINT((0 0),(1.5 -2))"}},
+		{withSRID(0, polygon), models.Geometry{WKT: "POLYGON((0 0,1 0,1 1,0 0))"}},
+		{withSRID(0, collection), models.Geometry{WKT: "GEOMETRYCOLLECTION(POINT(1 2),MULTIPOINT((0 0),(1.5 -2)))"}},
+		{withSRID(0, []byte{1, 7, 0, 0, 0, 0, 0, 0, 0}), models.Geometry{WKT: "GEOMETRYCOLLECTION EMPTY"}},
+	}
+	for _, test := range tests {
+		got, err := DecodeGeometry(test.data)
+		if err != nil {
+			t.Errorf("DecodeGeometry(%x) failed: %v", test.data, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("DecodeGeometry(%x) = %+v, expected %+v", test.data, got, test.expected)
+		}
+	}
+
+	// 截断、元素个数损坏、未知类型
+	for _, data := range [][]byte{
+		withSRID(0, wkbPoint(1, 2)[:12]),
+		withSRID(0, []byte{1, 2, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}),
+		withSRID(0, []byte{1, 99, 0, 0, 0}),
+		{0, 0},
+	} {
+		if _, err := DecodeGeometry(data); err == nil {
+			t.Errorf("expected error for %x", data)
+		}
+	}
+}
+
+func TestDecodeString(t *testing.T) {
+	tests := []struct {
+		charset   string
+		data      []byte
+		expected  string
+		converted bool
+	}{
+		{"latin1", []byte{'c', 'a', 'f', 0xe9}, "café", true},
+		{"gbk", []byte{0xd6, 0xd0, 0xce, 0xc4}, "中文", true},
+		{"utf8mb4", []byte("中文"), "中文", false},
+		{"", []byte("abc"), "abc", false},
+		{"unknown", []byte("abc"), "abc", false},
+	}
+	for _, test := range tests {
+		got, converted := DecodeString(test.charset, test.data)
+		if got != test.expected || converted != test.converted {
+			t.Errorf("DecodeString(%s, %x) = %q, %v, expected %q, %v", test.charset, test.data, got, converted, test.expected, test.converted)
+		}
+	}
+
+	if name := CharsetOfCollation(8); name != "latin1" {
+		t.Errorf("expected latin1 for collation 8, got %q", name)
+	}
+	if name := CharsetOfCollation(28); name != "gbk" {
+		t.Errorf("expected gbk for collation 28, got %q", name)
+	}
+	if name := CharsetOfCollation(9999); name != "" {
+		t.Errorf("expected empty charset for unknown collation, got %q", name)
+	}
+}